@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grvbrk/async0_server/internal/app"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+// How often rejudge -wait checks on progress
+const rejudgePollInterval = 2 * time.Second
+
+func runCommand(app *app.Application, args []string) {
+	var err error
+	switch args[0] {
+	case "rejudge":
+		err = rejudgeCommand(app, args[1:])
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+
+	if err != nil {
+		app.Logger.Fatal("Error running command: ", err)
+	}
+}
+
+// rejudgeCommand queues a rejudge for the running server's workers to pick
+// up, or shows the progress of one that was already started.
+//
+//	rejudge -problem <id> [-submissions <id>,<id>] [-wait]
+//	rejudge -status <rejudge id> [-wait]
+func rejudgeCommand(app *app.Application, args []string) error {
+	flags := flag.NewFlagSet("rejudge", flag.ExitOnError)
+	problem := flags.String("problem", "", "ID of the problem whose submissions to rejudge")
+	submissions := flags.String("submissions", "", "comma separated submission IDs, all of the problem's when empty")
+	status := flags.String("status", "", "ID of a rejudge to show the progress of")
+	wait := flags.Bool("wait", false, "keep reporting progress until the rejudge finishes")
+	flags.Parse(args)
+
+	var rejudge *models.Rejudge
+	switch {
+	case *status != "":
+		rejudgeID, err := uuid.Parse(*status)
+		if err != nil {
+			return fmt.Errorf("invalid rejudge id: %w", err)
+		}
+
+		rejudge, err = app.JudgePool.RejudgeStore.GetRejudgeByID(rejudgeID)
+		if err != nil {
+			return err
+		}
+
+	case *problem != "":
+		problemID, err := uuid.Parse(*problem)
+		if err != nil {
+			return fmt.Errorf("invalid problem id: %w", err)
+		}
+
+		var submissionIDs []uuid.UUID
+		for _, id := range strings.Split(*submissions, ",") {
+			if strings.TrimSpace(id) == "" {
+				continue
+			}
+
+			submissionID, err := uuid.Parse(strings.TrimSpace(id))
+			if err != nil {
+				return fmt.Errorf("invalid submission id: %w", err)
+			}
+			submissionIDs = append(submissionIDs, submissionID)
+		}
+
+		rejudge, err = app.JudgePool.Rejudge(context.Background(), problemID, submissionIDs)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Started rejudge", rejudge.ID)
+
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	printRejudge(rejudge)
+	for *wait && !rejudge.IsFinished() {
+		time.Sleep(rejudgePollInterval)
+
+		var err error
+		rejudge, err = app.JudgePool.RejudgeStore.GetRejudgeByID(rejudge.ID)
+		if err != nil {
+			return err
+		}
+		printRejudge(rejudge)
+	}
+
+	return nil
+}
+
+func printRejudge(rejudge *models.Rejudge) {
+	fmt.Printf("%d/%d judged, %d changed verdict, %d failed\n", rejudge.Done, rejudge.Total, rejudge.Changed, rejudge.Failed)
+}
@@ -2,14 +2,20 @@ package app
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/sessions"
 	"github.com/grvbrk/async0_server/internal/auth"
+	"github.com/grvbrk/async0_server/internal/executor"
 	"github.com/grvbrk/async0_server/internal/handlers"
 	adminHandler "github.com/grvbrk/async0_server/internal/handlers/admin"
+	"github.com/grvbrk/async0_server/internal/judge"
 	"github.com/grvbrk/async0_server/internal/middlewares"
 	"github.com/grvbrk/async0_server/internal/services"
 	"github.com/grvbrk/async0_server/internal/store"
@@ -25,6 +31,8 @@ import (
 // 	adminEncryptionKey = securecookie.GenerateRandomKey(32)
 // )
 
+const judge0HealthInterval = 10 * time.Second
+
 type Application struct {
 	Logger      *log.Logger
 	redisClient *redis.Client
@@ -46,8 +54,14 @@ type Application struct {
 	AdminTopicHandler    *adminHandler.AdminTopicHandler
 	AdminTestcaseHandler *adminHandler.AdminTestcaseHandler
 	AdminSolutionHandler *adminHandler.AdminSolutionHandler
+	AdminRejudgeHandler  *adminHandler.AdminRejudgeHandler
 
 	UserAnalyticsHandler *handlers.AnalyticsHandler
+
+	JudgeCallbackHandler *handlers.JudgeCallbackHandler
+
+	// JudgePool is started by the server, commands only queue work on it
+	JudgePool *judge.Pool
 }
 
 func NewApplication() (*Application, error) {
@@ -130,6 +144,7 @@ func NewApplication() (*Application, error) {
 	testcaseStore := store.NewPostgresTestcaseStore(pgDB)
 	submissionStore := store.NewPostgresSubmissionStore(pgDB)
 	topicStore := store.NewPostgresTopicStore(pgDB)
+	rejudgeStore := store.NewPostgresRejudgeStore(pgDB)
 
 	// admin stores
 	adminProblemStore := admin.NewPostgresAdminProblemStore(pgDB)
@@ -141,6 +156,68 @@ func NewApplication() (*Application, error) {
 	// analytics store
 	analyticsStore := store.NewPostgresAnalyticsStore(pgDB)
 
+	// code executor, judge0 unless a local sandbox is asked for
+	var codeExecutor executor.CodeExecutor
+	var callbackURL string
+	callbackSecret := os.Getenv("JUDGE0_CALLBACK_SECRET")
+
+	// Bytes kept of each of a run's stdout, stderr and compile output. Outputs
+	// are compared after the cut, so keep it above the longest expected output.
+	outputLimit, err := strconv.Atoi(os.Getenv("JUDGE_OUTPUT_LIMIT"))
+	if err != nil || outputLimit <= 0 {
+		outputLimit = 64 * 1024
+	}
+
+	if os.Getenv("CODE_EXECUTOR") == "local" {
+		codeExecutor = executor.NewLocalExecutor(os.Getenv("LOCAL_SANDBOX_DIR"), outputLimit)
+	} else {
+		// JUDGE0_URLS lists every judge box, JUDGE0_URL is the single box setup
+		judge0URLs := os.Getenv("JUDGE0_URLS")
+		if judge0URLs == "" {
+			judge0URLs = os.Getenv("JUDGE0_URL")
+		}
+
+		backends, err := executor.ParseJudge0Backends(judge0URLs)
+		if err != nil {
+			logger.Println("PANIC: Invalid judge0 configuration, exiting...")
+			return nil, err
+		}
+
+		// Judge0's max_submission_batch_size, larger batches are split up
+		batchSize, err := strconv.Atoi(os.Getenv("JUDGE0_BATCH_SIZE"))
+		if err != nil || batchSize <= 0 {
+			batchSize = 20
+		}
+
+		judge0Executor := executor.NewJudge0Executor(backends, batchSize, outputLimit, logger)
+		judge0Executor.StartHealthChecks(context.Background(), judge0HealthInterval)
+		codeExecutor = judge0Executor
+
+		// Anyone who can reach the callback route could feed it results otherwise
+		callbackURL = os.Getenv("JUDGE0_CALLBACK_URL")
+		if callbackURL != "" && callbackSecret == "" {
+			logger.Println("PANIC: JUDGE0_CALLBACK_URL is set without JUDGE0_CALLBACK_SECRET, exiting...")
+			return nil, errors.New("JUDGE0_CALLBACK_SECRET is required with JUDGE0_CALLBACK_URL")
+		}
+		if callbackURL != "" {
+			callbackURL += "?secret=" + url.QueryEscape(callbackSecret)
+		}
+	}
+
+	judgeWorkers, err := strconv.Atoi(os.Getenv("JUDGE_WORKERS"))
+	if err != nil || judgeWorkers <= 0 {
+		judgeWorkers = 4
+	}
+
+	judgeUserLimit, err := strconv.Atoi(os.Getenv("JUDGE_USER_LIMIT"))
+	if err != nil || judgeUserLimit <= 0 {
+		judgeUserLimit = 3
+	}
+
+	judgeQueue := judge.NewQueue(redisClient, judgeUserLimit, 500)
+	resultCache := judge.NewResultCache(redisClient, logger)
+	judgePool := judge.NewPool(codeExecutor, submissionStore, problemStore, testcaseStore, rejudgeStore, redisClient, judgeQueue, resultCache, logger, callbackURL, judgeWorkers)
+
 	oauth, err := auth.NewGoogleOauth(logger, sessionStore, userStore)
 	if err != nil {
 		return nil, err
@@ -156,19 +233,23 @@ func NewApplication() (*Application, error) {
 	userSolutionHandler := handlers.NewSolutionHandler(solutionStore, logger, oauth)
 	userListHandler := handlers.NewListHandler(listStore, logger, oauth)
 	userTestcaseHandler := handlers.NewTestcaseHandler(testcaseStore, logger, oauth)
-	userSubmissionHandler := handlers.NewSubmissionHandler(submissionStore, testcaseStore, logger, oauth)
+	userSubmissionHandler := handlers.NewSubmissionHandler(submissionStore, problemStore, testcaseStore, codeExecutor, judgePool, logger, oauth)
 	userTopicHandler := handlers.NewTopicHandler(topicStore, logger, oauth)
 
 	// admin handlers
-	adminProblemHandler := adminHandler.NewAdminProblemHandler(adminProblemStore, adminLogger, adminOauth)
+	adminProblemHandler := adminHandler.NewAdminProblemHandler(adminProblemStore, codeExecutor, resultCache, adminLogger, adminOauth)
 	adminListHandler := adminHandler.NewAdminListHandler(adminListStore, adminLogger, adminOauth)
 	adminTopicHandler := adminHandler.NewAdminTopicHandler(adminTopicStore, adminLogger, adminOauth)
 	adminTestcaseHandler := adminHandler.NewAdminTestcaseHandler(adminTestcaseStore, adminLogger, adminOauth)
 	adminSolutionHandler := adminHandler.NewAdminSolutionHandler(adminSolutionStore, adminLogger, adminOauth)
+	adminRejudgeHandler := adminHandler.NewAdminRejudgeHandler(rejudgeStore, judgePool, adminLogger, adminOauth)
 
 	// analytics handlers
 	userAnalyticsHandler := handlers.NewAnalyticsHandler(logger, oauth, analyticsStore)
 
+	// internal handlers
+	judgeCallbackHandler := handlers.NewJudgeCallbackHandler(judgePool, callbackSecret, outputLimit, logger)
+
 	app := &Application{
 		Logger:      logger,
 		redisClient: redisClient,
@@ -190,8 +271,13 @@ func NewApplication() (*Application, error) {
 		AdminTopicHandler:    adminTopicHandler,
 		AdminTestcaseHandler: adminTestcaseHandler,
 		AdminSolutionHandler: adminSolutionHandler,
+		AdminRejudgeHandler:  adminRejudgeHandler,
 
 		UserAnalyticsHandler: userAnalyticsHandler,
+
+		JudgeCallbackHandler: judgeCallbackHandler,
+
+		JudgePool: judgePool,
 	}
 
 	return app, nil
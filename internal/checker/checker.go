@@ -0,0 +1,225 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+type Mode string
+
+const (
+	ModeExact       Mode = "exact"
+	ModeWhitespace  Mode = "whitespace"
+	ModeFloat       Mode = "float"
+	ModeUnordered   Mode = "unordered"
+	ModeSetOfArrays Mode = "set_of_arrays"
+	ModeSpecial     Mode = "special"
+)
+
+const DefaultTolerance = 1e-6
+
+var ErrUnknownMode = errors.New("unknown checker mode")
+
+func (m Mode) IsValid() bool {
+	switch m {
+	case ModeExact, ModeWhitespace, ModeFloat, ModeUnordered, ModeSetOfArrays, ModeSpecial:
+		return true
+	}
+	return false
+}
+
+// Case is one finished run to be checked against its expected output.
+type Case struct {
+	Input    string
+	Expected string
+	Actual   string
+}
+
+// Checker decides AC, WA or PE for runs that finished cleanly. Cases are
+// checked together so a special judge can run them in a single batch.
+type Checker interface {
+	Check(ctx context.Context, cases []Case) ([]models.SubmissionStatus, error)
+}
+
+// New returns one of the built-in checkers. Special judges need an executor
+// and are built with NewSpecial instead.
+func New(mode Mode, tolerance float64) (Checker, error) {
+	if mode == "" {
+		mode = ModeWhitespace
+	}
+
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+
+	switch mode {
+	case ModeExact:
+		return builtin(exact), nil
+	case ModeWhitespace:
+		return builtin(whitespace), nil
+	case ModeFloat:
+		return builtin(func(expected, actual string) models.SubmissionStatus {
+			return floats(expected, actual, tolerance)
+		}), nil
+	case ModeUnordered:
+		return builtin(unordered), nil
+	case ModeSetOfArrays:
+		return builtin(setOfArrays), nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownMode, mode)
+}
+
+type builtin func(expected, actual string) models.SubmissionStatus
+
+func (b builtin) Check(ctx context.Context, cases []Case) ([]models.SubmissionStatus, error) {
+	verdicts := make([]models.SubmissionStatus, len(cases))
+	for i, c := range cases {
+		verdicts[i] = b(c.Expected, c.Actual)
+	}
+	return verdicts, nil
+}
+
+func verdict(ok bool) models.SubmissionStatus {
+	if ok {
+		return models.StatusAC
+	}
+	return models.StatusWA
+}
+
+// exact only forgives the trailing newline. Output that matches once
+// whitespace is ignored is a presentation error rather than a wrong answer.
+func exact(expected, actual string) models.SubmissionStatus {
+	if strings.TrimRight(expected, "\r\n") == strings.TrimRight(actual, "\r\n") {
+		return models.StatusAC
+	}
+
+	if whitespace(expected, actual) == models.StatusAC {
+		return models.StatusPE
+	}
+
+	return models.StatusWA
+}
+
+func whitespace(expected, actual string) models.SubmissionStatus {
+	return verdict(stripWhitespace(expected) == stripWhitespace(actual))
+}
+
+func stripWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// floats compares two JSON values, allowing numbers to differ by the
+// absolute or relative tolerance.
+func floats(expected, actual string, tolerance float64) models.SubmissionStatus {
+	var want, got any
+	if json.Unmarshal([]byte(expected), &want) != nil || json.Unmarshal([]byte(actual), &got) != nil {
+		return whitespace(expected, actual)
+	}
+
+	return verdict(closeEnough(want, got, tolerance))
+}
+
+func closeEnough(want, got any, tolerance float64) bool {
+	switch w := want.(type) {
+	case float64:
+		g, ok := got.(float64)
+		if !ok {
+			return false
+		}
+		diff := math.Abs(w - g)
+		return diff <= tolerance || diff <= tolerance*math.Abs(w)
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(w) != len(g) {
+			return false
+		}
+		for i := range w {
+			if !closeEnough(w[i], g[i], tolerance) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok || len(w) != len(g) {
+			return false
+		}
+		for key, value := range w {
+			if !closeEnough(value, g[key], tolerance) {
+				return false
+			}
+		}
+		return true
+	default:
+		return want == got
+	}
+}
+
+// unordered treats both outputs as JSON arrays and compares them as multisets.
+func unordered(expected, actual string) models.SubmissionStatus {
+	want, ok := canonicalElements(expected, false)
+	if !ok {
+		return whitespace(expected, actual)
+	}
+
+	got, ok := canonicalElements(actual, false)
+	return verdict(ok && slices.Equal(want, got))
+}
+
+// setOfArrays is unordered where the order inside each inner array doesn't
+// matter either, e.g. "return all subsets".
+func setOfArrays(expected, actual string) models.SubmissionStatus {
+	want, ok := canonicalElements(expected, true)
+	if !ok {
+		return whitespace(expected, actual)
+	}
+
+	got, ok := canonicalElements(actual, true)
+	return verdict(ok && slices.Equal(want, got))
+}
+
+// canonicalElements parses a JSON array and returns its elements re-encoded
+// and sorted, so equal multisets compare equal.
+func canonicalElements(s string, sortInner bool) ([]string, bool) {
+	var elements []any
+	if err := json.Unmarshal([]byte(s), &elements); err != nil {
+		return nil, false
+	}
+
+	encoded := make([]string, len(elements))
+	for i, element := range elements {
+		if sortInner {
+			inner, ok := element.([]any)
+			if !ok {
+				return nil, false
+			}
+
+			innerEncoded := make([]string, len(inner))
+			for j, value := range inner {
+				innerEncoded[j] = encode(value)
+			}
+			slices.Sort(innerEncoded)
+			encoded[i] = "[" + strings.Join(innerEncoded, ",") + "]"
+			continue
+		}
+
+		encoded[i] = encode(element)
+	}
+
+	slices.Sort(encoded)
+	return encoded, true
+}
+
+func encode(value any) string {
+	// Marshal sorts map keys, so objects encode the same way every time
+	b, _ := json.Marshal(value)
+	return string(b)
+}
@@ -0,0 +1,66 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+func TestBuiltinCheckers(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      Mode
+		tolerance float64
+		expected  string
+		actual    string
+		want      models.SubmissionStatus
+	}{
+		{"exact match", ModeExact, 0, "1 2\n3", "1 2\n3", models.StatusAC},
+		{"exact forgives trailing newline", ModeExact, 0, "1 2", "1 2\r\n", models.StatusAC},
+		{"exact spacing is presentation", ModeExact, 0, "1 2", "1  2", models.StatusPE},
+		{"exact different", ModeExact, 0, "1 2", "1 3", models.StatusWA},
+		{"default is whitespace", "", 0, "[1, 2]", "[1,2]\n", models.StatusAC},
+		{"whitespace different", ModeWhitespace, 0, "[1,2]", "[1,3]", models.StatusWA},
+		{"float within default tolerance", ModeFloat, 0, "[0.1, 2]", "[0.1000000001, 2]", models.StatusAC},
+		{"float outside tolerance", ModeFloat, 0, "0.1", "0.11", models.StatusWA},
+		{"float custom tolerance", ModeFloat, 0.05, "0.1", "0.11", models.StatusAC},
+		{"float relative tolerance", ModeFloat, 0, "1000000000", "1000000001", models.StatusAC},
+		{"float nested objects", ModeFloat, 0, `{"a": [1.5]}`, `{"a": [1.5000000001]}`, models.StatusAC},
+		{"float length mismatch", ModeFloat, 0, "[1, 2]", "[1]", models.StatusWA},
+		{"float falls back on text", ModeFloat, 0, "yes", " yes ", models.StatusAC},
+		{"unordered same multiset", ModeUnordered, 0, "[1,2,2,3]", "[2,3,1,2]", models.StatusAC},
+		{"unordered counts matter", ModeUnordered, 0, "[1,2,2]", "[1,1,2]", models.StatusWA},
+		{"unordered not an array", ModeUnordered, 0, "[1,2]", "1 2", models.StatusWA},
+		{"set of arrays ignores inner order", ModeSetOfArrays, 0, "[[1,2],[3]]", "[[3],[2,1]]", models.StatusAC},
+		{"set of arrays different", ModeSetOfArrays, 0, "[[1,2],[3]]", "[[1,3],[2]]", models.StatusWA},
+		{"set of arrays needs inner arrays", ModeSetOfArrays, 0, "[[1],[2]]", "[1,2]", models.StatusWA},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(tt.mode, tt.tolerance)
+			if err != nil {
+				t.Fatalf("New(%q) error: %v", tt.mode, err)
+			}
+
+			verdicts, err := c.Check(context.Background(), []Case{{Expected: tt.expected, Actual: tt.actual}})
+			if err != nil {
+				t.Fatalf("Check error: %v", err)
+			}
+
+			if verdicts[0] != tt.want {
+				t.Errorf("Check(%q, %q) = %q, want %q", tt.expected, tt.actual, verdicts[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestNewUnknownMode(t *testing.T) {
+	for _, mode := range []Mode{"fuzzy", ModeSpecial} {
+		if _, err := New(mode, 0); !errors.Is(err, ErrUnknownMode) {
+			t.Errorf("New(%q) error = %v, want ErrUnknownMode", mode, err)
+		}
+	}
+}
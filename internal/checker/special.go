@@ -0,0 +1,80 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+// Special is a "special judge": an admin-supplied program that defines
+// check(input, expected, actual) and returns true when the output is
+// acceptable. All three arguments are passed as strings.
+type Special struct {
+	Executor   executor.CodeExecutor
+	LanguageID int
+	Code       string
+}
+
+func NewSpecial(codeExecutor executor.CodeExecutor, languageID int, code string) *Special {
+	return &Special{
+		Executor:   codeExecutor,
+		LanguageID: languageID,
+		Code:       code,
+	}
+}
+
+const specialTemplate = `
+	%s
+	try {
+		const verdict = check(%s, %s, %s);
+		console.log(verdict ? "AC" : "WA");
+	} catch (error) {
+		console.error('Checker Error:', error.message);
+		process.exit(1);
+	}
+`
+
+func (s *Special) Check(ctx context.Context, cases []Case) ([]models.SubmissionStatus, error) {
+	if len(cases) == 0 {
+		return nil, nil
+	}
+
+	submissions := make([]executor.Submission, len(cases))
+	for i, c := range cases {
+		submissions[i] = executor.Submission{
+			LanguageID: s.LanguageID,
+			SourceCode: fmt.Sprintf(specialTemplate, s.Code, quote(c.Input), quote(c.Expected), quote(c.Actual)),
+		}
+	}
+
+	tokens, err := s.Executor.SubmitBatch(ctx, submissions)
+	if err != nil {
+		return nil, fmt.Errorf("error submitting checker batch: %w", err)
+	}
+
+	results, err := executor.AwaitBatch(ctx, s.Executor, tokens, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error awaiting checker batch: %w", err)
+	}
+
+	verdicts := make([]models.SubmissionStatus, len(results))
+	for i, result := range results {
+		if result.Status.ID != executor.StatusAccepted || result.Stdout == nil {
+			// A broken checker says nothing about the submission
+			return nil, fmt.Errorf("checker failed on case %d with status %d", i, result.Status.ID)
+		}
+
+		verdicts[i] = verdict(strings.TrimSpace(*result.Stdout) == "AC")
+	}
+
+	return verdicts, nil
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,142 @@
+package executor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	// Each step is applied in order, want is the state after it. "cool"
+	// moves the opening back past the cooldown instead of sleeping.
+	type step struct {
+		op   string
+		ok   bool // what acquire or failure returned
+		want breakerState
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens after threshold failures",
+			steps: []step{
+				{op: "failure", ok: false, want: breakerClosed},
+				{op: "failure", ok: false, want: breakerClosed},
+				{op: "failure", ok: true, want: breakerOpen},
+				{op: "acquire", ok: false, want: breakerOpen},
+			},
+		},
+		{
+			name: "success resets the count",
+			steps: []step{
+				{op: "failure", want: breakerClosed},
+				{op: "failure", want: breakerClosed},
+				{op: "success", want: breakerClosed},
+				{op: "failure", ok: false, want: breakerClosed},
+				{op: "acquire", ok: true, want: breakerClosed},
+			},
+		},
+		{
+			name: "one trial after the cooldown, closed by success",
+			steps: []step{
+				{op: "failure", want: breakerClosed},
+				{op: "failure", want: breakerClosed},
+				{op: "failure", ok: true, want: breakerOpen},
+				{op: "cool", want: breakerOpen},
+				{op: "acquire", ok: true, want: breakerHalfOpen},
+				{op: "acquire", ok: false, want: breakerHalfOpen},
+				{op: "success", want: breakerClosed},
+				{op: "acquire", ok: true, want: breakerClosed},
+			},
+		},
+		{
+			name: "failed trial opens it again",
+			steps: []step{
+				{op: "failure", want: breakerClosed},
+				{op: "failure", want: breakerClosed},
+				{op: "failure", ok: true, want: breakerOpen},
+				{op: "cool", want: breakerOpen},
+				{op: "acquire", ok: true, want: breakerHalfOpen},
+				{op: "failure", ok: true, want: breakerOpen},
+				{op: "acquire", ok: false, want: breakerOpen},
+			},
+		},
+		{
+			name: "released trial can be taken again",
+			steps: []step{
+				{op: "failure", want: breakerClosed},
+				{op: "failure", want: breakerClosed},
+				{op: "failure", ok: true, want: breakerOpen},
+				{op: "cool", want: breakerOpen},
+				{op: "acquire", ok: true, want: breakerHalfOpen},
+				{op: "release", want: breakerOpen},
+				{op: "acquire", ok: true, want: breakerHalfOpen},
+			},
+		},
+		{
+			name: "failures while open don't reopen it",
+			steps: []step{
+				{op: "failure", want: breakerClosed},
+				{op: "failure", want: breakerClosed},
+				{op: "failure", ok: true, want: breakerOpen},
+				{op: "failure", ok: false, want: breakerOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker(3, time.Hour)
+
+			for i, s := range tt.steps {
+				var ok bool
+				switch s.op {
+				case "acquire":
+					ok = b.acquire()
+				case "failure":
+					ok = b.failure()
+				case "success":
+					b.success()
+				case "release":
+					b.release()
+				case "cool":
+					b.openedAt = b.openedAt.Add(-time.Hour)
+				}
+
+				if (s.op == "acquire" || s.op == "failure") && ok != s.ok {
+					t.Fatalf("step %d: %s returned %v, want %v", i, s.op, ok, s.ok)
+				}
+				if b.state != s.want {
+					t.Fatalf("step %d: state after %s = %d, want %d", i, s.op, b.state, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerReady(t *testing.T) {
+	b := newBreaker(1, time.Hour)
+
+	if !b.ready() {
+		t.Fatal("closed breaker isn't ready")
+	}
+
+	b.failure()
+	if b.ready() {
+		t.Fatal("open breaker is ready before the cooldown")
+	}
+
+	b.openedAt = b.openedAt.Add(-time.Hour)
+	if !b.ready() {
+		t.Fatal("open breaker isn't ready after the cooldown")
+	}
+	if b.state != breakerOpen {
+		t.Fatal("ready took the trial")
+	}
+
+	b.acquire()
+	if b.ready() {
+		t.Fatal("half open breaker is ready while its trial is out")
+	}
+}
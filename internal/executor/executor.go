@@ -0,0 +1,230 @@
+package executor
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	StatusInQueue           = 1
+	StatusProcessing        = 2
+	StatusAccepted          = 3
+	StatusWrongAnswer       = 4
+	StatusTimeLimitExceeded = 5
+	StatusCompilationError  = 6
+	StatusRuntimeSIGSEGV    = 7
+	StatusRuntimeSIGXFSZ    = 8
+	StatusRuntimeSIGFPE     = 9
+	StatusRuntimeSIGABRT    = 10
+	StatusRuntimeNZEC       = 11
+	StatusRuntimeOther      = 12
+	StatusInternalError     = 13
+	StatusExecFormatError   = 14
+)
+
+var StatusDescriptions = map[int]string{
+	StatusInQueue:           "In Queue",
+	StatusProcessing:        "Processing",
+	StatusAccepted:          "Accepted",
+	StatusWrongAnswer:       "Wrong Answer",
+	StatusTimeLimitExceeded: "Time Limit Exceeded",
+	StatusCompilationError:  "Compilation Error",
+	StatusRuntimeSIGSEGV:    "Runtime Error (SIGSEGV)",
+	StatusRuntimeSIGXFSZ:    "Runtime Error (SIGXFSZ)",
+	StatusRuntimeSIGFPE:     "Runtime Error (SIGFPE)",
+	StatusRuntimeSIGABRT:    "Runtime Error (SIGABRT)",
+	StatusRuntimeNZEC:       "Runtime Error (NZEC)",
+	StatusRuntimeOther:      "Runtime Error (Other)",
+	StatusInternalError:     "Internal Error",
+	StatusExecFormatError:   "Exec Format Error",
+}
+
+var ErrUnknownToken = errors.New("unknown submission token")
+
+// ErrUnavailable means no backend can run code right now.
+var ErrUnavailable = errors.New("judge unavailable")
+
+// Submission and Result mirror the Judge0 submission format, which every
+// executor speaks so callers don't care which backend ran the code.
+type Submission struct {
+	LanguageID      int    `json:"language_id"`
+	SourceCode      string `json:"source_code"`
+	Stdin           string `json:"stdin,omitempty"`
+	ExpectedOutput  string `json:"expected_output,omitempty"`
+	CallbackURL     string `json:"callback_url,omitempty"`
+	CompilerOptions string `json:"compiler_options,omitempty"`
+
+	// Limits in Judge0's units, seconds and KB. Zero leaves the backend's default.
+	CPUTimeLimit  float64 `json:"cpu_time_limit,omitempty"`
+	WallTimeLimit float64 `json:"wall_time_limit,omitempty"`
+	MemoryLimit   int     `json:"memory_limit,omitempty"`
+
+	// ResultMarker is the marker a harnessed program reads from the first
+	// line of Stdin and prints its result after. Empty for stdio runs. Kept
+	// here to split the output, it's never sent.
+	ResultMarker string `json:"-"`
+}
+
+type Status struct {
+	ID          int    `json:"id"`
+	Description string `json:"description"`
+}
+
+type Result struct {
+	Stdout        *string `json:"stdout"`
+	Time          *string `json:"time"`
+	Memory        *int    `json:"memory"`
+	Stderr        *string `json:"stderr"`
+	Token         string  `json:"token"`
+	CompileOutput *string `json:"compile_output"`
+	Message       *string `json:"message"`
+	Status        Status  `json:"status"`
+
+	// What a harnessed program printed besides its result, which is all
+	// that's left in Stdout. Set by the judge, not the backend.
+	Log *string `json:"log,omitempty"`
+
+	// Set by Bound when the field was cut down to the output limit
+	StdoutTruncated        bool `json:"stdout_truncated,omitempty"`
+	StderrTruncated        bool `json:"stderr_truncated,omitempty"`
+	CompileOutputTruncated bool `json:"compile_output_truncated,omitempty"`
+}
+
+func (r Result) IsPending() bool {
+	return r.Status.ID == StatusInQueue || r.Status.ID == StatusProcessing
+}
+
+// DecodeBase64 decodes the text fields of a result Judge0 sent base64 encoded.
+func (r *Result) DecodeBase64() error {
+	for _, field := range []*string{r.Stdout, r.Stderr, r.CompileOutput, r.Message} {
+		if field == nil {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(*field))
+		if err != nil {
+			return fmt.Errorf("error decoding base64 field: %w", err)
+		}
+		*field = string(decoded)
+	}
+	return nil
+}
+
+// Bound makes the program's output safe to store, replacing bytes that
+// aren't UTF-8 and dropping NULs, then cuts stdout, stderr and compile output
+// down to limit bytes each. A limit of 0 or less keeps everything.
+func (r *Result) Bound(limit int) {
+	r.StdoutTruncated = boundField(r.Stdout, limit) || r.StdoutTruncated
+	r.StderrTruncated = boundField(r.Stderr, limit) || r.StderrTruncated
+	r.CompileOutputTruncated = boundField(r.CompileOutput, limit) || r.CompileOutputTruncated
+}
+
+func boundField(field *string, limit int) bool {
+	if field == nil {
+		return false
+	}
+
+	value := strings.ReplaceAll(strings.ToValidUTF8(*field, "\uFFFD"), "\x00", "")
+	truncated := limit > 0 && len(value) > limit
+	if truncated {
+		// Don't cut a character in half
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(value[cut]) {
+			cut--
+		}
+		value = value[:cut]
+	}
+
+	*field = value
+	return truncated
+}
+
+type CodeExecutor interface {
+	// SubmitBatch queues the submissions and returns one token per submission, in order.
+	SubmitBatch(ctx context.Context, submissions []Submission) ([]string, error)
+	// GetBatchResults returns the current state of each token without waiting.
+	GetBatchResults(ctx context.Context, tokens []string) ([]Result, error)
+	// Run executes a single submission and waits for it to finish.
+	Run(ctx context.Context, submission Submission) (Result, error)
+}
+
+// Available reports whether the executor can take new submissions.
+// Executors that don't track their backends' health always can.
+func Available(codeExecutor CodeExecutor) bool {
+	health, ok := codeExecutor.(interface{ Available() bool })
+	return !ok || health.Available()
+}
+
+// AwaitBatch polls the executor with backoff until every token has finished
+// or the context is done. onResult, if set, is called once per token as soon
+// as that token finishes.
+func AwaitBatch(ctx context.Context, executor CodeExecutor, tokens []string, onResult func(index int, result Result)) ([]Result, error) {
+	baseDelay := 500 * time.Millisecond
+	attempt := 0
+	reported := make([]bool, len(tokens))
+
+	for {
+		// First check if the context is done or not (timeout or cancellation)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		results, err := executor.GetBatchResults(ctx, tokens)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+
+		// Check if all result are done processing
+		allComplete := true
+		for i, result := range results {
+			if result.IsPending() {
+				allComplete = false
+				continue
+			}
+
+			if !reported[i] && onResult != nil {
+				onResult(i, result)
+			}
+			reported[i] = true
+		}
+
+		if allComplete {
+			return results, nil
+		}
+
+		if err := wait(ctx, backoff(baseDelay, attempt)); err != nil {
+			return nil, err
+		}
+
+		attempt++
+	}
+}
+
+func backoff(baseDelay time.Duration, attempt int) time.Duration {
+	delay := time.Duration(float64(baseDelay) * (1.5 * float64(attempt)))
+	if delay > 2*time.Second {
+		delay = 2 * time.Second // Cap at 2 seconds
+	}
+	return delay
+}
+
+func wait(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,625 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	judge0RequestTimeout   = 5 * time.Second
+	judge0ProbeTimeout     = 2 * time.Second
+	judge0BreakerThreshold = 5
+	judge0BreakerCooldown  = 30 * time.Second
+	judge0TokenTTL         = 10 * time.Minute
+	// Batches of one request that may be in flight at once
+	judge0MaxInFlight = 4
+)
+
+// Judge0Backend is one Judge0 server. Weight is its share of new
+// submissions relative to the other backends.
+type Judge0Backend struct {
+	URL    string
+	Weight int
+}
+
+// ParseJudge0Backends reads a comma separated list of Judge0 base URLs, each
+// optionally followed by |weight, e.g. "http://judge-a:2358|3,http://judge-b:2358".
+func ParseJudge0Backends(spec string) ([]Judge0Backend, error) {
+	var backends []Judge0Backend
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		backend := Judge0Backend{URL: entry, Weight: 1}
+		if i := strings.LastIndex(entry, "|"); i >= 0 {
+			weight, err := strconv.Atoi(strings.TrimSpace(entry[i+1:]))
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid weight for judge0 backend %q", entry)
+			}
+			backend.URL = strings.TrimSpace(entry[:i])
+			backend.Weight = weight
+		}
+
+		backend.URL = strings.TrimRight(backend.URL, "/")
+		backends = append(backends, backend)
+	}
+
+	if len(backends) == 0 {
+		return nil, errors.New("no judge0 backends configured")
+	}
+
+	return backends, nil
+}
+
+type judge0Backend struct {
+	url     string
+	weight  int
+	breaker *breaker
+	healthy atomic.Bool
+}
+
+type judge0Token struct {
+	backend   *judge0Backend
+	createdAt time.Time
+}
+
+// Judge0Executor spreads submissions over one or more Judge0 servers. New
+// batches go to a healthy backend picked by weight, falling over to the
+// next one if it fails, and each token is polled on the backend that issued
+// it. Every backend has a circuit breaker, so a dead one fails fast with
+// ErrUnavailable instead of tying up requests until they time out.
+//
+// Everything travels base64 encoded, so programs printing bytes that aren't
+// UTF-8 don't break Judge0's JSON, and every result is bounded to
+// outputLimit bytes per output. Batches larger than batchSize, Judge0's
+// max_submission_batch_size, are split up and merged back in order.
+type Judge0Executor struct {
+	backends    []*judge0Backend
+	client      *http.Client
+	batchSize   int
+	outputLimit int
+	logger      *log.Logger
+
+	mu     sync.Mutex
+	tokens map[string]judge0Token
+}
+
+func NewJudge0Executor(backends []Judge0Backend, batchSize int, outputLimit int, logger *log.Logger) *Judge0Executor {
+	// One client for every request, so connections to each backend are reused
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 32
+
+	je := &Judge0Executor{
+		client: &http.Client{
+			Timeout:   judge0RequestTimeout,
+			Transport: transport,
+		},
+		batchSize:   batchSize,
+		outputLimit: outputLimit,
+		logger:      logger,
+		tokens:      make(map[string]judge0Token),
+	}
+
+	for _, backend := range backends {
+		b := &judge0Backend{
+			url:     strings.TrimRight(backend.URL, "/"),
+			weight:  max(backend.Weight, 1),
+			breaker: newBreaker(judge0BreakerThreshold, judge0BreakerCooldown),
+		}
+		// Assume it's up until a probe says otherwise
+		b.healthy.Store(true)
+		je.backends = append(je.backends, b)
+	}
+
+	return je
+}
+
+// StartHealthChecks probes every backend's /about endpoint each interval
+// until the context is done. Backends failing the probe get no new
+// submissions until they pass again.
+func (je *Judge0Executor) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			for _, backend := range je.backends {
+				je.probe(ctx, backend)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (je *Judge0Executor) probe(ctx context.Context, backend *judge0Backend) {
+	ctx, cancel := context.WithTimeout(ctx, judge0ProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, backend.url+"/about", nil)
+	if err == nil {
+		var resp *http.Response
+		resp, err = je.client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode >= http.StatusBadRequest {
+				err = fmt.Errorf("status %d", resp.StatusCode)
+			}
+		}
+	}
+
+	healthy := err == nil
+	if backend.healthy.Swap(healthy) != healthy {
+		if healthy {
+			je.logger.Println("Judge0 backend is back up", backend.url)
+		} else {
+			je.logger.Println("Judge0 backend failed its health check", backend.url, err)
+		}
+	}
+}
+
+// Available reports whether any backend can take new submissions.
+func (je *Judge0Executor) Available() bool {
+	for _, backend := range je.backends {
+		if backend.healthy.Load() && backend.breaker.ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// candidates returns the backends that can take new submissions, in a
+// weighted random order.
+func (je *Judge0Executor) candidates() []*judge0Backend {
+	var ready []*judge0Backend
+	total := 0
+	for _, backend := range je.backends {
+		if backend.healthy.Load() && backend.breaker.ready() {
+			ready = append(ready, backend)
+			total += backend.weight
+		}
+	}
+
+	ordered := make([]*judge0Backend, 0, len(ready))
+	for len(ready) > 0 {
+		n := rand.IntN(total)
+		for i, backend := range ready {
+			n -= backend.weight
+			if n < 0 {
+				ordered = append(ordered, backend)
+				total -= backend.weight
+				ready = append(ready[:i], ready[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return ordered
+}
+
+// submit sends the request to the first candidate backend that takes it.
+// Only failures of the backend itself move on to the next one, a request
+// Judge0 rejects would be rejected by all of them.
+func (je *Judge0Executor) submit(ctx context.Context, path string, body any, out any) (*judge0Backend, error) {
+	err := ErrUnavailable
+	for _, backend := range je.candidates() {
+		err = je.do(ctx, backend, http.MethodPost, path, body, out)
+		if err == nil {
+			return backend, nil
+		}
+
+		var statusErr *judge0StatusError
+		if ctx.Err() != nil || (errors.As(err, &statusErr) && statusErr.status < http.StatusInternalServerError) {
+			return nil, err
+		}
+	}
+
+	if !errors.Is(err, ErrUnavailable) {
+		err = fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return nil, err
+}
+
+func (je *Judge0Executor) remember(backend *judge0Backend, tokens []string) {
+	je.mu.Lock()
+	defer je.mu.Unlock()
+
+	// Tokens resolved by callbacks are never polled, so clear out old ones here
+	for token, entry := range je.tokens {
+		if time.Since(entry.createdAt) > judge0TokenTTL {
+			delete(je.tokens, token)
+		}
+	}
+
+	for _, token := range tokens {
+		je.tokens[token] = judge0Token{backend: backend, createdAt: time.Now()}
+	}
+}
+
+func (je *Judge0Executor) forget(token string) {
+	je.mu.Lock()
+	defer je.mu.Unlock()
+
+	delete(je.tokens, token)
+}
+
+// backendFor finds the backend that issued the token. Tokens from before a
+// restart are unknown, which only matters with more than one backend.
+func (je *Judge0Executor) backendFor(token string) (*judge0Backend, error) {
+	je.mu.Lock()
+	defer je.mu.Unlock()
+
+	if entry, ok := je.tokens[token]; ok {
+		return entry.backend, nil
+	}
+
+	if len(je.backends) == 1 {
+		return je.backends[0], nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownToken, token)
+}
+
+type judge0BatchRequest struct {
+	Submissions []Submission `json:"submissions"`
+}
+
+// SubmitBatch splits the submissions into batches Judge0 accepts and sends
+// them concurrently. Each batch may land on a different backend.
+func (je *Judge0Executor) SubmitBatch(ctx context.Context, submissions []Submission) ([]string, error) {
+	tokens := make([]string, len(submissions))
+	chunks := chunk(len(submissions), je.batchSize)
+
+	err := inParallel(ctx, len(chunks), func(ctx context.Context, c int) error {
+		start, end := chunks[c][0], chunks[c][1]
+		return je.submitBatch(ctx, submissions[start:end], tokens[start:end])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (je *Judge0Executor) submitBatch(ctx context.Context, submissions []Submission, tokens []string) error {
+	var batchResponse []struct {
+		Token string `json:"token"`
+	}
+
+	encoded := make([]Submission, len(submissions))
+	for i, submission := range submissions {
+		encoded[i] = encodeSubmission(submission)
+	}
+
+	backend, err := je.submit(ctx, "/submissions/batch?base64_encoded=true&wait=false", judge0BatchRequest{Submissions: encoded}, &batchResponse)
+	if err != nil {
+		return fmt.Errorf("error submitting judge0 batch request: %w", err)
+	}
+
+	if len(batchResponse) != len(submissions) {
+		return fmt.Errorf("expected %d tokens, got %d", len(submissions), len(batchResponse))
+	}
+
+	for i, submission := range batchResponse {
+		if submission.Token == "" {
+			return fmt.Errorf("judge0 rejected submission %d", i)
+		}
+		tokens[i] = submission.Token
+	}
+
+	je.remember(backend, tokens)
+	return nil
+}
+
+// GetBatchResults asks each backend about its own tokens, in batches no
+// larger than the ones it takes, all concurrently.
+func (je *Judge0Executor) GetBatchResults(ctx context.Context, tokens []string) ([]Result, error) {
+	// A batch always comes from one backend, but the tokens asked about
+	// together needn't
+	var order []*judge0Backend
+	groups := make(map[*judge0Backend][]int)
+	for i, token := range tokens {
+		backend, err := je.backendFor(token)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := groups[backend]; !ok {
+			order = append(order, backend)
+		}
+		groups[backend] = append(groups[backend], i)
+	}
+
+	type poll struct {
+		backend *judge0Backend
+		indexes []int
+	}
+
+	var polls []poll
+	for _, backend := range order {
+		indexes := groups[backend]
+		for _, c := range chunk(len(indexes), je.batchSize) {
+			polls = append(polls, poll{backend: backend, indexes: indexes[c[0]:c[1]]})
+		}
+	}
+
+	results := make([]Result, len(tokens))
+	err := inParallel(ctx, len(polls), func(ctx context.Context, p int) error {
+		return je.getBatchResults(ctx, polls[p].backend, polls[p].indexes, tokens, results)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// getBatchResults fetches the tokens at indexes from the backend into the
+// same indexes of results.
+func (je *Judge0Executor) getBatchResults(ctx context.Context, backend *judge0Backend, indexes []int, tokens []string, results []Result) error {
+	batchTokens := make([]string, len(indexes))
+	for j, i := range indexes {
+		batchTokens[j] = tokens[i]
+	}
+
+	var judge0Response struct {
+		Submissions []Result `json:"submissions"`
+	}
+
+	path := fmt.Sprintf("/submissions/batch?tokens=%s&base64_encoded=true", strings.Join(batchTokens, ","))
+	err := je.do(ctx, backend, http.MethodGet, path, nil, &judge0Response)
+	if err != nil {
+		return fmt.Errorf("error getting judge0 batch results: %w", err)
+	}
+
+	if len(judge0Response.Submissions) != len(batchTokens) {
+		return fmt.Errorf("expected %d results, got %d", len(batchTokens), len(judge0Response.Submissions))
+	}
+
+	for j, i := range indexes {
+		results[i] = judge0Response.Submissions[j]
+		err := je.decode(&results[i])
+		if err != nil {
+			return err
+		}
+
+		if !results[i].IsPending() {
+			je.forget(tokens[i])
+		}
+	}
+
+	return nil
+}
+
+// chunk splits n items into [start, end) ranges of at most size items. A
+// size of 0 or less keeps them in one range.
+func chunk(n int, size int) [][2]int {
+	if size <= 0 {
+		size = max(n, 1)
+	}
+
+	var chunks [][2]int
+	for start := 0; start < n; start += size {
+		chunks = append(chunks, [2]int{start, min(start+size, n)})
+	}
+	return chunks
+}
+
+// inParallel calls fn for 0 to n-1 with at most judge0MaxInFlight calls
+// running at once, and returns the first error any of them returned. The
+// first error cancels the context fn gets, and calls not started by then
+// are skipped, so a failed batch doesn't keep sending the rest of it.
+func inParallel(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	if n == 1 {
+		return fn(ctx, 0)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr, skipped error
+	slots := make(chan struct{}, judge0MaxInFlight)
+
+	for i := range n {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			skipped = ctx.Err()
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			if err := fn(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+
+	wg.Wait()
+	if firstErr == nil {
+		// The caller's context ended before every call was started
+		return skipped
+	}
+	return firstErr
+}
+
+func (je *Judge0Executor) Run(ctx context.Context, submission Submission) (Result, error) {
+	var response struct {
+		Token string `json:"token"`
+	}
+
+	backend, err := je.submit(ctx, "/submissions?base64_encoded=true&wait=false", encodeSubmission(submission), &response)
+	if err != nil {
+		return Result{}, fmt.Errorf("error submitting judge0 run request: %w", err)
+	}
+
+	baseDelay := 500 * time.Millisecond
+	attempt := 0
+	for {
+		// Check if context is done (timeout or cancellation)
+		select {
+		case <-ctx.Done():
+			return Result{}, ctx.Err()
+		default:
+		}
+
+		var result Result
+		err := je.do(ctx, backend, http.MethodGet, fmt.Sprintf("/submissions/%s?base64_encoded=true", response.Token), nil, &result)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Result{}, ctx.Err()
+			}
+			return Result{}, fmt.Errorf("error getting judge0 result: %w", err)
+		}
+
+		// Check if processing is complete
+		if !result.IsPending() {
+			err := je.decode(&result)
+			return result, err
+		}
+
+		if err := wait(ctx, backoff(baseDelay, attempt)); err != nil {
+			return Result{}, err
+		}
+
+		attempt++
+	}
+}
+
+func encodeSubmission(submission Submission) Submission {
+	submission.SourceCode = base64.StdEncoding.EncodeToString([]byte(submission.SourceCode))
+	if submission.Stdin != "" {
+		submission.Stdin = base64.StdEncoding.EncodeToString([]byte(submission.Stdin))
+	}
+	if submission.ExpectedOutput != "" {
+		submission.ExpectedOutput = base64.StdEncoding.EncodeToString([]byte(submission.ExpectedOutput))
+	}
+	return submission
+}
+
+func (je *Judge0Executor) decode(result *Result) error {
+	err := result.DecodeBase64()
+	if err != nil {
+		return fmt.Errorf("error decoding judge0 result %s: %w", result.Token, err)
+	}
+
+	result.Bound(je.outputLimit)
+	return nil
+}
+
+type judge0StatusError struct {
+	status int
+	body   string
+}
+
+func (e *judge0StatusError) Error() string {
+	return fmt.Sprintf("judge0 responded with status %d: %s", e.status, e.body)
+}
+
+// do sends one request to the backend. Network errors and 5xx responses
+// count against the backend's circuit breaker, anything else means it's up.
+// Only new submissions wait on the breaker; polls for tokens the backend
+// already holds always go out, or a half open breaker would lose them.
+func (je *Judge0Executor) do(ctx context.Context, backend *judge0Backend, method string, path string, body any, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error marshalling request body: %w", err)
+		}
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, backend.url+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	gated := method == http.MethodPost
+	if gated && !backend.breaker.acquire() {
+		return fmt.Errorf("%w: %s", ErrUnavailable, backend.url)
+	}
+
+	resp, err := je.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			if gated {
+				backend.breaker.release()
+			}
+		} else {
+			je.failed(backend, err)
+		}
+		return fmt.Errorf("error sending request: %w", err)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		je.failed(backend, err)
+		return fmt.Errorf("error reading response body: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		err := &judge0StatusError{status: resp.StatusCode, body: string(respBody)}
+		je.failed(backend, err)
+		return err
+	}
+
+	backend.breaker.success()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &judge0StatusError{status: resp.StatusCode, body: string(respBody)}
+	}
+
+	err = json.Unmarshal(respBody, out)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling response body: %w, raw response: %s", err, string(respBody))
+	}
+
+	return nil
+}
+
+func (je *Judge0Executor) failed(backend *judge0Backend, err error) {
+	if backend.breaker.failure() {
+		je.logger.Println("Judge0 backend failing, pausing requests to it", backend.url, err)
+	}
+}
@@ -0,0 +1,165 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		size int
+		want [][2]int
+	}{
+		{"empty", 0, 20, nil},
+		{"fits in one", 5, 20, [][2]int{{0, 5}}},
+		{"exact multiple", 6, 3, [][2]int{{0, 3}, {3, 6}}},
+		{"remainder", 7, 3, [][2]int{{0, 3}, {3, 6}, {6, 7}}},
+		{"size of one", 3, 1, [][2]int{{0, 1}, {1, 2}, {2, 3}}},
+		{"no size keeps one range", 7, 0, [][2]int{{0, 7}}},
+		{"negative size keeps one range", 7, -1, [][2]int{{0, 7}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chunk(tt.n, tt.size); !slices.Equal(got, tt.want) {
+				t.Errorf("chunk(%d, %d) = %v, want %v", tt.n, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInParallel(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name string
+		n    int
+		// fail is the call that returns errFailed, -1 for none
+		fail    int
+		wantErr error
+	}{
+		{"single call", 1, -1, nil},
+		{"single call fails", 1, 0, errFailed},
+		{"all succeed", 10, -1, nil},
+		{"first error is returned", 10, 3, errFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			called := make([]bool, tt.n)
+
+			err := inParallel(context.Background(), tt.n, func(ctx context.Context, i int) error {
+				mu.Lock()
+				called[i] = true
+				mu.Unlock()
+
+				if i == tt.fail {
+					return errFailed
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("inParallel() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.fail < 0 && slices.Contains(called, false) {
+				t.Errorf("not every call was made: %v", called)
+			}
+		})
+	}
+}
+
+func TestInParallelStopsAfterError(t *testing.T) {
+	errFailed := errors.New("failed")
+	var calls, running, most atomic.Int32
+
+	// The first call fails while the others are still waiting on the
+	// context, so no call after the first slots fill can start.
+	err := inParallel(context.Background(), 50, func(ctx context.Context, i int) error {
+		calls.Add(1)
+		now := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := most.Load()
+			if now <= old || most.CompareAndSwap(old, now) {
+				break
+			}
+		}
+
+		if i == 0 {
+			return errFailed
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("inParallel() error = %v, want %v", err, errFailed)
+	}
+	if got := most.Load(); got > judge0MaxInFlight {
+		t.Errorf("%d calls ran at once, want at most %d", got, judge0MaxInFlight)
+	}
+	if got := calls.Load(); got >= 50 {
+		t.Errorf("%d calls were made after the first failed, want the rest skipped", got)
+	}
+}
+
+func TestInParallelCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var calls atomic.Int32
+	err := inParallel(ctx, 10, func(ctx context.Context, i int) error {
+		calls.Add(1)
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("inParallel() error = %v, want %v", err, context.Canceled)
+	}
+	if got := calls.Load(); got != 0 {
+		t.Errorf("%d calls were made on a cancelled context", got)
+	}
+}
+
+func TestDoHalfOpenBreaker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	je := NewJudge0Executor([]Judge0Backend{{URL: server.URL}}, 20, 1024, log.New(io.Discard, "", 0))
+	backend := je.backends[0]
+
+	// Another request holds the half open trial
+	for range judge0BreakerThreshold {
+		backend.breaker.failure()
+	}
+	backend.breaker.openedAt = backend.breaker.openedAt.Add(-judge0BreakerCooldown)
+	if !backend.breaker.acquire() {
+		t.Fatal("breaker didn't let the trial through")
+	}
+
+	var out struct{}
+	ctx := context.Background()
+
+	if err := je.do(ctx, backend, http.MethodPost, "/submissions/batch", []int{}, &out); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("new submission error = %v, want ErrUnavailable", err)
+	}
+
+	// Tokens the backend already has are still polled
+	if err := je.do(ctx, backend, http.MethodGet, "/submissions/batch?tokens=a", nil, &out); err != nil {
+		t.Errorf("poll error = %v, want it sent", err)
+	}
+}
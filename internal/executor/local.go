@@ -0,0 +1,320 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type localLanguage struct {
+	SourceFile string
+	Compile    []string
+	Run        []string
+}
+
+// Keyed by Judge0 language ID. Each toolchain has to be installed locally.
+var localLanguages = map[int]localLanguage{
+	54: {SourceFile: "main.cpp", Compile: []string{"g++", "-O2", "-std=c++17", "-o", "main", "main.cpp"}, Run: []string{"./main"}},
+	60: {SourceFile: "main.go", Compile: []string{"go", "build", "-o", "main", "main.go"}, Run: []string{"./main"}},
+	62: {SourceFile: "Main.java", Compile: []string{"javac", "Main.java"}, Run: []string{"java", "-cp", ".", "Main"}},
+	63: {SourceFile: "main.js", Run: []string{"node", "main.js"}},
+	71: {SourceFile: "main.py", Run: []string{"python3", "main.py"}},
+	74: {SourceFile: "main.ts", Compile: []string{"tsc", "main.ts"}, Run: []string{"node", "main.js"}},
+}
+
+const (
+	localCPUTimeLimit  = 2 * time.Second
+	localWallTimeLimit = 5 * time.Second
+	localMemoryLimitKB = 256 * 1024
+	localFileSizeKB    = 10 * 1024
+	localResultTTL     = 10 * time.Minute
+)
+
+type localEntry struct {
+	result    Result
+	createdAt time.Time
+}
+
+// LocalExecutor runs submissions as plain processes on this machine, each in
+// its own temp dir with CPU, memory and file size rlimits and a wall clock
+// timeout. It's meant for development and tests, not for untrusted code in
+// production.
+type LocalExecutor struct {
+	workDir     string
+	outputLimit int
+	slots       chan struct{}
+
+	mu      sync.Mutex
+	results map[string]*localEntry
+}
+
+func NewLocalExecutor(workDir string, outputLimit int) *LocalExecutor {
+	if workDir == "" {
+		workDir = os.TempDir()
+	}
+
+	return &LocalExecutor{
+		workDir:     workDir,
+		outputLimit: outputLimit,
+		slots:       make(chan struct{}, runtime.NumCPU()),
+		results:     make(map[string]*localEntry),
+	}
+}
+
+func (le *LocalExecutor) SubmitBatch(ctx context.Context, submissions []Submission) ([]string, error) {
+	le.prune()
+
+	tokens := make([]string, len(submissions))
+	for i, submission := range submissions {
+		token := uuid.NewString()
+		tokens[i] = token
+
+		le.store(token, Result{Token: token, Status: status(StatusInQueue)})
+
+		go func(token string, submission Submission) {
+			le.store(token, le.execute(context.Background(), token, submission))
+		}(token, submission)
+	}
+
+	return tokens, nil
+}
+
+func (le *LocalExecutor) GetBatchResults(ctx context.Context, tokens []string) ([]Result, error) {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+
+	results := make([]Result, len(tokens))
+	for i, token := range tokens {
+		entry, ok := le.results[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownToken, token)
+		}
+		results[i] = entry.result
+	}
+
+	return results, nil
+}
+
+func (le *LocalExecutor) Run(ctx context.Context, submission Submission) (Result, error) {
+	result := le.execute(ctx, uuid.NewString(), submission)
+	if ctx.Err() != nil {
+		return Result{}, ctx.Err()
+	}
+	return result, nil
+}
+
+func (le *LocalExecutor) store(token string, result Result) {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+
+	le.results[token] = &localEntry{result: result, createdAt: time.Now()}
+}
+
+func (le *LocalExecutor) prune() {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+
+	for token, entry := range le.results {
+		if time.Since(entry.createdAt) > localResultTTL {
+			delete(le.results, token)
+		}
+	}
+}
+
+func (le *LocalExecutor) execute(ctx context.Context, token string, submission Submission) Result {
+	select {
+	case le.slots <- struct{}{}:
+		defer func() { <-le.slots }()
+	case <-ctx.Done():
+		return internalError(token, ctx.Err())
+	}
+
+	lang, ok := localLanguages[submission.LanguageID]
+	if !ok {
+		return internalError(token, fmt.Errorf("language %d is not supported by the local executor", submission.LanguageID))
+	}
+
+	dir, err := os.MkdirTemp(le.workDir, "async0-")
+	if err != nil {
+		return internalError(token, err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = os.WriteFile(filepath.Join(dir, lang.SourceFile), []byte(submission.SourceCode), 0o600)
+	if err != nil {
+		return internalError(token, err)
+	}
+
+	if len(lang.Compile) > 0 {
+		compileLimits := localLimits{cpu: localCPUTimeLimit * 4, wall: localWallTimeLimit * 4, memoryKB: localMemoryLimitKB * 2}
+		run := le.command(ctx, dir, lang.Compile, "", compileLimits)
+		if run.err != nil || run.exitCode != 0 {
+			compileOutput := run.stdout + run.stderr
+			result := Result{
+				Token:         token,
+				CompileOutput: &compileOutput,
+				Status:        status(StatusCompilationError),
+			}
+			result.Bound(le.outputLimit)
+			return result
+		}
+	}
+
+	run := le.command(ctx, dir, lang.Run, submission.Stdin, limitsFor(submission))
+	if run.err != nil {
+		return internalError(token, run.err)
+	}
+
+	elapsed := fmt.Sprintf("%.3f", run.elapsed.Seconds())
+	result := Result{
+		Token:  token,
+		Stdout: &run.stdout,
+		Stderr: &run.stderr,
+		Time:   &elapsed,
+		Memory: &run.memoryKB,
+	}
+
+	switch {
+	case run.timedOut:
+		result.Status = status(StatusTimeLimitExceeded)
+	case run.signalStatus != 0:
+		result.Status = status(run.signalStatus)
+	case run.exitCode != 0:
+		result.Status = status(StatusRuntimeNZEC)
+		message := fmt.Sprintf("Exited with error status %d", run.exitCode)
+		result.Message = &message
+	case submission.ExpectedOutput != "" && strings.TrimSpace(run.stdout) != strings.TrimSpace(submission.ExpectedOutput):
+		result.Status = status(StatusWrongAnswer)
+	default:
+		result.Status = status(StatusAccepted)
+	}
+
+	result.Bound(le.outputLimit)
+	return result
+}
+
+type localLimits struct {
+	cpu      time.Duration
+	wall     time.Duration
+	memoryKB int
+}
+
+// limitsFor honours the limits set on the submission the way Judge0 would,
+// falling back to the local defaults.
+func limitsFor(submission Submission) localLimits {
+	limits := localLimits{cpu: localCPUTimeLimit, wall: localWallTimeLimit, memoryKB: localMemoryLimitKB}
+
+	if submission.CPUTimeLimit > 0 {
+		limits.cpu = time.Duration(submission.CPUTimeLimit * float64(time.Second))
+	}
+
+	if submission.WallTimeLimit > 0 {
+		limits.wall = time.Duration(submission.WallTimeLimit * float64(time.Second))
+	}
+
+	if submission.MemoryLimit > 0 {
+		limits.memoryKB = submission.MemoryLimit
+	}
+
+	return limits
+}
+
+type localRun struct {
+	stdout   string
+	stderr   string
+	exitCode int
+	// signalStatus is the Judge0 status matching the signal that killed the
+	// process, or 0 if it exited normally.
+	signalStatus int
+	timedOut     bool
+	elapsed      time.Duration
+	memoryKB     int
+	err          error
+}
+
+func (le *LocalExecutor) command(ctx context.Context, dir string, args []string, stdin string, limits localLimits) localRun {
+	ctx, cancel := context.WithTimeout(ctx, limits.wall)
+	defer cancel()
+
+	// The shell sets the rlimits and then execs the program, so the limits
+	// apply to the program itself rather than to a wrapper process. ulimit
+	// only takes whole seconds of CPU time.
+	ulimits := fmt.Sprintf("ulimit -t %d; ulimit -d %d; ulimit -f %d; exec \"$@\"",
+		int(math.Ceil(limits.cpu.Seconds())), limits.memoryKB, localFileSizeKB)
+
+	cmd := exec.CommandContext(ctx, "sh", append([]string{"-c", ulimits, "sh"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + dir}
+	cmd.Stdin = strings.NewReader(stdin)
+	isolate(cmd)
+
+	// One byte over the limit is enough for Bound to see it was exceeded
+	stdout := &cappedBuffer{limit: le.outputLimit + 1}
+	stderr := &cappedBuffer{limit: le.outputLimit + 1}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	run := localRun{
+		elapsed:  time.Since(start),
+		timedOut: ctx.Err() == context.DeadlineExceeded,
+	}
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !run.timedOut {
+		run.err = err
+		return run
+	}
+
+	if cmd.ProcessState != nil {
+		run.exitCode = cmd.ProcessState.ExitCode()
+		run.signalStatus = signalStatus(cmd.ProcessState)
+		run.memoryKB = maxRSS(cmd.ProcessState)
+	}
+
+	run.stdout = stdout.String()
+	run.stderr = stderr.String()
+	return run
+}
+
+// cappedBuffer keeps the first limit bytes written to it and quietly drops
+// the rest, so a program flooding its output can't exhaust memory. A limit
+// of 1 or less keeps everything.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (cb *cappedBuffer) Write(p []byte) (int, error) {
+	if cb.limit > 1 {
+		room := max(cb.limit-cb.Len(), 0)
+		cb.Buffer.Write(p[:min(room, len(p))])
+		return len(p), nil
+	}
+	return cb.Buffer.Write(p)
+}
+
+func status(id int) Status {
+	return Status{ID: id, Description: StatusDescriptions[id]}
+}
+
+func internalError(token string, err error) Result {
+	message := err.Error()
+	return Result{
+		Token:   token,
+		Message: &message,
+		Status:  status(StatusInternalError),
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+)
+
+func isolate(cmd *exec.Cmd) {}
+
+func signalStatus(state *os.ProcessState) int {
+	if state.Success() || state.ExitCode() >= 0 {
+		return 0
+	}
+	return StatusRuntimeOther
+}
+
+func maxRSS(state *os.ProcessState) int {
+	return 0
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// isolate puts the process in its own group so a timeout kills anything it
+// spawned as well.
+func isolate(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+func signalStatus(state *os.ProcessState) int {
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() {
+		return 0
+	}
+
+	switch ws.Signal() {
+	case syscall.SIGXCPU, syscall.SIGKILL:
+		return StatusTimeLimitExceeded
+	case syscall.SIGSEGV:
+		return StatusRuntimeSIGSEGV
+	case syscall.SIGXFSZ:
+		return StatusRuntimeSIGXFSZ
+	case syscall.SIGFPE:
+		return StatusRuntimeSIGFPE
+	case syscall.SIGABRT:
+		return StatusRuntimeSIGABRT
+	default:
+		return StatusRuntimeOther
+	}
+}
+
+func maxRSS(state *os.ProcessState) int {
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// Maxrss is already in KB on linux, which is what Judge0 reports too
+	return int(usage.Maxrss)
+}
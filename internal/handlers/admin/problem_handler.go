@@ -1,13 +1,23 @@
 package admin
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/grvbrk/async0_server/internal/auth"
+	"github.com/grvbrk/async0_server/internal/checker"
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/harness"
+	"github.com/grvbrk/async0_server/internal/judge"
+	"github.com/grvbrk/async0_server/internal/languages"
 	"github.com/grvbrk/async0_server/internal/models"
 	"github.com/grvbrk/async0_server/internal/store/admin"
 	"github.com/grvbrk/async0_server/internal/utils"
@@ -15,13 +25,17 @@ import (
 
 type AdminProblemHandler struct {
 	AdminProblemStore admin.AdminProblemStore
+	Executor          executor.CodeExecutor
+	ResultCache       *judge.ResultCache
 	Logger            *log.Logger
 	Oauth             *auth.AdminGoogleOauth
 }
 
-func NewAdminProblemHandler(adminProblemStore admin.AdminProblemStore, logger *log.Logger, oauth *auth.AdminGoogleOauth) *AdminProblemHandler {
+func NewAdminProblemHandler(adminProblemStore admin.AdminProblemStore, codeExecutor executor.CodeExecutor, resultCache *judge.ResultCache, logger *log.Logger, oauth *auth.AdminGoogleOauth) *AdminProblemHandler {
 	return &AdminProblemHandler{
 		AdminProblemStore: adminProblemStore,
+		Executor:          codeExecutor,
+		ResultCache:       resultCache,
 		Logger:            logger,
 		Oauth:             oauth,
 	}
@@ -39,11 +53,43 @@ func (ap *AdminProblemHandler) HandlerGetAllProblems(w http.ResponseWriter, r *h
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": problems})
 }
 
+// TestCaseBody is one of a problem's testcases. On update one with the ID of
+// a stored testcase replaces it, the rest are added and any stored testcase
+// left out is deleted. Without is_sample a stored testcase stays as it was
+// and a new one is hidden.
 type TestCaseBody struct {
-	UI       string `json:"ui"`
-	Input    string `json:"input"`
-	Output   string `json:"output"`
-	Position int    `json:"position"`
+	ID          uuid.UUID `json:"id"`
+	UI          string    `json:"ui"`
+	Input       string    `json:"input"`
+	Output      string    `json:"output"`
+	Position    int       `json:"position"`
+	IsSample    *bool     `json:"is_sample"`
+	IsGenerated bool      `json:"is_generated"`
+	Seed        string    `json:"seed"`
+}
+
+// problemTestcases turns the body's testcases into models, taking is_sample
+// from the stored testcases when it's left out.
+func problemTestcases(body ProblemBody, samples map[uuid.UUID]bool) []models.Testcase {
+	var testcases []models.Testcase
+	for _, tc := range body.TestCases {
+		isSample := samples[tc.ID]
+		if tc.IsSample != nil {
+			isSample = *tc.IsSample
+		}
+
+		testcases = append(testcases, models.Testcase{
+			ID:          tc.ID,
+			UI:          tc.UI,
+			Input:       tc.Input,
+			Output:      tc.Output,
+			Position:    tc.Position,
+			IsSample:    isSample,
+			IsGenerated: tc.IsGenerated,
+			Seed:        tc.Seed,
+		})
+	}
+	return testcases
 }
 
 type SolutionBody struct {
@@ -51,6 +97,7 @@ type SolutionBody struct {
 	Hint            string `json:"hint"`
 	Description     string `json:"description"`
 	Code            string `json:"code"`
+	Language        string `json:"language"`
 	CodeExplanation string `json:"code_explanation"`
 	Notes           string `json:"notes"`
 	TimeComplexity  string `json:"time_complexity"`
@@ -62,87 +109,289 @@ type SolutionBody struct {
 }
 
 type ProblemBody struct {
-	Name          string         `json:"name"`
-	ProblemNumber *int           `json:"problem_number"`
-	Slug          string         `json:"slug"`
-	Description   string         `json:"description"`
-	Link          string         `json:"link"`
-	Difficulty    string         `json:"difficulty"`
-	StarterCode   any            `json:"starter_code"`
-	SolutionCode  any            `json:"solution_code"`
-	TimeLimit     int            `json:"time_limit"`
-	MemoryLimit   int            `json:"memory_limit"`
-	IsActive      bool           `json:"is_active"`
-	Topics        []string       `json:"topics"`
-	Lists         []string       `json:"lists"`
-	TestCases     []TestCaseBody `json:"testcases"`
-	Solutions     []SolutionBody `json:"solutions"`
+	Name          string             `json:"name"`
+	ProblemNumber *int               `json:"problem_number"`
+	Slug          string             `json:"slug"`
+	Description   string             `json:"description"`
+	Link          string             `json:"link"`
+	Difficulty    string             `json:"difficulty"`
+	StarterCode   models.StarterCode `json:"starter_code"`
+	SolutionCode  string             `json:"solution_code"`
+	SolutionLang  string             `json:"solution_language"`
+	Signature     *models.Signature  `json:"signature"`
+	Checker       string             `json:"checker"`
+	Tolerance     *float64           `json:"checker_tolerance"`
+	CheckerCode   string             `json:"checker_code"`
+	GeneratorCode string             `json:"generator_code"`
+	GeneratorLang string             `json:"generator_language"`
+	Seeds         models.Seeds       `json:"generator_seeds"`
+	FailFast      bool               `json:"fail_fast"`
+	IOMode        models.IOMode      `json:"io_mode"`
+	TimeLimit     int                `json:"time_limit"`
+	MemoryLimit   int                `json:"memory_limit"`
+	IsActive      bool               `json:"is_active"`
+	Topics        []string           `json:"topics"`
+	Lists         []string           `json:"lists"`
+	TestCases     []TestCaseBody     `json:"testcases"`
+	Solutions     []SolutionBody     `json:"solutions"`
 }
 
-func (ap *AdminProblemHandler) HandlerCreateProblem(w http.ResponseWriter, r *http.Request) {
-	var problemBody ProblemBody
-	err := json.NewDecoder(r.Body).Decode(&problemBody)
+// problemChecker defaults the checker to whitespace-insensitive and rejects
+// unknown modes and special judges without a checker program.
+func problemChecker(body ProblemBody) (checker.Mode, bool) {
+	mode := checker.Mode(body.Checker)
+	if mode == "" {
+		mode = checker.ModeWhitespace
+	}
+
+	if !mode.IsValid() {
+		return "", false
+	}
+
+	if mode == checker.ModeSpecial && body.CheckerCode == "" {
+		return "", false
+	}
+
+	if body.Tolerance != nil && *body.Tolerance <= 0 {
+		return "", false
+	}
+
+	return mode, true
+}
+
+// problemIOMode defaults the problem to function calls and rejects stdio
+// problems with a signature, their programs read the input themselves.
+func problemIOMode(body ProblemBody) (models.IOMode, bool) {
+	mode := body.IOMode
+	if mode == "" {
+		mode = models.IOModeFunction
+	}
+
+	if !mode.IsValid() {
+		return "", false
+	}
+
+	if mode == models.IOModeStdio && body.Signature != nil {
+		return "", false
+	}
+
+	return mode, true
+}
+
+// problemLanguages checks every starter code, the reference solution and the
+// solutions are in languages we know, defaulting the reference solution's
+// language.
+func problemLanguages(body ProblemBody) (string, bool) {
+	for slug := range body.StarterCode {
+		if _, err := languages.Get(slug); err != nil {
+			return "", false
+		}
+	}
+
+	language, err := languages.Get(body.SolutionLang)
 	if err != nil {
-		ap.Logger.Println("Error decoding problem body", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
-		return
+		return "", false
+	}
+
+	if body.GeneratorCode != "" {
+		if _, err := languages.Get(body.GeneratorLang); err != nil {
+			return "", false
+		}
+	}
+
+	for _, solution := range body.Solutions {
+		if solution.Language == "" {
+			continue
+		}
+		if _, err := languages.Get(solution.Language); err != nil {
+			return "", false
+		}
+	}
+
+	return language.Slug, true
+}
+
+// problemSignature checks the signature's types and that every testcase
+// input holds the arguments it declares.
+func problemSignature(body ProblemBody) error {
+	if body.Signature == nil {
+		return nil
+	}
+
+	if err := harness.Validate(*body.Signature); err != nil {
+		return err
+	}
+
+	for _, tc := range body.TestCases {
+		if err := harness.ValidateInput(*body.Signature, tc.Input); err != nil {
+			return fmt.Errorf("testcase %d: %w", tc.Position, err)
+		}
+	}
+
+	return nil
+}
+
+// How a save checks the solutions against the testcases, picked with the
+// validate query parameter. Strict refuses the save when one fails, warn
+// saves anyway and reports it.
+const (
+	validateStrict = "strict"
+	validateWarn   = "warn"
+)
+
+// Leaves room under the server's write timeout for the save itself
+const validationTimeout = 25 * time.Second
+
+// validateSolutions runs the reference solution and every active solution,
+// each in its own language, against the testcases.
+func (ap *AdminProblemHandler) validateSolutions(ctx context.Context, problem *models.Problem, testcases []models.Testcase, solutions []models.Solution) (judge.ValidationReport, error) {
+	var toValidate []judge.ValidationSolution
+	if problem.SolutionCode != "" {
+		toValidate = append(toValidate, judge.ValidationSolution{
+			Name:     "Reference solution",
+			Language: problem.SolutionLanguage,
+			Code:     problem.SolutionCode,
+		})
+	}
+
+	for _, solution := range solutions {
+		if !solution.IsActive || solution.Code == "" {
+			continue
+		}
+
+		toValidate = append(toValidate, judge.ValidationSolution{
+			Name:     solution.Title,
+			Language: solution.Language,
+			Code:     solution.Code,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, validationTimeout)
+	defer cancel()
+
+	return judge.ValidateSolutions(ctx, ap.Executor, problem, testcases, toValidate)
+}
+
+// checkSolutions validates the solutions when the request asks for it. It
+// writes the response and returns false when the save shouldn't go ahead.
+func (ap *AdminProblemHandler) checkSolutions(w http.ResponseWriter, r *http.Request, problem *models.Problem, testcases []models.Testcase, solutions []models.Solution) (*judge.ValidationReport, bool) {
+	mode := r.URL.Query().Get("validate")
+	if mode == "" {
+		return nil, true
+	}
+
+	if mode != validateStrict && mode != validateWarn {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Invalid validate mode"})
+		return nil, false
+	}
+
+	report, err := ap.validateSolutions(r.Context(), problem, testcases, solutions)
+	if errors.Is(err, executor.ErrUnavailable) {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.Envelope{"message": "Judge unavailable, try again shortly"})
+		return nil, false
+	}
+
+	// A solution in a language the problem can't be run in is a bad request too
+	if errors.Is(err, harness.ErrInvalidInput) || errors.Is(err, languages.ErrNoHarness) || errors.Is(err, harness.ErrUnsupportedLanguage) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": err.Error()})
+		return nil, false
+	}
+
+	if err != nil {
+		ap.Logger.Println("Error validating solutions", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		return nil, false
+	}
+
+	if !report.Passed && mode == validateStrict {
+		utils.WriteJSON(w, http.StatusUnprocessableEntity, utils.Envelope{"message": "Solutions failed some testcases", "report": report})
+		return nil, false
+	}
+
+	return &report, true
+}
+
+// problemRelations is what a problem body holds besides the problem itself.
+type problemRelations struct {
+	topicIDs  []uuid.UUID
+	listIDs   []uuid.UUID
+	testcases []models.Testcase
+	solutions []models.Solution
+}
+
+// parseProblemBody validates the body for creating or updating a problem.
+// samples are the stored testcases' is_sample, see problemTestcases. The
+// error's message is meant for the client.
+func parseProblemBody(body ProblemBody, samples map[uuid.UUID]bool) (models.Problem, problemRelations, error) {
+	checkerMode, ok := problemChecker(body)
+	if !ok {
+		return models.Problem{}, problemRelations{}, errors.New("Invalid checker")
+	}
+
+	solutionLanguage, ok := problemLanguages(body)
+	if !ok {
+		return models.Problem{}, problemRelations{}, errors.New("Unsupported language")
+	}
+
+	ioMode, ok := problemIOMode(body)
+	if !ok {
+		return models.Problem{}, problemRelations{}, errors.New("Invalid io mode")
+	}
+
+	if err := problemSignature(body); err != nil {
+		return models.Problem{}, problemRelations{}, err
 	}
 
 	problem := models.Problem{
-		Name:          problemBody.Name,
-		ProblemNumber: problemBody.ProblemNumber,
-		Slug:          problemBody.Slug,
-		Description:   problemBody.Description,
-		Link:          problemBody.Link,
-		Difficulty:    problemBody.Difficulty,
-		StarterCode:   problemBody.StarterCode,
-		SolutionCode:  problemBody.SolutionCode,
-		TimeLimit:     problemBody.TimeLimit,
-		MemoryLimit:   problemBody.MemoryLimit,
-		IsActive:      problemBody.IsActive,
-	}
-
-	var topicIDs []uuid.UUID
-	for _, topicSlug := range problemBody.Topics {
+		Name:              body.Name,
+		ProblemNumber:     body.ProblemNumber,
+		Slug:              body.Slug,
+		Description:       body.Description,
+		Link:              body.Link,
+		Difficulty:        body.Difficulty,
+		StarterCode:       body.StarterCode,
+		SolutionCode:      body.SolutionCode,
+		SolutionLanguage:  solutionLanguage,
+		Signature:         body.Signature,
+		Checker:           string(checkerMode),
+		CheckerTolerance:  body.Tolerance,
+		CheckerCode:       body.CheckerCode,
+		GeneratorCode:     body.GeneratorCode,
+		GeneratorLanguage: body.GeneratorLang,
+		GeneratorSeeds:    body.Seeds,
+		FailFast:          body.FailFast,
+		IOMode:            ioMode,
+		TimeLimit:         body.TimeLimit,
+		MemoryLimit:       body.MemoryLimit,
+		IsActive:          body.IsActive,
+	}
+
+	var relations problemRelations
+	for _, topicSlug := range body.Topics {
 		topicID, err := uuid.Parse(topicSlug)
 		if err != nil {
-			ap.Logger.Println("Error parsing topic slug", err)
-			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
-			return
+			return models.Problem{}, problemRelations{}, fmt.Errorf("Invalid topic %q", topicSlug)
 		}
-
-		topicIDs = append(topicIDs, topicID)
+		relations.topicIDs = append(relations.topicIDs, topicID)
 	}
 
-	var listIDs []uuid.UUID
-	for _, listSlug := range problemBody.Lists {
+	for _, listSlug := range body.Lists {
 		listID, err := uuid.Parse(listSlug)
 		if err != nil {
-			ap.Logger.Println("Error parsing list slug", err)
-			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
-			return
+			return models.Problem{}, problemRelations{}, fmt.Errorf("Invalid list %q", listSlug)
 		}
-
-		listIDs = append(listIDs, listID)
+		relations.listIDs = append(relations.listIDs, listID)
 	}
 
-	var testcases []models.Testcase
-	for _, tc := range problemBody.TestCases {
-		testcases = append(testcases, models.Testcase{
-			UI:       tc.UI,
-			Input:    tc.Input,
-			Output:   tc.Output,
-			Position: tc.Position,
-		})
-	}
+	relations.testcases = problemTestcases(body, samples)
 
-	var solutions []models.Solution
-	for _, solution := range problemBody.Solutions {
-		solutions = append(solutions, models.Solution{
+	for _, solution := range body.Solutions {
+		relations.solutions = append(relations.solutions, models.Solution{
 			Title:           solution.Title,
 			Hint:            solution.Hint,
 			Description:     solution.Description,
 			Code:            solution.Code,
+			Language:        cmp.Or(solution.Language, solutionLanguage),
 			CodeExplanation: solution.CodeExplanation,
 			Notes:           solution.Notes,
 			TimeComplexity:  solution.TimeComplexity,
@@ -154,14 +403,42 @@ func (ap *AdminProblemHandler) HandlerCreateProblem(w http.ResponseWriter, r *ht
 		})
 	}
 
-	err = ap.AdminProblemStore.CreateProblem(problem, listIDs, topicIDs, testcases, solutions)
+	return problem, relations, nil
+}
+
+func (ap *AdminProblemHandler) HandlerCreateProblem(w http.ResponseWriter, r *http.Request) {
+	var problemBody ProblemBody
+	err := json.NewDecoder(r.Body).Decode(&problemBody)
+	if err != nil {
+		ap.Logger.Println("Error decoding problem body", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
+	}
+
+	problem, parsed, err := parseProblemBody(problemBody, nil)
+	if err != nil {
+		ap.Logger.Println("Invalid problem body", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": err.Error()})
+		return
+	}
+	report, ok := ap.checkSolutions(w, r, &problem, parsed.testcases, parsed.solutions)
+	if !ok {
+		return
+	}
+
+	err = ap.AdminProblemStore.CreateProblem(problem, parsed.listIDs, parsed.topicIDs, parsed.testcases, parsed.solutions)
 	if err != nil {
 		ap.Logger.Println("Error creating problem", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Successfully created problem"})
+	response := utils.Envelope{"message": "Successfully created problem"}
+	if report != nil {
+		response["report"] = report
+	}
+
+	utils.WriteJSON(w, http.StatusOK, response)
 }
 
 func (ap *AdminProblemHandler) HandlerGetProblemByID(w http.ResponseWriter, r *http.Request) {
@@ -175,6 +452,11 @@ func (ap *AdminProblemHandler) HandlerGetProblemByID(w http.ResponseWriter, r *h
 	}
 
 	problem, err := ap.AdminProblemStore.GetProblemByID(problemID)
+	if errors.Is(err, admin.ErrProblemNotFound) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Problem not found"})
+		return
+	}
+
 	if err != nil {
 		ap.Logger.Println("Error getting problem by id", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
@@ -201,78 +483,101 @@ func (ap *AdminProblemHandler) HandlerUpdateProblem(w http.ResponseWriter, r *ht
 		return
 	}
 
-	problem := models.Problem{
-		Name:          problemBody.Name,
-		ProblemNumber: problemBody.ProblemNumber,
-		Slug:          problemBody.Slug,
-		Description:   problemBody.Description,
-		Link:          problemBody.Link,
-		Difficulty:    problemBody.Difficulty,
-		StarterCode:   problemBody.StarterCode,
-		SolutionCode:  problemBody.SolutionCode,
-		TimeLimit:     problemBody.TimeLimit,
-		MemoryLimit:   problemBody.MemoryLimit,
-		IsActive:      problemBody.IsActive,
-	}
-
-	var topicIDs []uuid.UUID
-	for _, topicSlug := range problemBody.Topics {
-		topicID, err := uuid.Parse(topicSlug)
-		if err != nil {
-			ap.Logger.Println("Error parsing topic slug", err)
-			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
-			return
-		}
+	samples, err := ap.AdminProblemStore.GetTestcaseSamples(problemID)
+	if err != nil {
+		ap.Logger.Println("Error getting testcase samples", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		return
+	}
+
+	problem, parsed, err := parseProblemBody(problemBody, samples)
+	if err != nil {
+		ap.Logger.Println("Invalid problem body", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": err.Error()})
+		return
+	}
+	report, ok := ap.checkSolutions(w, r, &problem, parsed.testcases, parsed.solutions)
+	if !ok {
+		return
+	}
 
-		topicIDs = append(topicIDs, topicID)
+	err = ap.AdminProblemStore.UpdateProblem(problemID, problem, parsed.listIDs, parsed.topicIDs, parsed.testcases, parsed.solutions)
+	if err != nil {
+		ap.Logger.Println("Error updating problem", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		return
 	}
 
-	var listIDs []uuid.UUID
-	for _, listSlug := range problemBody.Lists {
-		listID, err := uuid.Parse(listSlug)
-		if err != nil {
-			ap.Logger.Println("Error parsing list slug", err)
-			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
-			return
+	// Cached results were judged against the old testcases and limits
+	if ap.ResultCache != nil {
+		if err := ap.ResultCache.Invalidate(r.Context(), problemID); err != nil {
+			ap.Logger.Println("Error invalidating result cache", err)
 		}
+	}
 
-		listIDs = append(listIDs, listID)
+	response := utils.Envelope{"message": "Successfully updated problem"}
+	if report != nil {
+		response["report"] = report
 	}
 
-	var testcases []models.Testcase
-	for _, tc := range problemBody.TestCases {
-		testcases = append(testcases, models.Testcase{
-			UI:       tc.UI,
-			Input:    tc.Input,
-			Output:   tc.Output,
-			Position: tc.Position,
-		})
+	utils.WriteJSON(w, http.StatusOK, response)
+}
+
+// HandlerGenerateTestcases runs the problem's generator and reference
+// solution and replaces its generated testcases with the result.
+func (ap *AdminProblemHandler) HandlerGenerateTestcases(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	problemID, err := uuid.Parse(id)
+	if err != nil {
+		ap.Logger.Println("Error parsing problem id", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
 	}
 
-	var solutions []models.Solution
-	for _, solution := range problemBody.Solutions {
-		solutions = append(solutions, models.Solution{
-			Title:           solution.Title,
-			Hint:            solution.Hint,
-			Description:     solution.Description,
-			Code:            solution.Code,
-			CodeExplanation: solution.CodeExplanation,
-			Notes:           solution.Notes,
-			TimeComplexity:  solution.TimeComplexity,
-			SpaceComplexity: solution.SpaceComplexity,
-			DifficultyLevel: solution.DifficultyLevel,
-			DisplayOrder:    solution.DisplayOrder,
-			Author:          solution.Author,
-			IsActive:        solution.IsActive,
-		})
+	problem, err := ap.AdminProblemStore.GetProblemByID(problemID)
+	if errors.Is(err, admin.ErrProblemNotFound) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Problem not found"})
+		return
 	}
 
-	err = ap.AdminProblemStore.UpdateProblem(problemID, problem, listIDs, topicIDs, testcases, solutions)
 	if err != nil {
-		ap.Logger.Println("Error updating problem", err)
+		ap.Logger.Println("Error getting problem by id", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Successfully updated problem"})
+	ctx, cancel := context.WithTimeout(r.Context(), validationTimeout)
+	defer cancel()
+
+	testcases, err := judge.GenerateTestcases(ctx, ap.Executor, &problem)
+	if errors.Is(err, executor.ErrUnavailable) {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.Envelope{"message": "Judge unavailable, try again shortly"})
+		return
+	}
+
+	if errors.Is(err, judge.ErrGeneration) || errors.Is(err, harness.ErrInvalidInput) {
+		utils.WriteJSON(w, http.StatusUnprocessableEntity, utils.Envelope{"message": err.Error()})
+		return
+	}
+
+	if err != nil {
+		ap.Logger.Println("Error generating testcases", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		return
+	}
+
+	err = ap.AdminProblemStore.ReplaceGeneratedTestcases(problemID, testcases)
+	if err != nil {
+		ap.Logger.Println("Error saving generated testcases", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		return
+	}
+
+	if ap.ResultCache != nil {
+		if err := ap.ResultCache.Invalidate(r.Context(), problemID); err != nil {
+			ap.Logger.Println("Error invalidating result cache", err)
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": fmt.Sprintf("Generated %d testcases", len(testcases)), "data": testcases})
 }
@@ -0,0 +1,92 @@
+package admin
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestProblemTestcasesIsSample(t *testing.T) {
+	sample, hidden := uuid.New(), uuid.New()
+	samples := map[uuid.UUID]bool{sample: true, hidden: false}
+	yes, no := true, false
+
+	tests := []struct {
+		name     string
+		id       uuid.UUID
+		isSample *bool
+		want     bool
+	}{
+		{"stored sample left out", sample, nil, true},
+		{"stored hidden left out", hidden, nil, false},
+		{"stored sample hidden", sample, &no, false},
+		{"stored hidden made a sample", hidden, &yes, true},
+		{"new testcase left out", uuid.Nil, nil, false},
+		{"new sample", uuid.Nil, &yes, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := ProblemBody{TestCases: []TestCaseBody{{ID: tt.id, IsSample: tt.isSample}}}
+
+			got := problemTestcases(body, samples)
+			if got[0].IsSample != tt.want {
+				t.Errorf("IsSample = %v, want %v", got[0].IsSample, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProblemBody(t *testing.T) {
+	topicID := uuid.New()
+	valid := func() ProblemBody {
+		return ProblemBody{
+			Name:         "Two Sum",
+			SolutionLang: "python",
+			Topics:       []string{topicID.String()},
+			Solutions:    []SolutionBody{{Title: "Hash map"}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		change  func(b *ProblemBody)
+		wantErr string
+	}{
+		{name: "valid", change: func(b *ProblemBody) {}},
+		{name: "unknown checker", change: func(b *ProblemBody) { b.Checker = "fuzzy" }, wantErr: "Invalid checker"},
+		{name: "unknown language", change: func(b *ProblemBody) { b.SolutionLang = "cobol" }, wantErr: "Unsupported language"},
+		{name: "unknown io mode", change: func(b *ProblemBody) { b.IOMode = "files" }, wantErr: "Invalid io mode"},
+		{name: "bad topic id", change: func(b *ProblemBody) { b.Topics = []string{"arrays"} }, wantErr: `Invalid topic "arrays"`},
+		{name: "bad list id", change: func(b *ProblemBody) { b.Lists = []string{"blind-75"} }, wantErr: `Invalid list "blind-75"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := valid()
+			tt.change(&body)
+
+			problem, parsed, err := parseProblemBody(body, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("parseProblemBody() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProblemBody() error: %v", err)
+			}
+
+			if problem.Name != body.Name || problem.Checker == "" || problem.IOMode == "" {
+				t.Errorf("problem = %+v, want the name kept and defaults filled in", problem)
+			}
+			if len(parsed.topicIDs) != 1 || parsed.topicIDs[0] != topicID {
+				t.Errorf("topic ids = %v, want [%s]", parsed.topicIDs, topicID)
+			}
+			// Solutions without a language are in the reference solution's
+			if len(parsed.solutions) != 1 || parsed.solutions[0].Language != problem.SolutionLanguage {
+				t.Errorf("solutions = %+v, want one in %s", parsed.solutions, problem.SolutionLanguage)
+			}
+		})
+	}
+}
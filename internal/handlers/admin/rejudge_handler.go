@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/grvbrk/async0_server/internal/auth"
+	"github.com/grvbrk/async0_server/internal/judge"
+	"github.com/grvbrk/async0_server/internal/store"
+	"github.com/grvbrk/async0_server/internal/utils"
+)
+
+type AdminRejudgeHandler struct {
+	RejudgeStore store.RejudgeStore
+	JudgePool    *judge.Pool
+	Logger       *log.Logger
+	Oauth        *auth.AdminGoogleOauth
+}
+
+func NewAdminRejudgeHandler(rejudgeStore store.RejudgeStore, judgePool *judge.Pool, logger *log.Logger, oauth *auth.AdminGoogleOauth) *AdminRejudgeHandler {
+	return &AdminRejudgeHandler{
+		RejudgeStore: rejudgeStore,
+		JudgePool:    judgePool,
+		Logger:       logger,
+		Oauth:        oauth,
+	}
+}
+
+// RejudgeBody narrows a rejudge down to some of the problem's submissions,
+// an empty body rejudges all of them.
+type RejudgeBody struct {
+	SubmissionIDs []uuid.UUID `json:"submission_ids"`
+}
+
+func (ar *AdminRejudgeHandler) HandlerRejudgeProblem(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	problemID, err := uuid.Parse(id)
+	if err != nil {
+		ar.Logger.Println("Error parsing problem id", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
+	}
+
+	var rejudgeBody RejudgeBody
+	err = json.NewDecoder(r.Body).Decode(&rejudgeBody)
+	if err != nil && !errors.Is(err, io.EOF) {
+		ar.Logger.Println("Error decoding rejudge body", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
+	}
+
+	rejudge, err := ar.JudgePool.Rejudge(r.Context(), problemID, rejudgeBody.SubmissionIDs)
+	if errors.Is(err, store.ErrNothingToRejudge) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "No finished submissions to rejudge"})
+		return
+	}
+
+	if err != nil {
+		ar.Logger.Println("Error starting rejudge", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, utils.Envelope{"data": rejudge})
+}
+
+func (ar *AdminRejudgeHandler) HandlerGetRejudgeByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	rejudgeID, err := uuid.Parse(id)
+	if err != nil {
+		ar.Logger.Println("Error parsing rejudge id", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
+	}
+
+	rejudge, err := ar.RejudgeStore.GetRejudgeByID(rejudgeID)
+	if errors.Is(err, store.ErrRejudgeNotFound) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Rejudge not found"})
+		return
+	}
+
+	if err != nil {
+		ar.Logger.Println("Error getting rejudge by id", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": rejudge})
+}
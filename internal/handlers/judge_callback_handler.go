@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/judge"
+	"github.com/grvbrk/async0_server/internal/utils"
+)
+
+type JudgeCallbackHandler struct {
+	JudgePool   *judge.Pool
+	Secret      string
+	OutputLimit int
+	Logger      *log.Logger
+}
+
+func NewJudgeCallbackHandler(judgePool *judge.Pool, secret string, outputLimit int, logger *log.Logger) *JudgeCallbackHandler {
+	return &JudgeCallbackHandler{
+		JudgePool:   judgePool,
+		Secret:      secret,
+		OutputLimit: outputLimit,
+		Logger:      logger,
+	}
+}
+
+func (jh *JudgeCallbackHandler) HandlerJudge0Callback(w http.ResponseWriter, r *http.Request) {
+
+	// Without a secret callbacks aren't in use, so nothing legitimate calls this
+	if jh.Secret == "" || subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("secret")), []byte(jh.Secret)) != 1 {
+		jh.Logger.Println("Judge0 callback with invalid secret")
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Not Authorized"})
+		return
+	}
+
+	var result executor.Result
+	err := json.NewDecoder(r.Body).Decode(&result)
+	if err != nil || result.Token == "" {
+		jh.Logger.Println("Error decoding judge0 callback body", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
+	}
+
+	// Judge0 always sends callbacks base64 encoded
+	err = result.DecodeBase64()
+	if err != nil {
+		jh.Logger.Println("Error decoding judge0 callback fields", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
+	}
+	result.Bound(jh.OutputLimit)
+
+	if result.IsPending() {
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "ok"})
+		return
+	}
+
+	if !jh.JudgePool.Callbacks.Deliver(result) {
+		jh.Logger.Println("Judge0 callback for unregistered token", result.Token)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "ok"})
+}
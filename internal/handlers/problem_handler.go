@@ -8,7 +8,10 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/grvbrk/async0_server/internal/auth"
+	"github.com/grvbrk/async0_server/internal/harness"
+	"github.com/grvbrk/async0_server/internal/languages"
 	"github.com/grvbrk/async0_server/internal/middlewares"
+	"github.com/grvbrk/async0_server/internal/models"
 	"github.com/grvbrk/async0_server/internal/store"
 	"github.com/grvbrk/async0_server/internal/utils"
 )
@@ -42,9 +45,33 @@ func (ph *ProblemHandler) HandlerGetProblemBySlug(w http.ResponseWriter, r *http
 		return
 	}
 
+	if problem.Signature != nil {
+		fillStarterCode(problem)
+	}
+
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": problem})
 }
 
+// fillStarterCode adds a stub from the signature for every language the
+// problem has no hand written starter code in.
+func fillStarterCode(problem *models.Problem) {
+	if problem.StarterCode == nil {
+		problem.StarterCode = models.StarterCode{}
+	}
+
+	for _, language := range languages.All() {
+		if _, ok := problem.StarterCode[language.Slug]; ok {
+			continue
+		}
+
+		stub, err := harness.Stub(language.Slug, *problem.Signature)
+		if err != nil {
+			continue
+		}
+		problem.StarterCode[language.Slug] = stub
+	}
+}
+
 func (ph *ProblemHandler) HandlerGetTanstackTableProblems(w http.ResponseWriter, r *http.Request) {
 	var userID *uuid.UUID
 
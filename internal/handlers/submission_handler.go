@@ -1,20 +1,21 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
-	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/grvbrk/async0_server/internal/auth"
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/harness"
+	"github.com/grvbrk/async0_server/internal/judge"
+	"github.com/grvbrk/async0_server/internal/languages"
 	"github.com/grvbrk/async0_server/internal/middlewares"
 	"github.com/grvbrk/async0_server/internal/models"
 	"github.com/grvbrk/async0_server/internal/store"
@@ -22,54 +23,43 @@ import (
 )
 
 type SubmissionBody struct {
-	Code string `json:"code"`
+	Language string `json:"language"`
+	Code     string `json:"code"`
+	// Stop at the first failing testcase, even if the problem doesn't
+	FailFast bool `json:"fail_fast"`
 }
 
-type Judge0Submission struct {
-	LanguageID     int    `json:"language_id"`
-	SourceCode     string `json:"source_code"`
-	Stdin          string `json:"stdin,omitempty"`
-	ExpectedOutput string `json:"expected_output,omitempty"`
+type SubmitSubmissionAccepted struct {
+	SubmissionID  uuid.UUID               `json:"submission_id"`
+	Status        models.SubmissionStatus `json:"status"`
+	QueuePosition int                     `json:"queue_position"`
 }
 
-type Judge0BatchRequest struct {
-	Submissions []Judge0Submission `json:"submissions"`
+type RunSubmissionBody struct {
+	Language     string   `json:"language"`
+	Code         string   `json:"code"`
+	CustomInputs []string `json:"custom_inputs"`
 }
 
-type Status struct {
-	ID          int    `json:"id"`
-	Description string `json:"description"`
-}
-
-type Judge0Result struct {
-	Stdout        *string `json:"stdout"`
-	Time          *string `json:"time"`
-	Memory        *int    `json:"memory"`
-	Stderr        *string `json:"stderr"`
-	Token         string  `json:"token"`
-	CompileOutput *string `json:"compile_output"`
-	Message       *string `json:"message"`
-	Status        Status  `json:"status"`
-}
-
-type RunSubmissionResponse struct {
-	StatusID     string `json:"status_id"`
-	StatusDesc   string `json:"status_description"`
-	Result       string `json:"result,omitempty"`
-	ErrorMessage string `json:"error_message,omitempty"`
-}
+const maxCustomInputs = 10
 
 type SubmissionHandler struct {
 	SubmissionStore store.SubmissionStore
+	ProblemStore    store.ProblemStore
 	TestcaseStore   store.TestcaseStore
+	Executor        executor.CodeExecutor
+	JudgePool       *judge.Pool
 	Logger          *log.Logger
 	Oauth           *auth.GoogleOauth
 }
 
-func NewSubmissionHandler(submissionStore store.SubmissionStore, testcaseStore store.TestcaseStore, logger *log.Logger, oauth *auth.GoogleOauth) *SubmissionHandler {
+func NewSubmissionHandler(submissionStore store.SubmissionStore, problemStore store.ProblemStore, testcaseStore store.TestcaseStore, codeExecutor executor.CodeExecutor, judgePool *judge.Pool, logger *log.Logger, oauth *auth.GoogleOauth) *SubmissionHandler {
 	return &SubmissionHandler{
 		SubmissionStore: submissionStore,
+		ProblemStore:    problemStore,
 		TestcaseStore:   testcaseStore,
+		Executor:        codeExecutor,
+		JudgePool:       judgePool,
 		Logger:          logger,
 		Oauth:           oauth,
 	}
@@ -127,452 +117,579 @@ func (ph *SubmissionHandler) HandlerSubmitSubmission(w http.ResponseWriter, r *h
 		return
 	}
 
-	testcases, err := ph.TestcaseStore.GetTestcasesByProblemID(problemID)
+	problem, err := ph.ProblemStore.GetProblemByID(problemID)
 	if err != nil {
-		ph.Logger.Println("Error getting testcases", err)
+		if errors.Is(err, store.ErrProblemNotFound) {
+			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Not Found"})
+			return
+		}
+
+		ph.Logger.Println("Error getting problem by id", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
 		return
 	}
 
-	var submissions []Judge0Submission
+	language, ok := ph.submissionLanguage(w, body.Language, problem)
+	if !ok {
+		return
+	}
 
-	for _, testcase := range testcases {
-		inputTemplate := `
-			%s
-			try {
-				const result = %s;
-				console.log(JSON.stringify(result));
-			} catch (error) {
-				console.error('Runtime Error:', error.message);
-				process.exit(1);
-			}
-		`
+	// Fail fast rather than queue a submission that can only end up IE
+	if !executor.Available(ph.Executor) {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.Envelope{"message": "Judge unavailable, try again shortly"})
+		return
+	}
+
+	submissionID, err := ph.SubmissionStore.CreatePendingSubmission(user.ID, problemID, language.Slug, body.Code)
+	if err != nil {
+		ph.Logger.Println("Error creating submission", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		return
+	}
 
-		sourceCode := fmt.Sprintf(inputTemplate, body.Code, testcase.Input)
-		expectedOutput := strings.ReplaceAll(strings.TrimSpace(testcase.Output), " ", "")
+	position, err := ph.JudgePool.Enqueue(r.Context(), judge.Job{
+		ID:        submissionID,
+		UserID:    user.ID,
+		ProblemID: problemID,
+		Language:  language.Slug,
+		Code:      body.Code,
+		FailFast:  body.FailFast,
+	})
+	if err != nil {
+		ph.Logger.Println("Error enqueueing submission", err)
 
-		submission := Judge0Submission{
-			LanguageID:     63,
-			SourceCode:     sourceCode,
-			ExpectedOutput: expectedOutput,
+		if err := ph.SubmissionStore.DeleteSubmission(submissionID); err != nil {
+			ph.Logger.Println("Error deleting unqueued submission", err)
 		}
 
-		submissions = append(submissions, submission)
+		ph.writeQueueError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, utils.Envelope{"data": SubmitSubmissionAccepted{
+		SubmissionID:  submissionID,
+		Status:        models.StatusPending,
+		QueuePosition: position,
+	}})
+
+}
+
+// writeQueueError answers a request the judge queue turned away.
+func (ph *SubmissionHandler) writeQueueError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, judge.ErrUserLimit):
+		utils.WriteJSON(w, http.StatusTooManyRequests, utils.Envelope{"message": "You have too many submissions running, wait for one to finish"})
+	case errors.Is(err, judge.ErrQueueFull):
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.Envelope{"message": "Too many submissions, try again shortly"})
+	default:
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+	}
+}
+
+func (ph *SubmissionHandler) HandlerGetSubmissionByID(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := middlewares.GetUserFromContext(r)
+	if !ok {
+		ph.Logger.Println("No user found in context")
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Not Authorized"})
+		return
 	}
 
-	batchRequest := Judge0BatchRequest{
-		Submissions: submissions,
+	submissionID, err := uuid.Parse(chi.URLParam(r, "submissionID"))
+	if err != nil {
+		ph.Logger.Println("Error parsing submission id", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
 	}
 
-	jsonBody, err := json.Marshal(batchRequest)
+	submission, err := ph.SubmissionStore.GetSubmissionByID(submissionID)
 	if err != nil {
-		ph.Logger.Println("Error marshalling batch request", err)
+		if errors.Is(err, store.ErrSubmissionNotFound) {
+			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Not Found"})
+			return
+		}
+
+		ph.Logger.Println("Error getting submission by id", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
 		return
 	}
 
-	client := &http.Client{
-		Timeout: 5 * time.Second,
+	if submission.UserID != user.ID {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Not Found"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": submission})
+}
+
+func (ph *SubmissionHandler) HandlerGetSubmissionResults(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := middlewares.GetUserFromContext(r)
+	if !ok {
+		ph.Logger.Println("No user found in context")
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Not Authorized"})
+		return
 	}
 
-	resp, err := client.Post(fmt.Sprintf("%s/submissions/batch?base64_encoded=false&wait=false", os.Getenv("JUDGE0_URL")), "application/json", bytes.NewBuffer([]byte(jsonBody)))
+	submissionID, err := uuid.Parse(chi.URLParam(r, "submissionID"))
 	if err != nil {
-		if os.IsTimeout(err) {
-			ph.Logger.Println("Judge0 batch submit request timed out", err)
-			utils.WriteJSON(w, http.StatusRequestTimeout, utils.Envelope{"message": "Request timed out"})
+		ph.Logger.Println("Error parsing submission id", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
+	}
+
+	submission, err := ph.SubmissionStore.GetSubmissionByID(submissionID)
+	if err != nil {
+		if errors.Is(err, store.ErrSubmissionNotFound) {
+			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Not Found"})
 			return
 		}
 
-		ph.Logger.Println("Error submitting judge0 batch request", err)
+		ph.Logger.Println("Error getting submission by id", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
 		return
 	}
 
-	defer resp.Body.Close()
+	if submission.UserID != user.ID {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Not Found"})
+		return
+	}
 
-	var batchResponse []map[string]string
-	if err := json.NewDecoder(resp.Body).Decode(&batchResponse); err != nil {
-		ph.Logger.Println("Error decoding batch response", err)
+	results, err := ph.SubmissionStore.GetSubmissionTestcaseResults(submissionID)
+	if err != nil {
+		ph.Logger.Println("Error getting submission testcase results", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
 		return
 	}
 
-	tokens := make([]string, len(batchResponse))
-	for i, submission := range batchResponse {
-		tokens[i] = submission["token"]
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": models.RedactTestcaseResults(results)})
+}
+
+func (ph *SubmissionHandler) HandlerGetSubmissionPercentile(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := middlewares.GetUserFromContext(r)
+	if !ok {
+		ph.Logger.Println("No user found in context")
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Not Authorized"})
+		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
+	submissionID, err := uuid.Parse(chi.URLParam(r, "submissionID"))
+	if err != nil {
+		ph.Logger.Println("Error parsing submission id", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
+	}
 
-	results, err := pollJudge0BatchResults(ctx, tokens)
-	if err != nil || len(results) == 0 {
-		ph.Logger.Println("Error polling judge0 results", err)
+	submission, err := ph.SubmissionStore.GetSubmissionByID(submissionID)
+	if err != nil {
+		if errors.Is(err, store.ErrSubmissionNotFound) {
+			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Not Found"})
+			return
+		}
+
+		ph.Logger.Println("Error getting submission by id", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
 		return
 	}
 
-	result := formatMultipleJudge0Results(results, testcases)
+	if submission.UserID != user.ID {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Not Found"})
+		return
+	}
 
-	err = ph.SubmissionStore.CreateSubmission(user.ID, problemID, body.Code, result)
+	if submission.Status != models.StatusAC || submission.Runtime == nil || submission.MemoryUsed == nil {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"message": "Only accepted submissions are ranked"})
+		return
+	}
+
+	percentile, err := ph.SubmissionStore.GetSubmissionPercentile(submission)
 	if err != nil {
-		ph.Logger.Println("Error creating submission", err)
+		ph.Logger.Println("Error getting submission percentile", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": result})
-
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": percentile})
 }
 
-func pollJudge0BatchResults(ctx context.Context, tokens []string) ([]Judge0Result, error) {
-	tokensParam := strings.Join(tokens, ",")
-	baseDelay := 500 * time.Millisecond
+func (ph *SubmissionHandler) HandlerStreamSubmissionEvents(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := middlewares.GetUserFromContext(r)
+	if !ok {
+		ph.Logger.Println("No user found in context")
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Not Authorized"})
+		return
+	}
 
-	client := &http.Client{
-		Timeout: 3 * time.Second,
+	submissionID, err := uuid.Parse(chi.URLParam(r, "submissionID"))
+	if err != nil {
+		ph.Logger.Println("Error parsing submission id", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
 	}
 
-	attempt := 0
+	// Subscribe before reading the submission so nothing published in between is missed
+	history, events, unsubscribe, err := ph.JudgePool.Events.Subscribe(r.Context(), submissionID)
+	if err != nil {
+		ph.Logger.Println("Error subscribing to submission events", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		return
+	}
+	defer unsubscribe()
 
-	for {
-		// First check if the context is done or not (timeout or cancellation)
-		select {
-		case <-ctx.Done():
-			return []Judge0Result{}, ctx.Err()
-		default:
+	submission, err := ph.SubmissionStore.GetSubmissionByID(submissionID)
+	if err != nil {
+		if errors.Is(err, store.ErrSubmissionNotFound) {
+			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Not Found"})
+			return
 		}
 
-		req, err := http.NewRequestWithContext(
-			ctx, "GET", fmt.Sprintf("%s/submissions/batch?tokens=%s&base64_encoded=false", os.Getenv("JUDGE0_URL"), tokensParam), nil,
-		)
-		if err != nil {
-			return []Judge0Result{}, fmt.Errorf("error creating request: %w", err)
-		}
+		ph.Logger.Println("Error getting submission by id", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		return
+	}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			// If context is done, return immediately
-			if ctx.Err() != nil {
-				return []Judge0Result{}, ctx.Err()
-			}
-			return []Judge0Result{}, fmt.Errorf("error sending get request: %w", err)
-		}
+	if submission.UserID != user.ID {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Not Found"})
+		return
+	}
 
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return []Judge0Result{}, fmt.Errorf("error reading response body: %w", err)
+	// The stream outlives the server's WriteTimeout, so lift the deadline for this response
+	rc := http.NewResponseController(w)
+	err = rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		ph.Logger.Println("Error clearing write deadline for event stream", err)
+	}
+
+	utils.WriteSSEHeaders(w)
+
+	send := func(event judge.Event) bool {
+		id := ""
+		if event.Type == judge.EventTestcase {
+			id = fmt.Sprintf("%d", event.ID)
 		}
 
-		var judge0Response struct {
-			Submissions []Judge0Result `json:"submissions"`
+		if err := utils.WriteSSE(w, event.Type, id, event.Data); err != nil {
+			return false
 		}
+		return rc.Flush() == nil
+	}
 
-		err = json.Unmarshal(body, &judge0Response)
+	sendSummary := func(submission *models.Submission) {
+		results, err := ph.SubmissionStore.GetSubmissionTestcaseResults(submissionID)
 		if err != nil {
-			return nil, fmt.Errorf("error unmarshalling response body: %w, raw response: %s", err, string(body))
+			ph.Logger.Println("Error getting submission testcase results", err)
 		}
 
-		results := judge0Response.Submissions
-		if len(results) != len(tokens) {
-			return nil, fmt.Errorf("expected %d results, got %d", len(tokens), len(results))
-		}
+		send(judge.Event{Type: judge.EventResult, Data: submissionSummary(submission, results).Redacted()})
+	}
 
-		// Check if all result are done processing
-		allComplete := true
-		for _, result := range results {
-			if result.Status.ID == 1 || result.Status.ID == 2 {
-				// status id < 2 means still pending
-				allComplete = false
-				break
-			}
+	for _, event := range history {
+		if !send(event) {
+			return
 		}
+	}
 
-		if allComplete {
-			return results, nil
-		}
+	// Judged before we subscribed and the events have already expired
+	if len(history) == 0 && submission.Status.IsFinal() {
+		sendSummary(submission)
+		return
+	}
 
-		delay := time.Duration(float64(baseDelay) * (1.5 * float64(attempt)))
-		if delay > 2*time.Second {
-			delay = 2 * time.Second // Cap at 2 seconds
-		}
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
 
-		timer := time.NewTimer(delay)
-		select {
-		case <-ctx.Done():
-			timer.Stop()
-			return []Judge0Result{}, ctx.Err()
-		case <-timer.C:
-			// Wait for delay seconds
-			// Continue to next iteration
+	// Keep the user posted on their place in the queue until a worker has it
+	queueTicker := time.NewTicker(2 * time.Second)
+	defer queueTicker.Stop()
+
+	lastPosition := 0
+	sendPosition := func() bool {
+		position, err := ph.JudgePool.QueuePosition(r.Context(), submission)
+		if err != nil {
+			ph.Logger.Println("Error getting queue position", err)
+			return true
 		}
 
-		attempt++
+		if position == lastPosition {
+			return true
+		}
+		lastPosition = position
+		return send(judge.Event{Type: judge.EventQueue, Data: position})
+	}
 
+	if submission.Status == models.StatusPending && !sendPosition() {
+		return
 	}
-}
 
-func formatMultipleJudge0Results(results []Judge0Result, testCases []models.Testcase) models.SubmitSubmissionResponse {
-	passedTests := 0
-	formattedResults := make([]models.TestcaseResult, len(results))
-
-	statusDescriptions := map[int]string{
-		1:  "In Queue",
-		2:  "Processing",
-		3:  "Accepted",
-		4:  "Wrong Answer",
-		5:  "Time Limit Exceeded",
-		6:  "Compilation Error",
-		7:  "Runtime Error (SIGSEGV)",
-		8:  "Runtime Error (SIGXFSZ)",
-		9:  "Runtime Error (SIGFPE)",
-		10: "Runtime Error (SIGABRT)",
-		11: "Runtime Error (NZEC)",
-		12: "Runtime Error (Other)",
-		13: "Internal Error",
-		14: "Exec Format Error",
-	}
-
-	for i, result := range results {
-		statusDesc := statusDescriptions[result.Status.ID]
-		if statusDesc == "" {
-			statusDesc = fmt.Sprintf("Unknown Status (%d)", result.Status.ID)
-		}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
 
-		actualOutput := ""
-		if result.Stdout != nil {
-			actualOutput = strings.TrimSpace(*result.Stdout)
+			// In case the result event never made it here
+			current, err := ph.SubmissionStore.GetSubmissionByID(submissionID)
+			if err != nil {
+				ph.Logger.Println("Error getting submission by id", err)
+				continue
+			}
+			if current.Status.IsFinal() {
+				sendSummary(current)
+				return
+			}
+		case <-queueTicker.C:
+			if lastPosition > 0 && !sendPosition() {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !send(event) || event.Type == judge.EventResult {
+				return
+			}
 		}
+	}
+}
 
-		normalize := func(s string) string {
-			s = strings.TrimSpace(s)
-			s = strings.ReplaceAll(s, " ", "")
-			return s
-		}
+func submissionSummary(submission *models.Submission, results []models.TestcaseResult) models.SubmitSubmissionResponse {
+	if results == nil {
+		results = []models.TestcaseResult{}
+	}
 
-		actualOutputNorm := normalize(actualOutput)
-		expectedOutputNorm := normalize(testCases[i].Output)
+	summary := models.SubmitSubmissionResponse{
+		OverallStatus:    submission.Status,
+		TestcasesResults: results,
+	}
 
-		passed := result.Status.ID == 3 && actualOutputNorm == expectedOutputNorm
-		if passed {
-			passedTests++
-		}
+	if submission.PassedTestcases != nil {
+		summary.PassedTestcases = *submission.PassedTestcases
+	}
 
-		tcTime := ""
-		if result.Time != nil {
-			tcTime = *result.Time
-		}
+	if submission.TotalTestcases != nil {
+		summary.TotalTestcases = *submission.TotalTestcases
+	}
 
-		tcMemory := 0
-		if result.Memory != nil {
-			tcMemory = *result.Memory
-		}
+	if submission.Runtime != nil {
+		summary.Runtime = *submission.Runtime
+	}
 
-		formattedResults[i] = models.TestcaseResult{
-			TCPass:           passed,
-			TCStatusID:       result.Status.ID,
-			TCStatus:         statusDesc,
-			TCTime:           tcTime,
-			TCMemory:         tcMemory,
-			TCOutput:         actualOutput,
-			TCExpectedOutput: expectedOutputNorm,
+	if submission.MemoryUsed != nil {
+		summary.MemoryUsed = *submission.MemoryUsed
+	}
+
+	if submission.FirstFailedTestcase != nil {
+		summary.FirstFailedTestcase = submission.FirstFailedTestcase
+		if *submission.FirstFailedTestcase < len(results) {
+			summary.FirstFailedVerdict = results[*submission.FirstFailedTestcase].TCVerdict
 		}
 	}
 
-	overallStatusID := 4
-	overallStatus := models.StatusRE
-	if passedTests == len(results) {
-		overallStatusID = 3
-		overallStatus = models.StatusAC
+	summary.OverallStatusID = executor.StatusWrongAnswer
+	if submission.Status == models.StatusAC {
+		summary.OverallStatusID = executor.StatusAccepted
 	}
 
-	return models.SubmitSubmissionResponse{
-		OverallStatusID:  overallStatusID,
-		OverallStatus:    overallStatus,
-		PassedTestcases:  passedTests,
-		TotalTestcases:   len(results),
-		TestcasesResults: formattedResults,
+	if len(results) > 0 {
+		summary.OverallStatusID = judge.ResolveVerdict(results).StatusID
 	}
+
+	return summary
 }
 
 func (ph *SubmissionHandler) HandlerRunSubmission(w http.ResponseWriter, r *http.Request) {
 
-	var body SubmissionBody
-	err := json.NewDecoder(r.Body).Decode(&body)
-	if err != nil {
-		ph.Logger.Println("Error decoding submission body", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+	user, ok := middlewares.GetUserFromContext(r)
+	if !ok {
+		ph.Logger.Println("No user found in context")
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Not Authorized"})
 		return
 	}
 
-	var submission = Judge0Submission{
-		LanguageID: 63,
-		SourceCode: body.Code,
+	problemID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		ph.Logger.Println("Error parsing problem id", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
 	}
 
-	jsonBody, err := json.Marshal(submission)
+	var body RunSubmissionBody
+	err = json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
-		ph.Logger.Println("Error marshalling submission", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		ph.Logger.Println("Error decoding run body", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
 		return
 	}
 
-	client := &http.Client{
-		Timeout: 5 * time.Second,
+	if len(body.CustomInputs) > maxCustomInputs {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": fmt.Sprintf("At most %d custom inputs are allowed", maxCustomInputs)})
+		return
 	}
 
-	resp, err := client.Post(fmt.Sprintf("%s/submissions?base64_encoded=false&wait=false", os.Getenv("JUDGE0_URL")), "application/json", bytes.NewBuffer([]byte(jsonBody)))
+	problem, err := ph.ProblemStore.GetProblemByID(problemID)
 	if err != nil {
-		if err, ok := err.(interface{ Timeout() bool }); ok && err.Timeout() {
-			ph.Logger.Println("Judge0 run request timed out", err)
-			utils.WriteJSON(w, http.StatusRequestTimeout, utils.Envelope{"message": "Request timed out"})
+		if errors.Is(err, store.ErrProblemNotFound) {
+			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Not Found"})
 			return
 		}
 
-		ph.Logger.Println("Error submitting judge0 run request", err)
+		ph.Logger.Println("Error getting problem by id", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
 		return
 	}
 
-	defer resp.Body.Close()
-
-	type Response struct {
-		Token string `json:"token"`
+	language, ok := ph.submissionLanguage(w, body.Language, problem)
+	if !ok {
+		return
 	}
 
-	var response Response
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		ph.Logger.Println("Error decoding response", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+	if !executor.Available(ph.Executor) {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.Envelope{"message": "Judge unavailable, try again shortly"})
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	// Covers the wait in the queue as well as the run itself
+	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
 	defer cancel()
 
-	pollResult, err := pollJudge0SingleResult(ctx, response.Token)
+	response, err := ph.JudgePool.Run(ctx, judge.Job{
+		UserID:       user.ID,
+		ProblemID:    problemID,
+		Language:     language.Slug,
+		Code:         body.Code,
+		CustomInputs: body.CustomInputs,
+	})
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			ph.Logger.Println("Judge0 polling timed out")
-			utils.WriteJSON(w, http.StatusRequestTimeout, utils.Envelope{"message": "Execution timed out"})
-			return
-		}
-		ph.Logger.Println("Error polling judge0 results", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		ph.writeRunError(w, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": formatJudge0Result(pollResult)})
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": response})
 
 }
 
-func pollJudge0SingleResult(ctx context.Context, token string) (Judge0Result, error) {
-	baseDelay := 500 * time.Millisecond
-
-	client := &http.Client{
-		Timeout: 3 * time.Second,
+// writeRunError writes the response for a run or stress test that failed.
+func (ph *SubmissionHandler) writeRunError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The client went away, there's nobody to answer
+		return
+	case errors.Is(err, executor.ErrUnavailable):
+		ph.Logger.Println("Judge unavailable for run", err)
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.Envelope{"message": "Judge unavailable, try again shortly"})
+	case isTimeout(err):
+		ph.Logger.Println("Run request timed out", err)
+		utils.WriteJSON(w, http.StatusRequestTimeout, utils.Envelope{"message": "Execution timed out"})
+	case errors.Is(err, harness.ErrInvalidInput):
+		// Only custom inputs can get here, stored testcases are checked on save
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": err.Error()})
+	case errors.Is(err, judge.ErrUserLimit) || errors.Is(err, judge.ErrQueueFull):
+		ph.Logger.Println("Error queueing run", err)
+		ph.writeQueueError(w, err)
+	default:
+		ph.Logger.Println("Error running submission", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
 	}
+}
 
-	attempt := 0
-	for {
-		// Check if context is done (timeout or cancellation)
-		select {
-		case <-ctx.Done():
-			return Judge0Result{}, ctx.Err()
-		default:
-		}
+// HandlerStressSubmission runs the code and the reference solution on random
+// inputs to find a small one the code gets wrong.
+func (ph *SubmissionHandler) HandlerStressSubmission(w http.ResponseWriter, r *http.Request) {
 
-		req, err := http.NewRequestWithContext(
-			ctx,
-			"GET",
-			fmt.Sprintf("%s/submissions/%s?base64_encoded=false", os.Getenv("JUDGE0_URL"), token),
-			nil,
-		)
-		if err != nil {
-			return Judge0Result{}, fmt.Errorf("error creating request: %w", err)
-		}
+	user, ok := middlewares.GetUserFromContext(r)
+	if !ok {
+		ph.Logger.Println("No user found in context")
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Not Authorized"})
+		return
+	}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			// If context is done, return immediately
-			if ctx.Err() != nil {
-				return Judge0Result{}, ctx.Err()
-			}
-			return Judge0Result{}, fmt.Errorf("error sending get request: %w", err)
-		}
+	problemID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		ph.Logger.Println("Error parsing problem id", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
+	}
 
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return Judge0Result{}, fmt.Errorf("error reading response body: %w", err)
-		}
+	var body RunSubmissionBody
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		ph.Logger.Println("Error decoding stress body", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Bad Request"})
+		return
+	}
 
-		var result Judge0Result
-		err = json.Unmarshal(body, &result)
-		if err != nil {
-			return Judge0Result{}, fmt.Errorf("error unmarshalling response body: %w, raw response: %s", err, string(body))
+	problem, err := ph.ProblemStore.GetProblemByID(problemID)
+	if err != nil {
+		if errors.Is(err, store.ErrProblemNotFound) {
+			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"message": "Not Found"})
+			return
 		}
 
-		// Check if processing is complete
-		if result.Status.ID != 1 && result.Status.ID != 2 {
-			return result, nil
-		}
+		ph.Logger.Println("Error getting problem by id", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
+		return
+	}
 
-		// Calculate delay with exponential backoff
-		delay := time.Duration(float64(baseDelay) * (1.5 * float64(attempt)))
-		if delay > 2*time.Second {
-			delay = 2 * time.Second // Cap at 2 seconds
-		}
+	if !judge.CanStress(problem) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Stress testing isn't available for this problem"})
+		return
+	}
 
-		// Use a timer to sleep for delay seconds (basically time.sleep(delay))
-		timer := time.NewTimer(delay)
-		select {
-		case <-ctx.Done():
-			timer.Stop()
-			return Judge0Result{}, ctx.Err()
-		case <-timer.C:
-			// Wait for delay seconds
-			// Continue to next iteration
-		}
+	language, ok := ph.submissionLanguage(w, body.Language, problem)
+	if !ok {
+		return
+	}
 
-		attempt++
+	if !executor.Available(ph.Executor) {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.Envelope{"message": "Judge unavailable, try again shortly"})
+		return
 	}
-}
 
-func formatJudge0Result(result Judge0Result) RunSubmissionResponse {
+	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
+	defer cancel()
 
-	var statusMap = map[int]string{
-		1: "In Queue",
-		2: "Processing",
-		3: "Accepted",
-		4: "Wrong Answer",
-		5: "Time Limit Exceeded",
-		6: "Compilation Error",
+	response, err := ph.JudgePool.Stress(ctx, judge.Job{
+		UserID:    user.ID,
+		ProblemID: problemID,
+		Language:  language.Slug,
+		Code:      body.Code,
+	})
+	if err != nil {
+		ph.writeRunError(w, err)
+		return
 	}
 
-	desc, ok := statusMap[result.Status.ID]
-	if !ok {
-		desc = "Execution Error"
-	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": response})
+}
 
-	resp := RunSubmissionResponse{
-		StatusID:   fmt.Sprintf("%d", result.Status.ID),
-		StatusDesc: desc,
+// submissionLanguage resolves the requested language, writing a 400 when it
+// can't be judged on the problem.
+func (ph *SubmissionHandler) submissionLanguage(w http.ResponseWriter, slug string, problem *models.Problem) (languages.Language, bool) {
+	language, err := languages.Get(slug)
+	if err != nil {
+		ph.Logger.Println("Error getting submission language", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": "Unsupported language"})
+		return languages.Language{}, false
 	}
 
-	if result.Stdout != nil && *result.Stdout != "" {
-		resp.Result = *result.Stdout
+	if !judge.Supports(language, problem) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"message": fmt.Sprintf("%s submissions aren't supported for this problem yet", language.Name)})
+		return languages.Language{}, false
 	}
 
-	if result.Stderr != nil && *result.Stderr != "" {
-		resp.ErrorMessage = *result.Stderr
-	} else if result.CompileOutput != nil && *result.CompileOutput != "" {
-		resp.ErrorMessage = *result.CompileOutput
-	} else if result.Message != nil && *result.Message != "" {
-		resp.ErrorMessage = *result.Message
+	return language, true
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
 	}
 
-	return resp
+	var timeoutErr interface{ Timeout() bool }
+	return errors.As(err, &timeoutErr) && timeoutErr.Timeout()
 }
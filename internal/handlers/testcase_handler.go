@@ -35,7 +35,7 @@ func (ph *TestcaseHandler) HandlerGetTestcasesByProblemID(w http.ResponseWriter,
 		return
 	}
 
-	testcases, err := ph.TestcaseStore.GetTestcasesByProblemID(problemID)
+	testcases, err := ph.TestcaseStore.GetSampleTestcasesByProblemID(problemID)
 	if err != nil {
 		ph.Logger.Println("Error getting testcase by slug", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"message": "Internal Server Error"})
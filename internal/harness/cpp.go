@@ -0,0 +1,388 @@
+package harness
+
+import (
+	"fmt"
+	"strings"
+)
+
+type cpp struct{}
+
+// Needs C++17 for optional and if constexpr, the language passes the flag
+const cppPrelude = `#include <bits/stdc++.h>
+#include <unistd.h>
+using namespace std;
+
+struct TreeNode {
+    int val;
+    TreeNode *left;
+    TreeNode *right;
+    TreeNode() : val(0), left(nullptr), right(nullptr) {}
+    TreeNode(int x) : val(x), left(nullptr), right(nullptr) {}
+    TreeNode(int x, TreeNode *left, TreeNode *right) : val(x), left(left), right(right) {}
+};
+
+struct ListNode {
+    int val;
+    ListNode *next;
+    ListNode() : val(0), next(nullptr) {}
+    ListNode(int x) : val(x), next(nullptr) {}
+    ListNode(int x, ListNode *next) : val(x), next(next) {}
+};
+
+`
+
+// harnessCapture points fd 1 at a temporary file, so cout and printf output
+// alike wait until the result is out. harnessRelease puts it back and
+// returns what was written.
+const cppHelpers = `
+
+FILE* harnessLogFile = nullptr;
+int harnessStdout = -1;
+
+void harnessCapture() {
+    cout.flush();
+    fflush(stdout);
+    harnessLogFile = tmpfile();
+    if (!harnessLogFile) return;
+    harnessStdout = dup(1);
+    dup2(fileno(harnessLogFile), 1);
+}
+
+string harnessRelease() {
+    cout.flush();
+    fflush(stdout);
+    string logs;
+    if (!harnessLogFile) return logs;
+    dup2(harnessStdout, 1);
+    close(harnessStdout);
+    rewind(harnessLogFile);
+    char buf[4096];
+    for (size_t n; (n = fread(buf, 1, sizeof(buf), harnessLogFile)) > 0;) logs.append(buf, n);
+    fclose(harnessLogFile);
+    harnessLogFile = nullptr;
+    return logs;
+}
+
+TreeNode* harnessBuildTree(const vector<optional<int>>& values) {
+    if (values.empty() || !values[0]) return nullptr;
+    TreeNode* root = new TreeNode(*values[0]);
+    vector<TreeNode*> queue = {root};
+    size_t head = 0, i = 1;
+    while (head < queue.size() && i < values.size()) {
+        TreeNode* node = queue[head++];
+        if (i < values.size() && values[i]) {
+            node->left = new TreeNode(*values[i]);
+            queue.push_back(node->left);
+        }
+        i++;
+        if (i < values.size() && values[i]) {
+            node->right = new TreeNode(*values[i]);
+            queue.push_back(node->right);
+        }
+        i++;
+    }
+    return root;
+}
+
+ListNode* harnessBuildList(const vector<int>& values) {
+    ListNode dummy;
+    ListNode* tail = &dummy;
+    for (int value : values) {
+        tail->next = new ListNode(value);
+        tail = tail->next;
+    }
+    return dummy.next;
+}
+
+string harnessJson(const string& value) {
+    string out = "\"";
+    for (unsigned char c : value) {
+        switch (c) {
+            case '"': out += "\\\""; break;
+            case '\\': out += "\\\\"; break;
+            case '\n': out += "\\n"; break;
+            case '\r': out += "\\r"; break;
+            case '\t': out += "\\t"; break;
+            default:
+                if (c < 0x20) {
+                    char buf[8];
+                    snprintf(buf, sizeof(buf), "\\u%04x", c);
+                    out += buf;
+                } else {
+                    out += c;
+                }
+        }
+    }
+    return out + "\"";
+}
+
+string harnessJson(TreeNode* root) {
+    vector<string> out;
+    vector<TreeNode*> queue = {root};
+    for (size_t head = 0; head < queue.size(); head++) {
+        TreeNode* node = queue[head];
+        if (!node) {
+            out.push_back("null");
+            continue;
+        }
+        out.push_back(to_string(node->val));
+        queue.push_back(node->left);
+        queue.push_back(node->right);
+    }
+    while (!out.empty() && out.back() == "null") out.pop_back();
+    string joined = "[";
+    for (size_t i = 0; i < out.size(); i++) joined += (i ? "," : "") + out[i];
+    return joined + "]";
+}
+
+string harnessJson(ListNode* head) {
+    string joined = "[";
+    for (ListNode* node = head; node; node = node->next) {
+        joined += (node == head ? "" : ",") + to_string(node->val);
+    }
+    return joined + "]";
+}
+
+// HarnessReader reads the JSON the arguments come in, as far as the
+// signature types need.
+struct HarnessReader {
+    const string& s;
+    size_t i = 0;
+
+    void skip() {
+        while (i < s.size() && isspace((unsigned char) s[i])) i++;
+    }
+
+    bool null() {
+        skip();
+        if (s.compare(i, 4, "null") != 0) return false;
+        i += 4;
+        return true;
+    }
+
+    string token() {
+        skip();
+        size_t start = i;
+        while (i < s.size() && (isalnum((unsigned char) s[i]) || s[i] == '-' || s[i] == '+' || s[i] == '.')) i++;
+        return s.substr(start, i - start);
+    }
+
+    void utf8(string& out, unsigned cp) {
+        if (cp < 0x80) {
+            out += char(cp);
+        } else if (cp < 0x800) {
+            out += char(0xC0 | cp >> 6);
+            out += char(0x80 | (cp & 0x3F));
+        } else if (cp < 0x10000) {
+            out += char(0xE0 | cp >> 12);
+            out += char(0x80 | (cp >> 6 & 0x3F));
+            out += char(0x80 | (cp & 0x3F));
+        } else {
+            out += char(0xF0 | cp >> 18);
+            out += char(0x80 | (cp >> 12 & 0x3F));
+            out += char(0x80 | (cp >> 6 & 0x3F));
+            out += char(0x80 | (cp & 0x3F));
+        }
+    }
+
+    string text() {
+        skip();
+        string out;
+        i++;
+        while (i < s.size() && s[i] != '"') {
+            char c = s[i++];
+            if (c != '\\') {
+                out += c;
+                continue;
+            }
+            char e = s[i++];
+            switch (e) {
+                case 'n': out += '\n'; break;
+                case 't': out += '\t'; break;
+                case 'r': out += '\r'; break;
+                case 'b': out += '\b'; break;
+                case 'f': out += '\f'; break;
+                case 'u': {
+                    unsigned cp = stoul(s.substr(i, 4), nullptr, 16);
+                    i += 4;
+                    if (cp >= 0xD800 && cp < 0xDC00 && s.compare(i, 2, "\\u") == 0) {
+                        unsigned low = stoul(s.substr(i + 2, 4), nullptr, 16);
+                        i += 6;
+                        cp = 0x10000 + ((cp - 0xD800) << 10) + (low - 0xDC00);
+                    }
+                    utf8(out, cp);
+                    break;
+                }
+                default: out += e;
+            }
+        }
+        i++;
+        return out;
+    }
+
+    template <typename F>
+    void array(F each) {
+        skip();
+        i++;
+        skip();
+        if (i < s.size() && s[i] == ']') {
+            i++;
+            return;
+        }
+        while (i < s.size()) {
+            each();
+            skip();
+            if (s[i++] == ']') return;
+        }
+    }
+};
+
+void harnessRead(HarnessReader& r, int& v) { v = stoi(r.token()); }
+void harnessRead(HarnessReader& r, long long& v) { v = stoll(r.token()); }
+void harnessRead(HarnessReader& r, double& v) { v = stod(r.token()); }
+void harnessRead(HarnessReader& r, bool& v) { v = r.token() == "true"; }
+void harnessRead(HarnessReader& r, string& v) { v = r.text(); }
+
+void harnessRead(HarnessReader& r, char& v) {
+    string text = r.text();
+    v = text.empty() ? '\0' : text[0];
+}
+
+void harnessRead(HarnessReader& r, TreeNode*& v) {
+    vector<optional<int>> values;
+    if (!r.null()) {
+        r.array([&] {
+            if (r.null()) values.push_back(nullopt);
+            else values.push_back(stoi(r.token()));
+        });
+    }
+    v = harnessBuildTree(values);
+}
+
+void harnessRead(HarnessReader& r, ListNode*& v) {
+    vector<int> values;
+    if (!r.null()) {
+        r.array([&] { values.push_back(stoi(r.token())); });
+    }
+    v = harnessBuildList(values);
+}
+
+template <typename T>
+void harnessRead(HarnessReader& r, vector<T>& v) {
+    r.array([&] {
+        T value{};
+        harnessRead(r, value);
+        v.push_back(value);
+    });
+}
+
+template <typename T>
+string harnessJson(const vector<T>& values);
+
+template <typename T>
+string harnessJson(const T& value) {
+    if constexpr (is_same_v<T, bool>) {
+        return value ? "true" : "false";
+    } else if constexpr (is_same_v<T, char>) {
+        return harnessJson(string(1, value));
+    } else if constexpr (is_floating_point_v<T>) {
+        ostringstream out;
+        out << setprecision(15) << value;
+        return out.str();
+    } else {
+        return to_string(value);
+    }
+}
+
+template <typename T>
+string harnessJson(const vector<T>& values) {
+    string joined = "[";
+    for (size_t i = 0; i < values.size(); i++) {
+        T value = values[i];
+        joined += (i ? "," : "") + harnessJson(value);
+    }
+    return joined + "]";
+}
+`
+
+func (cpp) program(sig signature, code string) string {
+	var b strings.Builder
+
+	// Line directives fence the user's code, so the compiler names it in
+	// its errors with the user's own line numbers
+	b.WriteString(cppPrelude)
+	b.WriteString("#line 1 \"solution.cpp\"\n")
+	b.WriteString(code + "\n")
+	fmt.Fprintf(&b, "#line %d \"main.cpp\"\n", lines(&b)+2)
+	b.WriteString(cppHelpers)
+
+	b.WriteString("\nint main() {\n")
+	b.WriteString("    vector<string> harnessLines;\n")
+	b.WriteString("    for (string line; getline(cin, line);) if (!line.empty()) harnessLines.push_back(line);\n")
+
+	// Named variables, so reference parameters have something to bind to
+	names := make([]string, len(sig.Params))
+	for i, t := range sig.Params {
+		names[i] = fmt.Sprintf("harnessArg%d", i)
+		fmt.Fprintf(&b, "    %s %s{};\n", cppType(t), names[i])
+		fmt.Fprintf(&b, "    { HarnessReader r{harnessLines[%d]}; harnessRead(r, %s); }\n", i+1, names[i])
+	}
+
+	call := sig.Name
+	if strings.Contains(code, "class Solution") {
+		call = "Solution()." + sig.Name
+	}
+
+	// An exception still lets the user see what they printed before it
+	fmt.Fprintf(&b, `    harnessCapture();
+    try {
+        auto harnessResult = %s(%s);
+        string harnessLogs = harnessRelease();
+        cout << "\n" << harnessLines[0] << harnessJson(harnessResult) << "\n" << harnessLogs << flush;
+    } catch (...) {
+        cout << harnessRelease() << flush;
+        throw;
+    }
+    return 0;
+}
+`, call, strings.Join(names, ", "))
+
+	return b.String()
+}
+
+func (cpp) stub(sig signature) string {
+	params := make([]string, len(sig.Params))
+	for i, name := range sig.ParamNames {
+		t := sig.Params[i]
+		if t.Dims > 0 || t.Base == baseString {
+			params[i] = cppType(t) + "& " + name
+		} else {
+			params[i] = cppType(t) + " " + name
+		}
+	}
+
+	return fmt.Sprintf("class Solution {\npublic:\n    %s %s(%s) {\n\n    }\n};\n", cppType(sig.Return), sig.Name, strings.Join(params, ", "))
+}
+
+func cppType(t Type) string {
+	if t.Dims > 0 {
+		return "vector<" + cppType(t.elem()) + ">"
+	}
+
+	switch t.Base {
+	case baseInt:
+		return "int"
+	case baseLong:
+		return "long long"
+	case baseDouble:
+		return "double"
+	case baseBool:
+		return "bool"
+	case baseChar:
+		return "char"
+	case baseString:
+		return "string"
+	default:
+		return t.Base + "*"
+	}
+}
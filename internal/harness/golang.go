@@ -0,0 +1,287 @@
+package harness
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type golang struct{}
+
+// The user's own imports follow ours, which is fine as long as both come
+// before any other declaration. Aliased so they can't clash with theirs.
+const goPrelude = `package main
+
+import (
+	harnessbytes "bytes"
+	harnessio "io"
+	harnessjson "encoding/json"
+	harnessos "os"
+	harnessreflect "reflect"
+	harnessstrings "strings"
+)
+
+`
+
+const goHelpers = `
+
+type TreeNode struct {
+	Val   int
+	Left  *TreeNode
+	Right *TreeNode
+}
+
+type ListNode struct {
+	Val  int
+	Next *ListNode
+}
+
+func harnessBuildTree(values []*int) *TreeNode {
+	if len(values) == 0 || values[0] == nil {
+		return nil
+	}
+	root := &TreeNode{Val: *values[0]}
+	queue := []*TreeNode{root}
+	for head, i := 0, 1; head < len(queue) && i < len(values); head++ {
+		node := queue[head]
+		if i < len(values) && values[i] != nil {
+			node.Left = &TreeNode{Val: *values[i]}
+			queue = append(queue, node.Left)
+		}
+		i++
+		if i < len(values) && values[i] != nil {
+			node.Right = &TreeNode{Val: *values[i]}
+			queue = append(queue, node.Right)
+		}
+		i++
+	}
+	return root
+}
+
+func harnessBuildList(values []int) *ListNode {
+	dummy := &ListNode{}
+	tail := dummy
+	for _, value := range values {
+		tail.Next = &ListNode{Val: value}
+		tail = tail.Next
+	}
+	return dummy.Next
+}
+
+func harnessChar(s string) byte {
+	return s[0]
+}
+
+// harnessReadLines reads the marker and then the arguments, one JSON value
+// per line.
+func harnessReadLines() []string {
+	input, err := harnessio.ReadAll(harnessos.Stdin)
+	if err != nil {
+		panic(err)
+	}
+
+	var lines []string
+	for _, line := range harnessstrings.Split(string(input), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func harnessDecode(line string, target any) {
+	if err := harnessjson.Unmarshal([]byte(line), target); err != nil {
+		panic(err)
+	}
+}
+
+// harnessCapture sends what the user's code prints to a buffer until the
+// returned function is called, which puts stdout back and returns it.
+func harnessCapture() func() []byte {
+	stdout := harnessos.Stdout
+	reader, writer, err := harnessos.Pipe()
+	if err != nil {
+		panic(err)
+	}
+	harnessos.Stdout = writer
+
+	var logs harnessbytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		harnessio.Copy(&logs, reader)
+		close(done)
+	}()
+
+	return func() []byte {
+		harnessos.Stdout = stdout
+		writer.Close()
+		<-done
+		return logs.Bytes()
+	}
+}
+
+// harnessSerialize turns nodes into arrays, bytes into strings and nil
+// slices into empty ones so the JSON matches the other languages.
+func harnessSerialize(value any) any {
+	switch v := value.(type) {
+	case *TreeNode:
+		out := []any{}
+		queue := []*TreeNode{v}
+		for head := 0; head < len(queue); head++ {
+			node := queue[head]
+			if node == nil {
+				out = append(out, nil)
+				continue
+			}
+			out = append(out, node.Val)
+			queue = append(queue, node.Left, node.Right)
+		}
+		for len(out) > 0 && out[len(out)-1] == nil {
+			out = out[:len(out)-1]
+		}
+		return out
+	case *ListNode:
+		out := []int{}
+		for node := v; node != nil; node = node.Next {
+			out = append(out, node.Val)
+		}
+		return out
+	case byte:
+		return string(rune(v))
+	}
+
+	rv := harnessreflect.ValueOf(value)
+	if rv.Kind() == harnessreflect.Slice {
+		out := make([]any, rv.Len())
+		for i := range out {
+			out[i] = harnessSerialize(rv.Index(i).Interface())
+		}
+		return out
+	}
+	return value
+}
+`
+
+// Only the clause's own line, so the user's line numbers stay put
+var goPackageClause = regexp.MustCompile(`(?m)^[ \t]*package[ \t]+\w+[ \t]*$`)
+
+func (golang) program(sig signature, code string) string {
+	var b strings.Builder
+
+	// Line directives fence the user's code, so the compiler names it in its
+	// errors with the user's own line numbers
+	b.WriteString(goPrelude)
+	b.WriteString("//line solution.go:1\n")
+	b.WriteString(goPackageClause.ReplaceAllString(code, "") + "\n")
+	fmt.Fprintf(&b, "//line main.go:%d\n", lines(&b)+2)
+	b.WriteString(goHelpers)
+
+	b.WriteString("\nfunc main() {\n")
+	b.WriteString("\tharnessLines := harnessReadLines()\n")
+
+	// Decoded into what JSON holds first, then built into the parameter's type
+	names := make([]string, len(sig.Params))
+	for i, t := range sig.Params {
+		names[i] = fmt.Sprintf("harnessArg%d", i)
+		fmt.Fprintf(&b, "\tvar harnessRaw%d %s\n", i, goRawType(t))
+		fmt.Fprintf(&b, "\tharnessDecode(harnessLines[%d], &harnessRaw%d)\n", i+1, i)
+		fmt.Fprintf(&b, "\t%s := %s\n", names[i], goBuild(t, fmt.Sprintf("harnessRaw%d", i)))
+	}
+
+	// A panic still lets the user see what they printed before it
+	fmt.Fprintf(&b, `
+	harnessStop := harnessCapture()
+	harnessResult := func() %s {
+		defer func() {
+			if r := recover(); r != nil {
+				harnessos.Stdout.Write(harnessStop())
+				panic(r)
+			}
+		}()
+		return %s(%s)
+	}()
+	harnessLogs := harnessStop()
+
+	harnessos.Stdout.WriteString("\n" + harnessLines[0])
+	encoder := harnessjson.NewEncoder(harnessos.Stdout)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(harnessSerialize(harnessResult)); err != nil {
+		panic(err)
+	}
+	harnessos.Stdout.Write(harnessLogs)
+}
+`, goType(sig.Return), sig.Name, strings.Join(names, ", "))
+
+	return b.String()
+}
+
+// goRawType is the type JSON decodes an argument of type t into.
+func goRawType(t Type) string {
+	if t.Dims > 0 {
+		return "[]" + goRawType(t.elem())
+	}
+
+	switch t.Base {
+	case baseChar:
+		return "string"
+	case baseTreeNode:
+		return "[]*int"
+	case baseListNode:
+		return "[]int"
+	}
+
+	return goType(t)
+}
+
+// goBuild converts expr from its raw type to t.
+func goBuild(t Type, expr string) string {
+	if goRawType(t) == goType(t) {
+		return expr
+	}
+
+	if t.Dims > 0 {
+		return fmt.Sprintf("func(raw %s) %s { out := make(%s, len(raw)); for i, v := range raw { out[i] = %s }; return out }(%s)",
+			goRawType(t), goType(t), goType(t), goBuild(t.elem(), "v"), expr)
+	}
+
+	switch t.Base {
+	case baseChar:
+		return "harnessChar(" + expr + ")"
+	case baseTreeNode:
+		return "harnessBuildTree(" + expr + ")"
+	default:
+		return "harnessBuildList(" + expr + ")"
+	}
+}
+
+func (golang) stub(sig signature) string {
+	params := make([]string, len(sig.Params))
+	for i, name := range sig.ParamNames {
+		params[i] = name + " " + goType(sig.Params[i])
+	}
+
+	return fmt.Sprintf("func %s(%s) %s {\n\n}\n", sig.Name, strings.Join(params, ", "), goType(sig.Return))
+}
+
+func goType(t Type) string {
+	if t.Dims > 0 {
+		return "[]" + goType(t.elem())
+	}
+
+	switch t.Base {
+	case baseInt:
+		return "int"
+	case baseLong:
+		return "int64"
+	case baseDouble:
+		return "float64"
+	case baseBool:
+		return "bool"
+	case baseChar:
+		return "byte"
+	case baseString:
+		return "string"
+	default:
+		return "*" + t.Base
+	}
+}
@@ -0,0 +1,191 @@
+package harness
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+var ErrUnsupportedLanguage = errors.New("no harness generator for language")
+
+// Version is bumped whenever generated programs change in a way that can
+// change their output, so results cached for the old harness aren't reused.
+const Version = 4
+
+// NewMarker returns a marker for one run. The program reads it from the
+// first line of stdin and starts its result line with it, so the user's
+// code, which never sees it, can't print a result line of its own.
+func NewMarker() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// generator writes the program for one language: the node types, the user's
+// code, helpers that read the run's marker and then the arguments from
+// stdin, one JSON value per line, and a main that calls the function and
+// prints its result as JSON on the marker's line. What the user's code
+// prints is held back and printed after the result, so a flood of it can't
+// push the result past the output limit. Output that gets past the capture
+// comes first, so the marker always follows a newline of its own.
+//
+// The user's code is kept apart from the harness where the language allows:
+// run as its own unit, or fenced with line directives, or put last in the
+// file, so a stray brace fails in the user's code and not in ours.
+type generator interface {
+	program(sig signature, code string) string
+	stub(sig signature) string
+}
+
+// Keyed by language slug, see the languages package
+var generators = map[string]generator{
+	"javascript": javascript{},
+	"typescript": javascript{typed: true},
+	"python":     python{},
+	"go":         golang{},
+	"cpp":        cpp{},
+	"java":       java{},
+}
+
+func Supports(language string) bool {
+	_, ok := generators[language]
+	return ok
+}
+
+// Generate returns the program that runs code on the arguments it reads
+// from stdin, the same for every testcase. The input never appears in the
+// source, so the user's code can't read it from there.
+func Generate(language string, sig models.Signature, code string) (string, error) {
+	gen, ok := generators[language]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+
+	parsed, err := parseSignature(sig)
+	if err != nil {
+		return "", err
+	}
+
+	return gen.program(parsed, code), nil
+}
+
+// Stdin returns what a generated program reads for the testcase input: the
+// run's marker and then each argument as compact JSON, a line each.
+func Stdin(sig models.Signature, input string, marker string) (string, error) {
+	parsed, err := parseSignature(sig)
+	if err != nil {
+		return "", err
+	}
+
+	args, err := parseArgs(parsed, input)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(marker + "\n")
+	for _, arg := range args {
+		b.WriteString(jsonText(arg) + "\n")
+	}
+	return b.String(), nil
+}
+
+// SplitOutput separates a program's result, the last line starting with
+// marker, from everything else it printed. The newline the harness writes
+// before the marker is dropped too. ok is false when there's no result
+// line, as for programs that crashed or weren't generated.
+func SplitOutput(stdout string, marker string) (result string, log string, ok bool) {
+	if marker == "" {
+		return "", stdout, false
+	}
+
+	end := len(stdout)
+	for {
+		i := strings.LastIndex(stdout[:end], marker)
+		if i < 0 {
+			return "", stdout, false
+		}
+		if i > 0 && stdout[i-1] != '\n' {
+			end = i
+			continue
+		}
+
+		before := stdout[:i]
+		if i > 0 {
+			before = stdout[:i-1]
+		}
+
+		lineEnd := strings.IndexByte(stdout[i:], '\n')
+		if lineEnd < 0 {
+			return stdout[i+len(marker):], before, true
+		}
+		lineEnd += i
+
+		return stdout[i+len(marker) : lineEnd], before + stdout[lineEnd+1:], true
+	}
+}
+
+var callExpression = regexp.MustCompile(`^\s*([A-Za-z_$][\w$]*)\s*\(([\s\S]*)\)\s*;?\s*$`)
+
+// CallStdin parses a call expression, the input format of problems without
+// a signature, and returns what their harnesses read: the run's marker, the
+// function's name and then each argument as compact JSON, a line each. The
+// arguments are data, so the harness never evaluates the input.
+func CallStdin(call string, marker string) (string, error) {
+	match := callExpression.FindStringSubmatch(call)
+	if match == nil {
+		return "", fmt.Errorf("%w: expected a call like name(arg, ...)", ErrInvalidInput)
+	}
+
+	args, err := decodeArgs(match[2])
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+
+	var b strings.Builder
+	b.WriteString(marker + "\n" + match[1] + "\n")
+	for _, arg := range args {
+		b.WriteString(jsonText(arg) + "\n")
+	}
+	return b.String(), nil
+}
+
+// lines counts the lines written so far, for line directives pointing
+// back at the generated file.
+func lines(b *strings.Builder) int {
+	return strings.Count(b.String(), "\n")
+}
+
+// Stub returns empty starter code for the signature in the language.
+func Stub(language string, sig models.Signature) (string, error) {
+	gen, ok := generators[language]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+
+	parsed, err := parseSignature(sig)
+	if err != nil {
+		return "", err
+	}
+
+	return gen.stub(parsed), nil
+}
+
+// jsonText encodes a value the way it would appear in JSON source, without
+// escaping HTML characters.
+func jsonText(value any) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+	return string(bytes.TrimRight(buf.Bytes(), "\n"))
+}
@@ -0,0 +1,310 @@
+package harness
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+// marker stands in for the one NewMarker makes for each run
+const marker = "5f0e9c2a"
+
+func TestSplitOutput(t *testing.T) {
+	tests := []struct {
+		name       string
+		stdout     string
+		wantResult string
+		wantLog    string
+		wantOK     bool
+	}{
+		{
+			name:       "result only",
+			stdout:     "\n" + marker + "[1,2]\n",
+			wantResult: "[1,2]",
+			wantOK:     true,
+		},
+		{
+			name:       "logs after the result",
+			stdout:     "\n" + marker + "3\nhello\nworld\n",
+			wantResult: "3",
+			wantLog:    "hello\nworld\n",
+			wantOK:     true,
+		},
+		{
+			name:       "output that got past the capture",
+			stdout:     "no newline\n" + marker + "3\nlater\n",
+			wantResult: "3",
+			wantLog:    "no newlinelater\n",
+			wantOK:     true,
+		},
+		{
+			name:       "marker at the very start",
+			stdout:     marker + "true",
+			wantResult: "true",
+			wantOK:     true,
+		},
+		{
+			name:       "marker printed mid line is a log",
+			stdout:     "fake " + marker + "1\n\n" + marker + "2\n",
+			wantResult: "2",
+			wantLog:    "fake " + marker + "1\n",
+			wantOK:     true,
+		},
+		{
+			name:       "only the last result line counts",
+			stdout:     "\n" + marker + "1\n\n" + marker + "2\n",
+			wantResult: "2",
+			wantLog:    "\n" + marker + "1\n",
+			wantOK:     true,
+		},
+		{
+			name:    "another run's marker",
+			stdout:  "\n0a1b2c3d1\n",
+			wantLog: "\n0a1b2c3d1\n",
+		},
+		{
+			name:    "no result line",
+			stdout:  "Runtime Error\n",
+			wantLog: "Runtime Error\n",
+		},
+		{
+			name: "empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, log, ok := SplitOutput(tt.stdout, marker)
+			if result != tt.wantResult || log != tt.wantLog || ok != tt.wantOK {
+				t.Errorf("SplitOutput(%q) = %q, %q, %v, want %q, %q, %v", tt.stdout, result, log, ok, tt.wantResult, tt.wantLog, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		sig  models.Signature
+		ok   bool
+	}{
+		{
+			name: "valid",
+			sig:  models.Signature{FunctionName: "twoSum", Params: []models.Param{{Name: "nums", Type: "int[]"}, {Name: "target", Type: "int"}}, ReturnType: "int[]"},
+			ok:   true,
+		},
+		{
+			name: "no parameters",
+			sig:  models.Signature{FunctionName: "answer", ReturnType: "string"},
+			ok:   true,
+		},
+		{
+			name: "bad function name",
+			sig:  models.Signature{FunctionName: "two-sum", ReturnType: "int"},
+		},
+		{
+			name: "function name starting with a digit",
+			sig:  models.Signature{FunctionName: "2sum", ReturnType: "int"},
+		},
+		{
+			name: "bad parameter name",
+			sig:  models.Signature{FunctionName: "f", Params: []models.Param{{Name: "a b", Type: "int"}}, ReturnType: "int"},
+		},
+		{
+			name: "unknown parameter type",
+			sig:  models.Signature{FunctionName: "f", Params: []models.Param{{Name: "a", Type: "map"}}, ReturnType: "int"},
+		},
+		{
+			name: "unknown return type",
+			sig:  models.Signature{FunctionName: "f", ReturnType: "void"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.sig)
+			if tt.ok && err != nil {
+				t.Errorf("Validate() error: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Validate() error = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestStdin(t *testing.T) {
+	sig := func(types ...string) models.Signature {
+		s := models.Signature{FunctionName: "solve", ReturnType: "int"}
+		for i, typ := range types {
+			s.Params = append(s.Params, models.Param{Name: string(rune('a' + i)), Type: typ})
+		}
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		sig     models.Signature
+		input   string
+		want    string
+		wantErr error
+	}{
+		{"comma separated", sig("int[]", "int"), "[2, 7, 11], 9", "[2,7,11]\n9\n", nil},
+		{"one per line", sig("int[]", "int"), "[2,7,11]\n9", "[2,7,11]\n9\n", nil},
+		{"strings and chars", sig("string", "char[]"), `"a<b", ["x","y"]`, "\"a<b\"\n[\"x\",\"y\"]\n", nil},
+		{"tree with gaps", sig("TreeNode"), "[1,null,2]", "[1,null,2]\n", nil},
+		{"empty list", sig("ListNode"), "null", "null\n", nil},
+		{"graph", sig("graph"), "[[1],[0]]", "[[1],[0]]\n", nil},
+		{"big numbers keep their digits", sig("long", "double"), "9007199254740993, 0.1", "9007199254740993\n0.1\n", nil},
+		{"int at its limits", sig("int", "int"), "2147483647, -2147483648", "2147483647\n-2147483648\n", nil},
+		{"int overflow", sig("int"), "2147483648", "", ErrInvalidInput},
+		{"int overflow in an array", sig("int[]"), "[1, -2147483649]", "", ErrInvalidInput},
+		{"long past int", sig("long"), "2147483648", "2147483648\n", nil},
+		{"fraction for int", sig("int"), "1.5", "", ErrInvalidInput},
+		{"too few arguments", sig("int", "int"), "1", "", ErrInvalidInput},
+		{"wrong type", sig("string"), "1", "", ErrInvalidInput},
+		{"long char", sig("char"), `"ab"`, "", ErrInvalidInput},
+		{"null inside a list", sig("ListNode"), "[1,null]", "", ErrInvalidInput},
+		{"not json", sig("int"), "abc", "", ErrInvalidInput},
+		{"bad type", sig("int[][][]"), "1", "", ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Stdin(tt.sig, tt.input, marker)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Stdin(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+			}
+			if err == nil && got != marker+"\n"+tt.want {
+				t.Errorf("Stdin(%q) = %q, want %q after the marker", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallStdin(t *testing.T) {
+	tests := []struct {
+		name    string
+		call    string
+		want    string
+		wantErr bool
+	}{
+		{"simple", "twoSum([2,7,11,15], 9)", "twoSum\n[2,7,11,15]\n9\n", false},
+		{"spaces and semicolon", "  add ( 1 , 2 ) ;\n", "add\n1\n2\n", false},
+		{"no arguments", "answer()", "answer\n", false},
+		{"nested values", `f({"a": [true, null]}, "x")`, "f\n{\"a\":[true,null]}\n\"x\"\n", false},
+		{"dollar and underscore", "$_f(1)", "$_f\n1\n", false},
+		{"code in the arguments", "f(process.exit(0))", "", true},
+		{"not a call", "1 + 2", "", true},
+		{"member call", "obj.f(1)", "", true},
+		{"trailing code", "f(1); g(2)", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CallStdin(tt.call, marker)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("CallStdin(%q) error = %v, want ErrInvalidInput", tt.call, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CallStdin(%q) error: %v", tt.call, err)
+			}
+			if got != marker+"\n"+tt.want {
+				t.Errorf("CallStdin(%q) = %q, want %q after the marker", tt.call, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseType(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Type
+		wantErr bool
+	}{
+		{"int", Type{Base: baseInt}, false},
+		{" string[] ", Type{Base: baseString, Dims: 1}, false},
+		{"int[][]", Type{Base: baseInt, Dims: 2}, false},
+		{"graph", Type{Base: baseInt, Dims: 2}, false},
+		{"TreeNode", Type{Base: baseTreeNode}, false},
+		{"ListNode[]", Type{Base: baseListNode, Dims: 1}, false},
+		{"int[][][]", Type{}, true},
+		{"float", Type{}, true},
+		{"", Type{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseType(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseType(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseType(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShrinkInput(t *testing.T) {
+	numsFlag := models.Signature{FunctionName: "f", Params: []models.Param{{Name: "nums", Type: "int[]"}, {Name: "flag", Type: "bool"}}, ReturnType: "int"}
+	letter := models.Signature{FunctionName: "f", Params: []models.Param{{Name: "c", Type: "char"}}, ReturnType: "int"}
+
+	tests := []struct {
+		name  string
+		sig   models.Signature
+		input string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "removals before smaller values",
+			sig:   numsFlag,
+			input: "[2,0]\ntrue",
+			limit: 10,
+			want:  []string{"[0]\ntrue", "[2]\ntrue", "[0,0]\ntrue", "[1,0]\ntrue", "[2,0]\nfalse"},
+		},
+		{
+			name:  "halves before single elements",
+			sig:   numsFlag,
+			input: "[0,0,0,1]\nfalse",
+			limit: 3,
+			want:  []string{"[0,1]\nfalse", "[0,0]\nfalse", "[0,0,1]\nfalse"},
+		},
+		{
+			name:  "nothing smaller",
+			sig:   numsFlag,
+			input: "[]\nfalse",
+			limit: 10,
+		},
+		{
+			name:  "a char stays one character",
+			sig:   letter,
+			input: `"a"`,
+			limit: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ShrinkInput(tt.sig, tt.input, tt.limit)
+			if err != nil {
+				t.Fatalf("ShrinkInput() error: %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("ShrinkInput() = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ShrinkInput() = %q, want %q", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package harness
+
+import (
+	"fmt"
+	"strings"
+)
+
+type java struct{}
+
+// The user's imports are moved up to follow ours, Java wants them before any
+// class. Their classes go after Main, declaration order doesn't matter.
+const javaPrelude = `import java.util.*;
+
+`
+
+const javaHelpers = `
+
+class TreeNode {
+    int val;
+    TreeNode left;
+    TreeNode right;
+    TreeNode() {}
+    TreeNode(int val) { this.val = val; }
+    TreeNode(int val, TreeNode left, TreeNode right) { this.val = val; this.left = left; this.right = right; }
+}
+
+class ListNode {
+    int val;
+    ListNode next;
+    ListNode() {}
+    ListNode(int val) { this.val = val; }
+    ListNode(int val, ListNode next) { this.val = val; this.next = next; }
+}
+
+public class Main {
+    static String harnessText;
+    static int harnessPos;
+
+    // harnessParse reads one argument's JSON into lists, strings, booleans,
+    // BigDecimals and nulls, which the generated main converts.
+    static Object harnessParse(String text) {
+        harnessText = text;
+        harnessPos = 0;
+        return harnessValue();
+    }
+
+    static void harnessSkip() {
+        while (harnessPos < harnessText.length() && Character.isWhitespace(harnessText.charAt(harnessPos))) harnessPos++;
+    }
+
+    static Object harnessValue() {
+        harnessSkip();
+        char c = harnessText.charAt(harnessPos);
+        if (c == '[') {
+            List<Object> out = new ArrayList<>();
+            harnessPos++;
+            harnessSkip();
+            if (harnessText.charAt(harnessPos) == ']') {
+                harnessPos++;
+                return out;
+            }
+            while (true) {
+                out.add(harnessValue());
+                harnessSkip();
+                if (harnessText.charAt(harnessPos++) == ']') return out;
+            }
+        }
+        if (c == '"') {
+            StringBuilder out = new StringBuilder();
+            harnessPos++;
+            while (harnessText.charAt(harnessPos) != '"') {
+                char d = harnessText.charAt(harnessPos++);
+                if (d != '\\') {
+                    out.append(d);
+                    continue;
+                }
+                char e = harnessText.charAt(harnessPos++);
+                switch (e) {
+                    case 'n': out.append('\n'); break;
+                    case 't': out.append('\t'); break;
+                    case 'r': out.append('\r'); break;
+                    case 'b': out.append('\b'); break;
+                    case 'f': out.append('\f'); break;
+                    case 'u':
+                        out.append((char) Integer.parseInt(harnessText.substring(harnessPos, harnessPos + 4), 16));
+                        harnessPos += 4;
+                        break;
+                    default: out.append(e);
+                }
+            }
+            harnessPos++;
+            return out.toString();
+        }
+        int start = harnessPos;
+        while (harnessPos < harnessText.length() && ",] \t\r\n".indexOf(harnessText.charAt(harnessPos)) < 0) harnessPos++;
+        String token = harnessText.substring(start, harnessPos);
+        if (token.equals("null")) return null;
+        if (token.equals("true") || token.equals("false")) return Boolean.valueOf(token);
+        return new java.math.BigDecimal(token);
+    }
+
+    @SuppressWarnings("unchecked")
+    static List<Object> harnessList(Object value) {
+        return value == null ? new ArrayList<>() : (List<Object>) value;
+    }
+
+    static boolean[] harnessBooleans(Object value) {
+        List<Object> values = harnessList(value);
+        boolean[] out = new boolean[values.size()];
+        for (int i = 0; i < out.length; i++) out[i] = (Boolean) values.get(i);
+        return out;
+    }
+
+    static char harnessChar(Object value) {
+        String text = (String) value;
+        return text.isEmpty() ? '\0' : text.charAt(0);
+    }
+
+    static char[] harnessChars(Object value) {
+        List<Object> values = harnessList(value);
+        char[] out = new char[values.size()];
+        for (int i = 0; i < out.length; i++) out[i] = harnessChar(values.get(i));
+        return out;
+    }
+
+    static TreeNode harnessBuildTree(List<Object> values) {
+        if (values.isEmpty() || values.get(0) == null) return null;
+        TreeNode root = new TreeNode(((Number) values.get(0)).intValue());
+        List<TreeNode> queue = new ArrayList<>();
+        queue.add(root);
+        int head = 0, i = 1;
+        while (head < queue.size() && i < values.size()) {
+            TreeNode node = queue.get(head++);
+            if (i < values.size() && values.get(i) != null) {
+                node.left = new TreeNode(((Number) values.get(i)).intValue());
+                queue.add(node.left);
+            }
+            i++;
+            if (i < values.size() && values.get(i) != null) {
+                node.right = new TreeNode(((Number) values.get(i)).intValue());
+                queue.add(node.right);
+            }
+            i++;
+        }
+        return root;
+    }
+
+    static ListNode harnessBuildList(List<Object> values) {
+        ListNode dummy = new ListNode();
+        ListNode tail = dummy;
+        for (Object value : values) {
+            tail.next = new ListNode(((Number) value).intValue());
+            tail = tail.next;
+        }
+        return dummy.next;
+    }
+
+    static String harnessQuote(String value) {
+        StringBuilder out = new StringBuilder("\"");
+        for (char c : value.toCharArray()) {
+            switch (c) {
+                case '"': out.append("\\\""); break;
+                case '\\': out.append("\\\\"); break;
+                case '\n': out.append("\\n"); break;
+                case '\r': out.append("\\r"); break;
+                case '\t': out.append("\\t"); break;
+                default:
+                    if (c < 0x20) out.append(String.format("\\u%04x", (int) c));
+                    else out.append(c);
+            }
+        }
+        return out.append("\"").toString();
+    }
+
+    static String harnessJson(Object value) {
+        if (value == null) return "null";
+        if (value instanceof TreeNode) {
+            List<String> out = new ArrayList<>();
+            List<TreeNode> queue = new ArrayList<>();
+            queue.add((TreeNode) value);
+            for (int head = 0; head < queue.size(); head++) {
+                TreeNode node = queue.get(head);
+                if (node == null) {
+                    out.add("null");
+                    continue;
+                }
+                out.add(String.valueOf(node.val));
+                queue.add(node.left);
+                queue.add(node.right);
+            }
+            while (!out.isEmpty() && out.get(out.size() - 1).equals("null")) out.remove(out.size() - 1);
+            return "[" + String.join(",", out) + "]";
+        }
+        if (value instanceof ListNode) {
+            List<String> out = new ArrayList<>();
+            for (ListNode node = (ListNode) value; node != null; node = node.next) out.add(String.valueOf(node.val));
+            return "[" + String.join(",", out) + "]";
+        }
+        if (value instanceof String) return harnessQuote((String) value);
+        if (value instanceof Character) return harnessQuote(String.valueOf(value));
+        if (value instanceof Double || value instanceof Float) {
+            return java.math.BigDecimal.valueOf(((Number) value).doubleValue()).stripTrailingZeros().toPlainString();
+        }
+        if (value instanceof Number || value instanceof Boolean) return String.valueOf(value);
+        List<String> out = new ArrayList<>();
+        if (value instanceof Iterable) {
+            for (Object item : (Iterable<?>) value) out.add(harnessJson(item));
+        } else if (value.getClass().isArray()) {
+            for (int i = 0; i < java.lang.reflect.Array.getLength(value); i++) out.add(harnessJson(java.lang.reflect.Array.get(value, i)));
+        } else {
+            return harnessQuote(value.toString());
+        }
+        return "[" + String.join(",", out) + "]";
+    }
+`
+
+func (java) program(sig signature, code string) string {
+	var b strings.Builder
+
+	imports, code := javaImports(code)
+
+	b.WriteString(javaPrelude)
+	b.WriteString(imports)
+	b.WriteString(javaHelpers)
+
+	b.WriteString("\n    public static void main(String[] args) throws Exception {\n")
+	b.WriteString("        java.io.BufferedReader harnessIn = new java.io.BufferedReader(new java.io.InputStreamReader(System.in, \"UTF-8\"));\n")
+	b.WriteString("        List<String> harnessLines = new ArrayList<>();\n")
+	b.WriteString("        for (String line; (line = harnessIn.readLine()) != null;) if (!line.isEmpty()) harnessLines.add(line);\n")
+
+	names := make([]string, len(sig.Params))
+	for i, t := range sig.Params {
+		names[i] = fmt.Sprintf("harnessArg%d", i)
+		fmt.Fprintf(&b, "        %s %s = %s;\n", javaType(t), names[i], javaConv(t, fmt.Sprintf("harnessParse(harnessLines.get(%d))", i+1), 0))
+	}
+
+	fmt.Fprintf(&b, `        java.io.PrintStream harnessStdout = System.out;
+        java.io.ByteArrayOutputStream harnessLogs = new java.io.ByteArrayOutputStream();
+        System.setOut(new java.io.PrintStream(harnessLogs, true, "UTF-8"));
+        Object harnessResult;
+        try {
+            harnessResult = new Solution().%s(%s);
+        } catch (Throwable e) {
+            System.setOut(harnessStdout);
+            harnessStdout.print(harnessLogs.toString("UTF-8"));
+            throw e;
+        }
+        System.setOut(harnessStdout);
+        harnessStdout.print("\n" + harnessLines.get(0) + harnessJson(harnessResult) + "\n");
+        harnessStdout.print(harnessLogs.toString("UTF-8"));
+        harnessStdout.flush();
+    }
+}
+`, sig.Name, strings.Join(names, ", "))
+
+	// Last, where an unclosed block can only swallow the end of the file.
+	// Only Main can be public in Main.java.
+	b.WriteString("\n" + strings.Replace(code, "public class Solution", "class Solution", 1) + "\n")
+
+	return b.String()
+}
+
+// javaImports takes the import lines off the top of the code. They're left
+// blank in the rest, so its lines keep their distance from each other.
+func javaImports(code string) (string, string) {
+	var imports strings.Builder
+
+	codeLines := strings.Split(code, "\n")
+	for i, line := range codeLines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		if !strings.HasPrefix(trimmed, "import ") || !strings.HasSuffix(trimmed, ";") {
+			break
+		}
+
+		imports.WriteString(trimmed + "\n")
+		codeLines[i] = ""
+	}
+
+	return imports.String(), strings.Join(codeLines, "\n")
+}
+
+// javaConv converts expr, a parsed JSON value, to t. Lambda parameters are
+// numbered by depth since Java doesn't let them shadow each other.
+func javaConv(t Type, expr string, depth int) string {
+	if t.Dims > 0 {
+		elem := t.elem()
+		v := fmt.Sprintf("v%d", depth)
+		stream := "harnessList(" + expr + ").stream()"
+
+		if elem.Dims == 0 {
+			switch elem.Base {
+			case baseInt:
+				return stream + ".mapToInt(" + v + " -> ((Number) " + v + ").intValue()).toArray()"
+			case baseLong:
+				return stream + ".mapToLong(" + v + " -> ((Number) " + v + ").longValue()).toArray()"
+			case baseDouble:
+				return stream + ".mapToDouble(" + v + " -> ((Number) " + v + ").doubleValue()).toArray()"
+			case baseBool:
+				return "harnessBooleans(" + expr + ")"
+			case baseChar:
+				return "harnessChars(" + expr + ")"
+			}
+		}
+
+		return fmt.Sprintf("%s.map(%s -> %s).toArray(%s[]::new)", stream, v, javaConv(elem, v, depth+1), javaType(elem))
+	}
+
+	switch t.Base {
+	case baseInt:
+		return "((Number) " + expr + ").intValue()"
+	case baseLong:
+		return "((Number) " + expr + ").longValue()"
+	case baseDouble:
+		return "((Number) " + expr + ").doubleValue()"
+	case baseBool:
+		return "(Boolean) " + expr
+	case baseChar:
+		return "harnessChar(" + expr + ")"
+	case baseString:
+		return "(String) " + expr
+	case baseTreeNode:
+		return "harnessBuildTree(harnessList(" + expr + "))"
+	default:
+		return "harnessBuildList(harnessList(" + expr + "))"
+	}
+}
+
+func (java) stub(sig signature) string {
+	params := make([]string, len(sig.Params))
+	for i, name := range sig.ParamNames {
+		params[i] = javaType(sig.Params[i]) + " " + name
+	}
+
+	return fmt.Sprintf("class Solution {\n    public %s %s(%s) {\n\n    }\n}\n", javaType(sig.Return), sig.Name, strings.Join(params, ", "))
+}
+
+func javaType(t Type) string {
+	if t.Dims > 0 {
+		return javaType(t.elem()) + "[]"
+	}
+
+	switch t.Base {
+	case baseInt:
+		return "int"
+	case baseLong:
+		return "long"
+	case baseDouble:
+		return "double"
+	case baseBool:
+		return "boolean"
+	case baseChar:
+		return "char"
+	case baseString:
+		return "String"
+	default:
+		return t.Base
+	}
+}
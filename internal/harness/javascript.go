@@ -0,0 +1,261 @@
+package harness
+
+import (
+	"fmt"
+	"strings"
+)
+
+// javascript also covers TypeScript, which differs in the node classes, the
+// stubs and where the user's code goes. The helpers are untyped,
+// TypeScript's defaults allow that.
+type javascript struct {
+	typed bool
+}
+
+const jsNodes = `class TreeNode {
+	constructor(val, left, right) {
+		this.val = val === undefined ? 0 : val;
+		this.left = left === undefined ? null : left;
+		this.right = right === undefined ? null : right;
+	}
+}
+
+class ListNode {
+	constructor(val, next) {
+		this.val = val === undefined ? 0 : val;
+		this.next = next === undefined ? null : next;
+	}
+}
+`
+
+const tsNodes = `class TreeNode {
+	val: number;
+	left: TreeNode | null;
+	right: TreeNode | null;
+	constructor(val?: number, left?: TreeNode | null, right?: TreeNode | null) {
+		this.val = val === undefined ? 0 : val;
+		this.left = left === undefined ? null : left;
+		this.right = right === undefined ? null : right;
+	}
+}
+
+class ListNode {
+	val: number;
+	next: ListNode | null;
+	constructor(val?: number, next?: ListNode | null) {
+		this.val = val === undefined ? 0 : val;
+		this.next = next === undefined ? null : next;
+	}
+}
+`
+
+// jsIO reads the marker and arguments before the user's code runs and holds back what
+// it prints until harnessFinish, which writes the result line first.
+const jsIO = `
+const [harnessMarker, ...harnessLines] = require("fs").readFileSync(0, "utf8").split("\n").filter((line) => line !== "");
+const harnessArgs = harnessLines.map((line) => JSON.parse(line));
+const harnessWrite = process.stdout.write.bind(process.stdout);
+const harnessLogs = [];
+process.stdout.write = (chunk) => {
+	harnessLogs.push(String(chunk));
+	return true;
+};
+
+function harnessFinish(result) {
+	process.stdout.write = harnessWrite;
+	harnessWrite(result + harnessLogs.join(""));
+}
+`
+
+// tsIO is jsIO without relying on the node typings, require and process
+// are reached through eval and globalThis.
+const tsIO = `
+const harnessProcess: any = (globalThis as any).process;
+const [harnessMarker, ...harnessLines]: string[] = eval("require")("fs").readFileSync(0, "utf8").split("\n").filter((line: string) => line !== "");
+const harnessArgs: any[] = harnessLines.map((line: string) => JSON.parse(line));
+const harnessWrite = harnessProcess.stdout.write.bind(harnessProcess.stdout);
+const harnessLogs: string[] = [];
+harnessProcess.stdout.write = (chunk: any) => {
+	harnessLogs.push(String(chunk));
+	return true;
+};
+
+function harnessFinish(result: string) {
+	harnessProcess.stdout.write = harnessWrite;
+	harnessWrite(result + harnessLogs.join(""));
+}
+`
+
+const jsHelpers = `
+function harnessBuild(value, base, dims) {
+	if (dims > 0) {
+		return (value || []).map((v) => harnessBuild(v, base, dims - 1));
+	}
+	if (base === "TreeNode") return harnessBuildTree(value);
+	if (base === "ListNode") return harnessBuildList(value);
+	return value;
+}
+function harnessBuildTree(values) {
+	if (!values || values.length === 0 || values[0] === null) return null;
+	const root = new TreeNode(values[0]);
+	const queue = [root];
+	let head = 0;
+	let i = 1;
+	while (head < queue.length && i < values.length) {
+		const node = queue[head++];
+		if (i < values.length && values[i] !== null) {
+			node.left = new TreeNode(values[i]);
+			queue.push(node.left);
+		}
+		i++;
+		if (i < values.length && values[i] !== null) {
+			node.right = new TreeNode(values[i]);
+			queue.push(node.right);
+		}
+		i++;
+	}
+	return root;
+}
+
+function harnessBuildList(values) {
+	const dummy = new ListNode(0);
+	let tail = dummy;
+	for (const value of values || []) {
+		tail.next = new ListNode(value);
+		tail = tail.next;
+	}
+	return dummy.next;
+}
+
+function harnessSerialize(value, base, dims) {
+	if (dims > 0) {
+		return (value || []).map((v) => harnessSerialize(v, base, dims - 1));
+	}
+	if (base === "TreeNode") {
+		const out = [];
+		const queue = [value];
+		let head = 0;
+		while (head < queue.length) {
+			const node = queue[head++];
+			if (node === null || node === undefined) {
+				out.push(null);
+				continue;
+			}
+			out.push(node.val);
+			queue.push(node.left, node.right);
+		}
+		while (out.length > 0 && out[out.length - 1] === null) out.pop();
+		return out;
+	}
+	if (base === "ListNode") {
+		const out = [];
+		for (let node = value; node; node = node.next) out.push(node.val);
+		return out;
+	}
+	return value;
+}
+`
+
+func (j javascript) program(sig signature, code string) string {
+	var b strings.Builder
+
+	if j.typed {
+		b.WriteString(tsNodes)
+		b.WriteString(tsIO)
+	} else {
+		b.WriteString(jsNodes)
+		b.WriteString(jsIO)
+	}
+
+	b.WriteString(jsHelpers)
+
+	args := make([]string, len(sig.Params))
+	for i, t := range sig.Params {
+		args[i] = fmt.Sprintf("harnessBuild(harnessArgs[%d], %q, %d)", i, t.Base, t.Dims)
+	}
+	result := fmt.Sprintf(`harnessFinish("\n" + harnessMarker + JSON.stringify(harnessSerialize(harnessResult, %q, %d)) + "\n");`,
+		sig.Return.Base, sig.Return.Dims)
+
+	if !j.typed {
+		// The user's code runs as a script of its own, solution.js, so its
+		// errors point at their lines. Its top level declarations land in
+		// the global scope, where the function is looked up.
+		fmt.Fprintf(&b, `
+globalThis.TreeNode = TreeNode;
+globalThis.ListNode = ListNode;
+globalThis.require = require;
+
+try {
+	const harnessVm = require("vm");
+	harnessVm.runInThisContext(%s, { filename: "solution.js" });
+	const harnessResult = harnessVm.runInThisContext(%q)(%s);
+	%s
+} catch (error) {
+	harnessFinish("");
+	console.error('Runtime Error:', error instanceof SyntaxError ? error.stack : error.message);
+	process.exit(1);
+}
+`, jsonText(code), sig.Name, strings.Join(args, ", "), result)
+
+		return b.String()
+	}
+
+	// TypeScript has to be compiled with the rest, so the user's code goes
+	// last, where an unclosed block can only swallow the end of the file.
+	// The call waits for it to have run. No process.exit, the node typings
+	// may not be installed, rethrowing still exits non-zero.
+	fmt.Fprintf(&b, `
+function harnessMain() {
+	try {
+		const harnessResult = %s(%s);
+		%s
+	} catch (error) {
+		harnessFinish("");
+		console.error('Runtime Error:', error.message);
+		throw error;
+	}
+}
+
+(globalThis as any).setTimeout(harnessMain, 0);
+
+`, sig.Name, strings.Join(args, ", "), result)
+	b.WriteString(code + "\n")
+
+	return b.String()
+}
+
+func (j javascript) stub(sig signature) string {
+	params := make([]string, len(sig.Params))
+	for i, name := range sig.ParamNames {
+		params[i] = name
+		if j.typed {
+			params[i] += ": " + tsType(sig.Params[i])
+		}
+	}
+
+	if j.typed {
+		return fmt.Sprintf("function %s(%s): %s {\n\n}\n", sig.Name, strings.Join(params, ", "), tsType(sig.Return))
+	}
+	return fmt.Sprintf("function %s(%s) {\n\n}\n", sig.Name, strings.Join(params, ", "))
+}
+
+func tsType(t Type) string {
+	if t.Dims > 0 {
+		elem := tsType(t.elem())
+		if t.elem().isNode() {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	}
+
+	switch t.Base {
+	case baseInt, baseLong, baseDouble:
+		return "number"
+	case baseBool:
+		return "boolean"
+	case baseChar, baseString:
+		return "string"
+	default:
+		return t.Base + " | null"
+	}
+}
@@ -0,0 +1,172 @@
+package harness
+
+import (
+	"fmt"
+	"strings"
+)
+
+type python struct{}
+
+// The marker and arguments are read before the user's code runs, and what it prints
+// goes to harness_logs until harness_finish writes the result line first.
+const pythonPrelude = `import io
+import json
+import sys
+import traceback
+
+sys.setrecursionlimit(10**6)
+
+harness_marker, *harness_lines = [line for line in sys.stdin.read().split("\n") if line]
+harness_args = [json.loads(line) for line in harness_lines]
+harness_stdout = sys.stdout
+harness_logs = sys.stdout = io.StringIO()
+
+
+class TreeNode:
+    def __init__(self, val=0, left=None, right=None):
+        self.val = val
+        self.left = left
+        self.right = right
+
+
+class ListNode:
+    def __init__(self, val=0, next=None):
+        self.val = val
+        self.next = next
+
+`
+
+const pythonHelpers = `
+
+def harness_finish(result):
+    sys.stdout = harness_stdout
+    sys.stdout.write(result + harness_logs.getvalue())
+
+
+def harness_build(value, base, dims):
+    if dims > 0:
+        return [harness_build(v, base, dims - 1) for v in (value or [])]
+    if base == "TreeNode":
+        return harness_build_tree(value)
+    if base == "ListNode":
+        return harness_build_list(value)
+    return value
+
+
+def harness_build_tree(values):
+    if not values or values[0] is None:
+        return None
+    root = TreeNode(values[0])
+    queue = [root]
+    head, i = 0, 1
+    while head < len(queue) and i < len(values):
+        node = queue[head]
+        head += 1
+        if i < len(values) and values[i] is not None:
+            node.left = TreeNode(values[i])
+            queue.append(node.left)
+        i += 1
+        if i < len(values) and values[i] is not None:
+            node.right = TreeNode(values[i])
+            queue.append(node.right)
+        i += 1
+    return root
+
+
+def harness_build_list(values):
+    dummy = tail = ListNode()
+    for value in values or []:
+        tail.next = ListNode(value)
+        tail = tail.next
+    return dummy.next
+
+
+def harness_serialize(value, base, dims):
+    if dims > 0:
+        return [harness_serialize(v, base, dims - 1) for v in (value or [])]
+    if base == "TreeNode":
+        out, queue, head = [], [value], 0
+        while head < len(queue):
+            node = queue[head]
+            head += 1
+            if node is None:
+                out.append(None)
+                continue
+            out.append(node.val)
+            queue.extend([node.left, node.right])
+        while out and out[-1] is None:
+            out.pop()
+        return out
+    if base == "ListNode":
+        out = []
+        while value:
+            out.append(value.val)
+            value = value.next
+        return out
+    return value
+
+`
+
+func (python) program(sig signature, code string) string {
+	var b strings.Builder
+
+	b.WriteString(pythonPrelude)
+	b.WriteString(pythonHelpers)
+
+	args := make([]string, len(sig.Params))
+	for i, t := range sig.Params {
+		args[i] = fmt.Sprintf("harness_build(harness_args[%d], %q, %d)", i, t.Base, t.Dims)
+	}
+
+	// The user's code is compiled on its own as solution.py, so its errors
+	// point at their lines. LeetCode style code wraps the function in a
+	// Solution class.
+	fmt.Fprintf(&b, `
+harness_scope = {"__name__": "__main__", "TreeNode": TreeNode, "ListNode": ListNode}
+
+try:
+    exec("from typing import *", harness_scope)
+    exec(compile(%[5]s, "solution.py", "exec"), harness_scope)
+    harness_fn = harness_scope["Solution"]().%[1]s if "Solution" in harness_scope else harness_scope[%[1]q]
+    harness_result = harness_fn(%[2]s)
+    harness_finish("\n" + harness_marker + json.dumps(harness_serialize(harness_result, %[3]q, %[4]d), separators=(",", ":")) + "\n")
+except SyntaxError as error:
+    harness_finish("")
+    print("".join(traceback.format_exception_only(type(error), error)), end="", file=sys.stderr)
+    sys.exit(1)
+except Exception as error:
+    harness_finish("")
+    print("Runtime Error:", error, file=sys.stderr)
+    sys.exit(1)
+`, sig.Name, strings.Join(args, ", "), sig.Return.Base, sig.Return.Dims, jsonText(code))
+
+	return b.String()
+}
+
+func (python) stub(sig signature) string {
+	params := []string{"self"}
+	for i, name := range sig.ParamNames {
+		params = append(params, name+": "+pyType(sig.Params[i]))
+	}
+
+	return fmt.Sprintf("class Solution:\n    def %s(%s) -> %s:\n        pass\n", sig.Name, strings.Join(params, ", "), pyType(sig.Return))
+}
+
+func pyType(t Type) string {
+	if t.Dims > 0 {
+		return "List[" + pyType(t.elem()) + "]"
+	}
+
+	switch t.Base {
+	case baseInt, baseLong:
+		return "int"
+	case baseDouble:
+		return "float"
+	case baseBool:
+		return "bool"
+	case baseChar, baseString:
+		return "str"
+	default:
+		return "Optional[" + t.Base + "]"
+	}
+}
@@ -0,0 +1,214 @@
+package harness
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+// RandomInput makes a testcase input for the signature out of random values.
+// size bounds the length of arrays and strings and the magnitude of numbers,
+// small sizes give inputs small enough to debug by hand.
+func RandomInput(sig models.Signature, rng *rand.Rand, size int) (string, error) {
+	parsed, err := parseSignature(sig)
+	if err != nil {
+		return "", err
+	}
+
+	args := make([]string, len(parsed.Params))
+	for i, t := range parsed.Params {
+		args[i] = jsonText(randomValue(t, rng, size))
+	}
+
+	return strings.Join(args, "\n"), nil
+}
+
+func randomValue(t Type, rng *rand.Rand, size int) any {
+	if t.Dims > 0 {
+		values := make([]any, rng.Intn(size+1))
+		for i := range values {
+			values[i] = randomValue(t.elem(), rng, size)
+		}
+		return values
+	}
+
+	switch t.Base {
+	case baseInt, baseLong:
+		return rng.Intn(2*size+1) - size
+	case baseDouble:
+		return math.Round((rng.Float64()*2-1)*float64(size)*100) / 100
+	case baseBool:
+		return rng.Intn(2) == 1
+	case baseChar:
+		return string(rune('a' + rng.Intn(3)))
+	case baseString:
+		// Few distinct letters so repeats, the usual edge cases, come up
+		var b strings.Builder
+		for range rng.Intn(size + 1) {
+			b.WriteRune(rune('a' + rng.Intn(3)))
+		}
+		return b.String()
+	case baseListNode:
+		values := make([]any, rng.Intn(size+1))
+		for i := range values {
+			values[i] = rng.Intn(2*size+1) - size
+		}
+		return values
+	case baseTreeNode:
+		return randomTree(rng, size)
+	}
+
+	return nil
+}
+
+// randomTree returns a level order tree of up to size nodes, null for
+// missing children.
+func randomTree(rng *rand.Rand, size int) []any {
+	nodes := rng.Intn(size + 1)
+	if nodes == 0 {
+		return []any{}
+	}
+
+	values := []any{rng.Intn(2*size+1) - size}
+	open := 1
+	for placed := 1; placed < nodes && open > 0; {
+		// Each open node gets two child slots
+		for range 2 {
+			if placed < nodes && rng.Intn(3) > 0 {
+				values = append(values, rng.Intn(2*size+1)-size)
+				placed++
+				open++
+			} else {
+				values = append(values, nil)
+			}
+		}
+		open--
+	}
+
+	// Trailing nulls say nothing
+	for values[len(values)-1] == nil {
+		values = values[:len(values)-1]
+	}
+
+	return values
+}
+
+// ShrinkInput returns up to limit inputs for the signature a step smaller
+// than the given one, for narrowing down a failing case. Parts of arrays and
+// strings are dropped first, halves before single elements, then numbers
+// move toward zero and booleans to false.
+func ShrinkInput(sig models.Signature, input string, limit int) ([]string, error) {
+	parsed, err := parseSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := parseArgs(parsed, input)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{input: true}
+	var inputs []string
+	for _, removing := range []bool{true, false} {
+		for i := range args {
+			more := shrinkValue(args[i], removing, func(smaller any) bool {
+				if check(parsed.Params[i], smaller) != nil {
+					return true
+				}
+
+				next := slices.Clone(args)
+				next[i] = smaller
+
+				text := make([]string, len(next))
+				for j, arg := range next {
+					text[j] = jsonText(arg)
+				}
+				joined := strings.Join(text, "\n")
+
+				if !seen[joined] {
+					seen[joined] = true
+					inputs = append(inputs, joined)
+				}
+				return len(inputs) < limit
+			})
+			if !more {
+				return inputs, nil
+			}
+		}
+	}
+
+	return inputs, nil
+}
+
+// shrinkValue passes smaller versions of the value to yield until it returns
+// false, and reports whether it never did. removing picks between dropping
+// parts of arrays and strings and shrinking the values inside.
+func shrinkValue(value any, removing bool, yield func(any) bool) bool {
+	switch v := value.(type) {
+	case []any:
+		if removing {
+			for _, part := range chunks(len(v)) {
+				if !yield(slices.Delete(slices.Clone(v), part[0], part[1])) {
+					return false
+				}
+			}
+		}
+
+		for i, elem := range v {
+			more := shrinkValue(elem, removing, func(smaller any) bool {
+				next := slices.Clone(v)
+				next[i] = smaller
+				return yield(next)
+			})
+			if !more {
+				return false
+			}
+		}
+	case string:
+		if removing {
+			runes := []rune(v)
+			for _, part := range chunks(len(runes)) {
+				if !yield(string(slices.Delete(slices.Clone(runes), part[0], part[1]))) {
+					return false
+				}
+			}
+		}
+	case json.Number:
+		if removing {
+			return true
+		}
+		if n, err := v.Int64(); err == nil {
+			if n != 0 && !yield(json.Number("0")) {
+				return false
+			}
+			if n/2 != 0 {
+				return yield(json.Number(jsonText(n / 2)))
+			}
+		} else if f, err := v.Float64(); err == nil && f != 0 {
+			return yield(json.Number("0"))
+		}
+	case bool:
+		if !removing && v {
+			return yield(false)
+		}
+	}
+
+	return true
+}
+
+// chunks splits n elements into halves, then quarters and so on down to
+// single elements, as [start, end) pairs.
+func chunks(n int) [][2]int {
+	var parts [][2]int
+	for size := (n + 1) / 2; size >= 1; size /= 2 {
+		for start := 0; start < n; start += size {
+			parts = append(parts, [2]int{start, min(start+size, n)})
+		}
+	}
+	return parts
+}
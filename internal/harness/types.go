@@ -0,0 +1,271 @@
+package harness
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+var ErrInvalidInput = errors.New("invalid testcase input")
+
+const (
+	baseInt      = "int"
+	baseLong     = "long"
+	baseDouble   = "double"
+	baseBool     = "bool"
+	baseChar     = "char"
+	baseString   = "string"
+	baseTreeNode = "TreeNode"
+	baseListNode = "ListNode"
+)
+
+var bases = map[string]bool{
+	baseInt:      true,
+	baseLong:     true,
+	baseDouble:   true,
+	baseBool:     true,
+	baseChar:     true,
+	baseString:   true,
+	baseTreeNode: true,
+	baseListNode: true,
+}
+
+// Type is a base type with zero or more array dimensions, written the way
+// LeetCode does: int, string[], int[][], TreeNode. "graph" is an adjacency
+// list and means int[][].
+type Type struct {
+	Base string
+	Dims int
+}
+
+func ParseType(s string) (Type, error) {
+	s = strings.TrimSpace(s)
+	if s == "graph" {
+		return Type{Base: baseInt, Dims: 2}, nil
+	}
+
+	t := Type{Base: s}
+	for strings.HasSuffix(t.Base, "[]") {
+		t.Base = strings.TrimSuffix(t.Base, "[]")
+		t.Dims++
+	}
+
+	if !bases[t.Base] {
+		return Type{}, fmt.Errorf("%w: unknown type %q", ErrInvalidSignature, s)
+	}
+
+	if t.Dims > 2 {
+		return Type{}, fmt.Errorf("%w: %q has more than two dimensions", ErrInvalidSignature, s)
+	}
+
+	return t, nil
+}
+
+// elem is the type of an array's elements.
+func (t Type) elem() Type {
+	return Type{Base: t.Base, Dims: t.Dims - 1}
+}
+
+func (t Type) isNode() bool {
+	return t.Dims == 0 && (t.Base == baseTreeNode || t.Base == baseListNode)
+}
+
+// signature is a models.Signature with its types parsed.
+type signature struct {
+	Name       string
+	ParamNames []string
+	Params     []Type
+	Return     Type
+}
+
+func parseSignature(sig models.Signature) (signature, error) {
+	if !isIdentifier(sig.FunctionName) {
+		return signature{}, fmt.Errorf("%w: bad function name %q", ErrInvalidSignature, sig.FunctionName)
+	}
+
+	parsed := signature{Name: sig.FunctionName}
+	for _, param := range sig.Params {
+		if !isIdentifier(param.Name) {
+			return signature{}, fmt.Errorf("%w: bad parameter name %q", ErrInvalidSignature, param.Name)
+		}
+
+		t, err := ParseType(param.Type)
+		if err != nil {
+			return signature{}, err
+		}
+
+		parsed.ParamNames = append(parsed.ParamNames, param.Name)
+		parsed.Params = append(parsed.Params, t)
+	}
+
+	ret, err := ParseType(sig.ReturnType)
+	if err != nil {
+		return signature{}, err
+	}
+	parsed.Return = ret
+
+	return parsed, nil
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the signature's names and types.
+func Validate(sig models.Signature) error {
+	_, err := parseSignature(sig)
+	return err
+}
+
+// ValidateInput checks a testcase input holds one value of the right type
+// per parameter.
+func ValidateInput(sig models.Signature, input string) error {
+	parsed, err := parseSignature(sig)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseArgs(parsed, input)
+	return err
+}
+
+// parseArgs decodes the input into one JSON value per parameter, checking
+// each against its type. Numbers stay json.Number so they're written back
+// out exactly as given.
+func parseArgs(sig signature, input string) ([]any, error) {
+	args, err := decodeArgs(input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+
+	if len(args) != len(sig.Params) {
+		return nil, fmt.Errorf("%w: got %d arguments, %s takes %d", ErrInvalidInput, len(args), sig.Name, len(sig.Params))
+	}
+
+	for i, arg := range args {
+		if err := check(sig.Params[i], arg); err != nil {
+			return nil, fmt.Errorf("%w: argument %s: %v", ErrInvalidInput, sig.ParamNames[i], err)
+		}
+	}
+
+	return args, nil
+}
+
+// decodeArgs accepts arguments separated by commas, "[1,2],3", or by
+// whitespace with one argument per line.
+func decodeArgs(input string) ([]any, error) {
+	var args []any
+
+	decoder := json.NewDecoder(strings.NewReader("[" + input + "]"))
+	decoder.UseNumber()
+	if err := decoder.Decode(&args); err == nil {
+		return args, nil
+	}
+
+	decoder = json.NewDecoder(strings.NewReader(input))
+	decoder.UseNumber()
+	for {
+		var value any
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			return args, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+}
+
+func check(t Type, value any) error {
+	if t.Dims > 0 {
+		values, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("expected an array, got %s", describe(value))
+		}
+		for _, v := range values {
+			if err := check(t.elem(), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch t.Base {
+	case baseInt, baseLong:
+		n, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("expected an integer, got %s", describe(value))
+		}
+		i, err := n.Int64()
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %s", n)
+		}
+		// Java and C++ ints are 32 bits
+		if t.Base == baseInt && (i < math.MinInt32 || i > math.MaxInt32) {
+			return fmt.Errorf("%s is out of range for int, use long", n)
+		}
+	case baseDouble:
+		if _, ok := value.(json.Number); !ok {
+			return fmt.Errorf("expected a number, got %s", describe(value))
+		}
+	case baseBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("expected a boolean, got %s", describe(value))
+		}
+	case baseChar:
+		s, ok := value.(string)
+		if !ok || len([]rune(s)) != 1 {
+			return fmt.Errorf("expected a single character string, got %s", describe(value))
+		}
+	case baseString:
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("expected a string, got %s", describe(value))
+		}
+	case baseTreeNode, baseListNode:
+		// Level order for trees, in order for lists, null is an empty structure
+		if value == nil {
+			return nil
+		}
+		values, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("expected an array, got %s", describe(value))
+		}
+		for _, v := range values {
+			if v == nil && t.Base == baseTreeNode {
+				continue
+			}
+			if err := check(Type{Base: baseInt}, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func describe(value any) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(b)
+}
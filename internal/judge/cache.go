@@ -0,0 +1,193 @@
+package judge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/harness"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	resultCacheTTL = 24 * time.Hour
+	// Bump when the same submission should stop getting its cached result,
+	// e.g. after changing how results are produced outside the source code
+	resultCacheVersion = 2
+)
+
+// ResultCache keeps executor results in Redis keyed by a hash of everything
+// that decides them: the language, the full source with its harness and
+// testcase input baked in, stdin, compiler options and limits. Identical
+// runs are answered without going to the executor. The result marker, new
+// for every run, is left out; results are stored already split from it.
+type ResultCache struct {
+	redis  *redis.Client
+	logger *log.Logger
+}
+
+func NewResultCache(redisClient *redis.Client, logger *log.Logger) *ResultCache {
+	return &ResultCache{redis: redisClient, logger: logger}
+}
+
+func resultCacheKey(submission executor.Submission) string {
+	stdin := submission.Stdin
+	if submission.ResultMarker != "" {
+		stdin = strings.TrimPrefix(stdin, submission.ResultMarker+"\n")
+	}
+
+	payload, _ := json.Marshal(struct {
+		Version         int     `json:"version"`
+		HarnessVersion  int     `json:"harness_version"`
+		LanguageID      int     `json:"language_id"`
+		SourceCode      string  `json:"source_code"`
+		Stdin           string  `json:"stdin"`
+		CompilerOptions string  `json:"compiler_options"`
+		CPUTimeLimit    float64 `json:"cpu_time_limit"`
+		WallTimeLimit   float64 `json:"wall_time_limit"`
+		MemoryLimit     int     `json:"memory_limit"`
+	}{
+		Version:         resultCacheVersion,
+		HarnessVersion:  harness.Version,
+		LanguageID:      submission.LanguageID,
+		SourceCode:      submission.SourceCode,
+		Stdin:           stdin,
+		CompilerOptions: submission.CompilerOptions,
+		CPUTimeLimit:    submission.CPUTimeLimit,
+		WallTimeLimit:   submission.WallTimeLimit,
+		MemoryLimit:     submission.MemoryLimit,
+	})
+
+	sum := sha256.Sum256(payload)
+	return queuePrefix + "cache:result:" + hex.EncodeToString(sum[:])
+}
+
+func problemCacheKey(problemID uuid.UUID) string {
+	return queuePrefix + "cache:problem:" + problemID.String()
+}
+
+// cacheable leaves out results that say more about the judge at the time
+// than about the code.
+func cacheable(result executor.Result) bool {
+	switch result.Status.ID {
+	case executor.StatusInQueue, executor.StatusProcessing, executor.StatusTimeLimitExceeded, executor.StatusInternalError, executor.StatusExecFormatError:
+		return false
+	}
+	return true
+}
+
+// Execute runs the submissions, answering what it can from the cache and
+// handing the rest to run as one batch. onResult, if set, is called once per
+// submission as its result comes in, cached ones first. A nil cache runs
+// everything.
+func (c *ResultCache) Execute(ctx context.Context, problemID uuid.UUID, submissions []executor.Submission, run func(ctx context.Context, submissions []executor.Submission, onResult func(int, executor.Result)) ([]executor.Result, error), onResult func(int, executor.Result)) ([]executor.Result, error) {
+	if c == nil {
+		return run(ctx, submissions, onResult)
+	}
+
+	results := make([]executor.Result, len(submissions))
+	keys := make([]string, len(submissions))
+	for i, submission := range submissions {
+		keys[i] = resultCacheKey(submission)
+	}
+
+	cached, err := c.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		// The cache is an optimisation, run everything rather than fail
+		c.logger.Println("Error reading result cache", err)
+		cached = make([]any, len(submissions))
+	}
+
+	var misses []int
+	for i, value := range cached {
+		payload, ok := value.(string)
+		if !ok || json.Unmarshal([]byte(payload), &results[i]) != nil {
+			misses = append(misses, i)
+			continue
+		}
+
+		if onResult != nil {
+			onResult(i, results[i])
+		}
+	}
+
+	if len(misses) == 0 {
+		return results, nil
+	}
+
+	pending := make([]executor.Submission, len(misses))
+	for j, i := range misses {
+		pending[j] = submissions[i]
+	}
+
+	var forward func(int, executor.Result)
+	if onResult != nil {
+		forward = func(j int, result executor.Result) {
+			onResult(misses[j], result)
+		}
+	}
+
+	ran, err := run(ctx, pending, forward)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, i := range misses {
+		results[i] = ran[j]
+	}
+
+	c.store(ctx, problemID, misses, keys, results)
+	return results, nil
+}
+
+func (c *ResultCache) store(ctx context.Context, problemID uuid.UUID, indexes []int, keys []string, results []executor.Result) {
+	pipe := c.redis.TxPipeline()
+
+	stored := 0
+	for _, i := range indexes {
+		if !cacheable(results[i]) {
+			continue
+		}
+
+		payload, err := json.Marshal(results[i])
+		if err != nil {
+			continue
+		}
+
+		pipe.Set(ctx, keys[i], payload, resultCacheTTL)
+		pipe.SAdd(ctx, problemCacheKey(problemID), keys[i])
+		stored++
+	}
+
+	if stored == 0 {
+		return
+	}
+
+	pipe.Expire(ctx, problemCacheKey(problemID), resultCacheTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		c.logger.Println("Error writing result cache", err)
+	}
+}
+
+// Invalidate drops every cached result for the problem, for when its
+// testcases or limits change.
+func (c *ResultCache) Invalidate(ctx context.Context, problemID uuid.UUID) error {
+	keys, err := c.redis.SMembers(ctx, problemCacheKey(problemID)).Result()
+	if err != nil {
+		return fmt.Errorf("error getting cached results for problem: %w", err)
+	}
+
+	keys = append(keys, problemCacheKey(problemID))
+	if err := c.redis.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("error deleting cached results for problem: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package judge
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestResultCacheKey(t *testing.T) {
+	base := executor.Submission{
+		LanguageID:      71,
+		SourceCode:      "print(input())",
+		Stdin:           "1\n",
+		CompilerOptions: "-O2",
+		CPUTimeLimit:    1,
+		WallTimeLimit:   3,
+		MemoryLimit:     128000,
+	}
+
+	tests := []struct {
+		name   string
+		change func(s *executor.Submission)
+		same   bool
+	}{
+		{"identical", func(s *executor.Submission) {}, true},
+		{"language", func(s *executor.Submission) { s.LanguageID = 63 }, false},
+		{"source", func(s *executor.Submission) { s.SourceCode += " " }, false},
+		{"stdin", func(s *executor.Submission) { s.Stdin = "2\n" }, false},
+		{"compiler options", func(s *executor.Submission) { s.CompilerOptions = "" }, false},
+		{"cpu time limit", func(s *executor.Submission) { s.CPUTimeLimit = 2 }, false},
+		{"wall time limit", func(s *executor.Submission) { s.WallTimeLimit = 4 }, false},
+		{"memory limit", func(s *executor.Submission) { s.MemoryLimit = 256000 }, false},
+		{"expected output isn't part of the run", func(s *executor.Submission) { s.ExpectedOutput = "1" }, true},
+		{"result marker is new every run", func(s *executor.Submission) { s.ResultMarker = "5f0e"; s.Stdin = "5f0e\n" + s.Stdin }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := base
+			tt.change(&changed)
+
+			if same := resultCacheKey(base) == resultCacheKey(changed); same != tt.same {
+				t.Errorf("keys equal = %v, want %v", same, tt.same)
+			}
+		})
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		statusID int
+		want     bool
+	}{
+		{executor.StatusAccepted, true},
+		{executor.StatusWrongAnswer, true},
+		{executor.StatusCompilationError, true},
+		{executor.StatusRuntimeNZEC, true},
+		{executor.StatusInQueue, false},
+		{executor.StatusProcessing, false},
+		{executor.StatusTimeLimitExceeded, false},
+		{executor.StatusInternalError, false},
+		{executor.StatusExecFormatError, false},
+	}
+
+	for _, tt := range tests {
+		result := executor.Result{Status: executor.Status{ID: tt.statusID}}
+		if got := cacheable(result); got != tt.want {
+			t.Errorf("cacheable(status %d) = %v, want %v", tt.statusID, got, tt.want)
+		}
+	}
+}
+
+func TestResultCacheExecute(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	cache := NewResultCache(client, log.New(io.Discard, "", 0))
+	problemID := uuid.New()
+
+	submissions := []executor.Submission{
+		{LanguageID: 71, SourceCode: "a", Stdin: "1"},
+		{LanguageID: 71, SourceCode: "a", Stdin: "2"},
+	}
+	statuses := map[string]int{"1": executor.StatusAccepted, "2": executor.StatusTimeLimitExceeded}
+
+	var ran []string
+	run := func(ctx context.Context, submissions []executor.Submission, onResult func(int, executor.Result)) ([]executor.Result, error) {
+		results := make([]executor.Result, len(submissions))
+		for i, submission := range submissions {
+			ran = append(ran, submission.Stdin)
+			results[i] = executor.Result{Status: executor.Status{ID: statuses[submission.Stdin]}}
+			if onResult != nil {
+				onResult(i, results[i])
+			}
+		}
+		return results, nil
+	}
+
+	tests := []struct {
+		name       string
+		invalidate bool
+		wantRan    []string
+	}{
+		{name: "cold cache runs everything", wantRan: []string{"1", "2"}},
+		{name: "time limits aren't cached", wantRan: []string{"2"}},
+		{name: "invalidate drops the problem's results", invalidate: true, wantRan: []string{"1", "2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.invalidate {
+				if err := cache.Invalidate(ctx, problemID); err != nil {
+					t.Fatalf("Invalidate() error: %v", err)
+				}
+			}
+
+			ran = nil
+			seen := make([]bool, len(submissions))
+			results, err := cache.Execute(ctx, problemID, submissions, run, func(i int, result executor.Result) {
+				seen[i] = true
+			})
+			if err != nil {
+				t.Fatalf("Execute() error: %v", err)
+			}
+
+			if len(ran) != len(tt.wantRan) {
+				t.Fatalf("ran %v, want %v", ran, tt.wantRan)
+			}
+			for i := range ran {
+				if ran[i] != tt.wantRan[i] {
+					t.Fatalf("ran %v, want %v", ran, tt.wantRan)
+				}
+			}
+
+			for i, result := range results {
+				if want := statuses[submissions[i].Stdin]; result.Status.ID != want {
+					t.Errorf("result %d has status %d, want %d", i, result.Status.ID, want)
+				}
+				if !seen[i] {
+					t.Errorf("onResult wasn't called for result %d", i)
+				}
+			}
+		})
+	}
+}
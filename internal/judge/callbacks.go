@@ -0,0 +1,170 @@
+package judge
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grvbrk/async0_server/internal/executor"
+)
+
+const (
+	// How long to wait on callbacks before polling the tokens that are still out
+	callbackPollInterval = 3 * time.Second
+	// How long to hold a callback that arrived before its token was registered
+	earlyCallbackTTL = time.Minute
+	// Callbacks held at once, past that early ones are dropped and left to polling
+	maxEarlyCallbacks = 10000
+)
+
+type earlyCallback struct {
+	result     executor.Result
+	receivedAt time.Time
+}
+
+// Callbacks matches Judge0 callback_url deliveries to the jobs waiting on
+// them. Registrations are in memory, so a callback that lands on another
+// server instance is simply missed and the token gets picked up by polling.
+type Callbacks struct {
+	mu      sync.Mutex
+	waiters map[string]chan executor.Result
+	early   map[string]earlyCallback
+}
+
+func NewCallbacks() *Callbacks {
+	return &Callbacks{
+		waiters: make(map[string]chan executor.Result),
+		early:   make(map[string]earlyCallback),
+	}
+}
+
+// Deliver hands a finished result to whoever registered its token. It
+// returns false if nobody is waiting for it yet.
+func (c *Callbacks) Deliver(result executor.Result) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch, ok := c.waiters[result.Token]
+	if !ok {
+		c.pruneEarly()
+		if len(c.early) < maxEarlyCallbacks {
+			c.early[result.Token] = earlyCallback{result: result, receivedAt: time.Now()}
+		}
+		return false
+	}
+
+	delete(c.waiters, result.Token)
+	ch <- result
+	return true
+}
+
+// pruneEarly drops held callbacks nobody registered in time. The caller
+// holds the lock.
+func (c *Callbacks) pruneEarly() {
+	for token, callback := range c.early {
+		if time.Since(callback.receivedAt) > earlyCallbackTTL {
+			delete(c.early, token)
+		}
+	}
+}
+
+func (c *Callbacks) register(tokens []string) (<-chan executor.Result, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pruneEarly()
+
+	// Buffered for every token so Deliver never blocks while holding the lock
+	ch := make(chan executor.Result, len(tokens))
+	for _, token := range tokens {
+		if callback, ok := c.early[token]; ok {
+			delete(c.early, token)
+			ch <- callback.result
+			continue
+		}
+		c.waiters[token] = ch
+	}
+
+	unregister := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for _, token := range tokens {
+			if c.waiters[token] == ch {
+				delete(c.waiters, token)
+			}
+		}
+	}
+
+	return ch, unregister
+}
+
+// Await collects the results for tokens from callbacks, polling the executor
+// for any token that hasn't called back in a while. onResult is called once
+// per token as it finishes, like executor.AwaitBatch.
+func (c *Callbacks) Await(ctx context.Context, codeExecutor executor.CodeExecutor, tokens []string, onResult func(index int, result executor.Result)) ([]executor.Result, error) {
+	delivered, unregister := c.register(tokens)
+	defer unregister()
+
+	indexes := make(map[string]int, len(tokens))
+	for i, token := range tokens {
+		indexes[token] = i
+	}
+
+	results := make([]executor.Result, len(tokens))
+	done := make([]bool, len(tokens))
+	remaining := len(tokens)
+
+	finish := func(i int, result executor.Result) {
+		if done[i] {
+			return
+		}
+		results[i] = result
+		done[i] = true
+		remaining--
+		if onResult != nil {
+			onResult(i, result)
+		}
+	}
+
+	poll := time.NewTimer(callbackPollInterval)
+	defer poll.Stop()
+
+	for remaining > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+
+		case result := <-delivered:
+			if i, ok := indexes[result.Token]; ok {
+				finish(i, result)
+			}
+
+		case <-poll.C:
+			var pending []string
+			for i, token := range tokens {
+				if !done[i] {
+					pending = append(pending, token)
+				}
+			}
+
+			polled, err := codeExecutor.GetBatchResults(ctx, pending)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, err
+			}
+
+			for j, result := range polled {
+				if !result.IsPending() {
+					finish(indexes[pending[j]], result)
+				}
+			}
+
+			poll.Reset(callbackPollInterval)
+		}
+	}
+
+	return results, nil
+}
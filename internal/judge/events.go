@@ -0,0 +1,266 @@
+package judge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	EventStatus   = "status"
+	EventTestcase = "testcase"
+	EventResult   = "result"
+	// Sent by the stream itself while the submission waits in the queue
+	EventQueue = "queue"
+)
+
+const (
+	eventsChannel = queuePrefix + "events"
+	// How long a submission's events stay around for late subscribers after
+	// the last one was published
+	eventHistoryTTL = 10 * time.Minute
+)
+
+type Event struct {
+	Type string `json:"type"`
+	ID   int    `json:"id"`
+	Data any    `json:"data"`
+}
+
+// publishScript appends the event to the submission's history and announces
+// it with its place there, so a subscriber can tell which live events its
+// copy of the history already has.
+var publishScript = redis.NewScript(`
+local seq = redis.call("RPUSH", KEYS[1], ARGV[2])
+redis.call("EXPIRE", KEYS[1], ARGV[3])
+redis.call("PUBLISH", ARGV[4], ARGV[1] .. ":" .. seq .. ":" .. ARGV[2])
+return seq
+`)
+
+type subscriber struct {
+	ch chan Event
+	// seen is how many of the submission's events the subscriber has, -1
+	// while its history is being read
+	seen    int
+	pending []eventMessage
+}
+
+type eventMessage struct {
+	seq   int
+	event Event
+}
+
+// Broker fans judge progress out to anyone watching a submission, on any
+// server instance. Events go through Redis, which also keeps each
+// submission's history so a subscriber that shows up late still sees the
+// testcases that already finished.
+type Broker struct {
+	redis  *redis.Client
+	logger *log.Logger
+
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[*subscriber]struct{}
+}
+
+func NewBroker(redisClient *redis.Client, logger *log.Logger) *Broker {
+	return &Broker{
+		redis:       redisClient,
+		logger:      logger,
+		subscribers: make(map[uuid.UUID]map[*subscriber]struct{}),
+	}
+}
+
+func eventsKey(submissionID uuid.UUID) string {
+	return queuePrefix + "events:" + submissionID.String()
+}
+
+// Run relays events published by every instance to the subscribers on this
+// one until the context is done.
+func (b *Broker) Run(ctx context.Context) {
+	pubsub := b.redis.Subscribe(ctx, eventsChannel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			b.logger.Println("Error receiving judge event", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		submissionID, message, err := parseEventMessage(msg.Payload)
+		if err != nil {
+			b.logger.Println("Error parsing judge event", err)
+			continue
+		}
+
+		b.dispatch(submissionID, message)
+	}
+}
+
+// Subscribe returns the events published so far and a channel for the rest.
+// The channel is closed after the result event; call unsubscribe when done.
+func (b *Broker) Subscribe(ctx context.Context, submissionID uuid.UUID) ([]Event, <-chan Event, func(), error) {
+	sub := &subscriber{ch: make(chan Event, 16), seen: -1}
+
+	// Listen before reading the history so nothing published in between is missed
+	b.mu.Lock()
+	if b.subscribers[submissionID] == nil {
+		b.subscribers[submissionID] = make(map[*subscriber]struct{})
+	}
+	b.subscribers[submissionID][sub] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(submissionID, sub)
+	}
+
+	stored, err := b.redis.LRange(ctx, eventsKey(submissionID), 0, -1).Result()
+	if err != nil {
+		unsubscribe()
+		return nil, nil, nil, fmt.Errorf("error reading submission events: %w", err)
+	}
+
+	history := make([]Event, 0, len(stored))
+	for _, payload := range stored {
+		event, err := parseEvent(payload)
+		if err != nil {
+			unsubscribe()
+			return nil, nil, nil, err
+		}
+		history = append(history, event)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub.seen = len(history)
+	if len(history) > 0 && history[len(history)-1].Type == EventResult {
+		b.remove(submissionID, sub)
+		return history, sub.ch, func() {}, nil
+	}
+
+	pending := sub.pending
+	sub.pending = nil
+	for _, message := range pending {
+		b.deliver(submissionID, sub, message)
+	}
+
+	return history, sub.ch, unsubscribe, nil
+}
+
+// Publish sends the event to the submission's subscribers. It's best effort,
+// a failure is only logged.
+func (b *Broker) Publish(ctx context.Context, submissionID uuid.UUID, event Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		b.logger.Println("Error marshalling judge event", submissionID, err)
+		return
+	}
+
+	err = publishScript.Run(ctx, b.redis, []string{eventsKey(submissionID)},
+		submissionID.String(), payload, int(eventHistoryTTL.Seconds()), eventsChannel,
+	).Err()
+	if err != nil {
+		b.logger.Println("Error publishing judge event", submissionID, err)
+	}
+}
+
+func (b *Broker) dispatch(submissionID uuid.UUID, message eventMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers[submissionID] {
+		if sub.seen < 0 {
+			sub.pending = append(sub.pending, message)
+			continue
+		}
+		b.deliver(submissionID, sub, message)
+	}
+}
+
+// deliver sends the event on unless the subscriber's history had it. Called
+// with b.mu held.
+func (b *Broker) deliver(submissionID uuid.UUID, sub *subscriber, message eventMessage) {
+	if message.seq <= sub.seen {
+		return
+	}
+	sub.seen = message.seq
+
+	select {
+	case sub.ch <- message.event:
+	default:
+		// Slow subscriber, drop it rather than stall the others
+		b.remove(submissionID, sub)
+		return
+	}
+
+	if message.event.Type == EventResult {
+		b.remove(submissionID, sub)
+	}
+}
+
+// remove closes the subscriber's channel. Called with b.mu held.
+func (b *Broker) remove(submissionID uuid.UUID, sub *subscriber) {
+	subs := b.subscribers[submissionID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	close(sub.ch)
+	if len(subs) == 0 {
+		delete(b.subscribers, submissionID)
+	}
+}
+
+func parseEventMessage(payload string) (uuid.UUID, eventMessage, error) {
+	parts := strings.SplitN(payload, ":", 3)
+	if len(parts) != 3 {
+		return uuid.Nil, eventMessage{}, fmt.Errorf("malformed event %q", payload)
+	}
+
+	submissionID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, eventMessage{}, err
+	}
+
+	seq, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return uuid.Nil, eventMessage{}, err
+	}
+
+	event, err := parseEvent(parts[2])
+	if err != nil {
+		return uuid.Nil, eventMessage{}, err
+	}
+
+	return submissionID, eventMessage{seq: seq, event: event}, nil
+}
+
+// parseEvent keeps the data as raw JSON, it's only ever written back out.
+func parseEvent(payload string) (Event, error) {
+	var stored struct {
+		Type string          `json:"type"`
+		ID   int             `json:"id"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(payload), &stored); err != nil {
+		return Event{}, fmt.Errorf("error unmarshalling event: %w", err)
+	}
+
+	return Event{Type: stored.Type, ID: stored.ID, Data: stored.Data}, nil
+}
@@ -0,0 +1,102 @@
+package judge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestBrokers returns two brokers sharing one Redis, as two server
+// instances would, with both relaying events.
+func newTestBrokers(t *testing.T) (*Broker, *Broker) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	brokers := make([]*Broker, 2)
+	for i := range brokers {
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+
+		brokers[i] = NewBroker(client, log.New(io.Discard, "", 0))
+		go brokers[i].Run(ctx)
+	}
+
+	for server.PubSubNumSub(eventsChannel)[eventsChannel] < len(brokers) {
+		time.Sleep(time.Millisecond)
+	}
+
+	return brokers[0], brokers[1]
+}
+
+func receive(t *testing.T, events <-chan Event) (Event, bool) {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		return event, ok
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an event")
+		return Event{}, false
+	}
+}
+
+func TestBrokerAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	judging, streaming := newTestBrokers(t)
+	submissionID := uuid.New()
+
+	judging.Publish(ctx, submissionID, Event{Type: EventStatus, Data: "RUNNING"})
+
+	// A late subscriber on the other instance gets what it missed
+	history, events, unsubscribe, err := streaming.Subscribe(ctx, submissionID)
+	if err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+	defer unsubscribe()
+
+	if len(history) != 1 || history[0].Type != EventStatus {
+		t.Fatalf("history = %+v, want the status event", history)
+	}
+
+	judging.Publish(ctx, submissionID, Event{Type: EventTestcase, ID: 0, Data: map[string]bool{"passed": true}})
+	judging.Publish(ctx, submissionID, Event{Type: EventResult, Data: "AC"})
+
+	event, _ := receive(t, events)
+	if event.Type != EventTestcase || event.ID != 0 {
+		t.Fatalf("got %+v, want testcase 0", event)
+	}
+	if data, _ := json.Marshal(event.Data); string(data) != `{"passed":true}` {
+		t.Errorf("testcase data = %s, want it unchanged", data)
+	}
+
+	event, _ = receive(t, events)
+	if event.Type != EventResult {
+		t.Fatalf("got %+v, want the result", event)
+	}
+
+	if _, ok := receive(t, events); ok {
+		t.Error("channel still open after the result")
+	}
+
+	// Once finished the whole history comes back on a closed channel
+	history, events, _, err = judging.Subscribe(ctx, submissionID)
+	if err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+	if len(history) != 3 {
+		t.Errorf("history has %d events, want 3", len(history))
+	}
+	if _, ok := receive(t, events); ok {
+		t.Error("channel of a finished submission is open")
+	}
+}
@@ -0,0 +1,120 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/harness"
+	"github.com/grvbrk/async0_server/internal/languages"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+var ErrGeneration = errors.New("testcase generation failed")
+
+// GenerateTestcases runs the problem's generator once per seed, with the
+// seed on stdin, to get the inputs and then the reference solution on them
+// for the outputs. The same generator, seeds and reference always give the
+// same testcases, so generating again only replaces them with themselves.
+func GenerateTestcases(ctx context.Context, exec executor.CodeExecutor, problem *models.Problem) ([]models.Testcase, error) {
+	if problem.GeneratorCode == "" || len(problem.GeneratorSeeds) == 0 {
+		return nil, fmt.Errorf("%w: problem has no generator or seeds", ErrGeneration)
+	}
+
+	if problem.SolutionCode == "" {
+		return nil, fmt.Errorf("%w: problem has no reference solution", ErrGeneration)
+	}
+
+	language, err := languages.Get(problem.GeneratorLanguage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting generator language: %w", err)
+	}
+
+	submissions := make([]executor.Submission, len(problem.GeneratorSeeds))
+	for i, seed := range problem.GeneratorSeeds {
+		submissions[i] = executor.Submission{
+			LanguageID:      language.Judge0ID,
+			SourceCode:      problem.GeneratorCode,
+			Stdin:           seed,
+			CompilerOptions: language.CompilerOptions,
+		}
+	}
+	LimitsFor(problem, language).Apply(submissions)
+
+	ctx, cancel := context.WithTimeout(ctx, judgeTimeout)
+	defer cancel()
+
+	results, err := runBatch(ctx, exec, submissions)
+	if err != nil {
+		return nil, err
+	}
+
+	ui := ""
+	if problem.Signature != nil {
+		names := make([]string, len(problem.Signature.Params))
+		for i, param := range problem.Signature.Params {
+			names[i] = param.Name
+		}
+		ui = strings.Join(names, ",")
+	}
+
+	testcases := make([]models.Testcase, len(results))
+	for i, result := range results {
+		seed := problem.GeneratorSeeds[i]
+
+		if result.Status.ID != executor.StatusAccepted || result.Stdout == nil {
+			return nil, fmt.Errorf("%w: generator failed for seed %q: %s", ErrGeneration, seed, executor.StatusDescriptions[result.Status.ID])
+		}
+
+		input := strings.TrimSpace(*result.Stdout)
+		if problem.Signature != nil {
+			if err := harness.ValidateInput(*problem.Signature, input); err != nil {
+				return nil, fmt.Errorf("%w: generator output for seed %q: %v", ErrGeneration, seed, err)
+			}
+		}
+
+		testcases[i] = models.Testcase{
+			UI:          ui,
+			Input:       input,
+			IsGenerated: true,
+			Seed:        seed,
+		}
+	}
+
+	reference, err := referenceSubmissions(problem, testcases)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err = runBatch(ctx, exec, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		if referenceError := referenceOutput(result, &testcases[i]); referenceError != "" {
+			return nil, fmt.Errorf("%w: seed %q: %s", ErrGeneration, testcases[i].Seed, referenceError)
+		}
+	}
+
+	return testcases, nil
+}
+
+func runBatch(ctx context.Context, exec executor.CodeExecutor, submissions []executor.Submission) ([]executor.Result, error) {
+	tokens, err := exec.SubmitBatch(ctx, submissions)
+	if err != nil {
+		return nil, fmt.Errorf("error submitting batch: %w", err)
+	}
+
+	results, err := executor.AwaitBatch(ctx, exec, tokens, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error awaiting batch: %w", err)
+	}
+
+	for i := range results {
+		splitOutput(submissions[i], &results[i])
+	}
+	return results, nil
+}
@@ -0,0 +1,285 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/grvbrk/async0_server/internal/checker"
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/harness"
+	"github.com/grvbrk/async0_server/internal/languages"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+// Special judges are written in JavaScript whatever the submission's language
+const specialJudgeLanguageID = 63
+
+// NewChecker returns the output checker configured for the problem.
+func NewChecker(codeExecutor executor.CodeExecutor, problem *models.Problem) (checker.Checker, error) {
+	if checker.Mode(problem.Checker) == checker.ModeSpecial {
+		if problem.CheckerCode == "" {
+			return nil, fmt.Errorf("problem %s uses a special judge but has no checker code", problem.ID)
+		}
+		return checker.NewSpecial(codeExecutor, specialJudgeLanguageID, problem.CheckerCode), nil
+	}
+
+	tolerance := 0.0
+	if problem.CheckerTolerance != nil {
+		tolerance = *problem.CheckerTolerance
+	}
+
+	return checker.New(checker.Mode(problem.Checker), tolerance)
+}
+
+// Supports reports whether code in the language can be judged on the
+// problem. Stdio programs need no harness, problems with a typed signature
+// get a generated one and the rest rely on the language's own.
+func Supports(language languages.Language, problem *models.Problem) bool {
+	if problem.IOMode == models.IOModeStdio {
+		return true
+	}
+	if problem.Signature != nil {
+		return harness.Supports(language.Slug)
+	}
+	return language.HasHarness()
+}
+
+// BuildSubmissions wraps the code in a harness, generated from the signature
+// when the problem has one, and passes each testcase's input on stdin after
+// a result marker of its own. Stdio
+// problems run the code as it is with the testcase input on stdin. Expected
+// outputs aren't sent, the problem's checker decides the verdict once the
+// runs are back.
+func BuildSubmissions(language languages.Language, problem *models.Problem, code string, testcases []models.Testcase) ([]executor.Submission, error) {
+	sourceCode := code
+	var err error
+	switch {
+	case problem.IOMode == models.IOModeStdio:
+	case problem.Signature != nil:
+		sourceCode, err = harness.Generate(language.Slug, *problem.Signature, code)
+	default:
+		sourceCode, err = language.Harness(code)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	submissions := make([]executor.Submission, 0, len(testcases))
+
+	for _, testcase := range testcases {
+		input := stdin(testcase.Input)
+		marker := ""
+		switch {
+		case problem.IOMode == models.IOModeStdio:
+		case problem.Signature != nil:
+			marker = harness.NewMarker()
+			input, err = harness.Stdin(*problem.Signature, testcase.Input, marker)
+		default:
+			marker = harness.NewMarker()
+			input, err = harness.CallStdin(testcase.Input, marker)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		submissions = append(submissions, executor.Submission{
+			LanguageID:      language.Judge0ID,
+			SourceCode:      sourceCode,
+			Stdin:           input,
+			CompilerOptions: language.CompilerOptions,
+			ResultMarker:    marker,
+		})
+	}
+
+	return submissions, nil
+}
+
+// splitOutput leaves only the result line of a harnessed program in Stdout
+// and moves whatever else it printed to Log. Results without a result line,
+// like stdio runs or crashes, are left alone.
+func splitOutput(submission executor.Submission, result *executor.Result) {
+	if result.Stdout == nil {
+		return
+	}
+
+	stdout, log, ok := harness.SplitOutput(*result.Stdout, submission.ResultMarker)
+	if !ok {
+		return
+	}
+
+	result.Stdout = &stdout
+	if log != "" {
+		result.Log = &log
+	}
+}
+
+// stdin ends the input with a newline, as programs reading lines expect.
+func stdin(input string) string {
+	if input == "" || strings.HasSuffix(input, "\n") {
+		return input
+	}
+	return input + "\n"
+}
+
+// ranCleanly reports whether the run finished without an error, meaning its
+// output is worth handing to the checker.
+func ranCleanly(result executor.Result) bool {
+	return result.Status.ID == executor.StatusAccepted || result.Status.ID == executor.StatusWrongAnswer
+}
+
+// CheckResults runs the checker over the results that finished cleanly. The
+// returned verdicts line up with results and are empty for runs that failed.
+func CheckResults(ctx context.Context, chk checker.Checker, results []executor.Result, testcases []models.Testcase) ([]models.SubmissionStatus, error) {
+	verdicts := make([]models.SubmissionStatus, len(results))
+
+	var cases []checker.Case
+	var indices []int
+	for i, result := range results {
+		if !ranCleanly(result) {
+			continue
+		}
+
+		actual := ""
+		if result.Stdout != nil {
+			actual = *result.Stdout
+		}
+
+		cases = append(cases, checker.Case{Input: testcases[i].Input, Expected: testcases[i].Output, Actual: actual})
+		indices = append(indices, i)
+	}
+
+	if len(cases) == 0 {
+		return verdicts, nil
+	}
+
+	checked, err := chk.Check(ctx, cases)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, i := range indices {
+		verdicts[i] = checked[j]
+	}
+
+	return verdicts, nil
+}
+
+// FormatResult builds the stored result for one testcase. checked is the
+// checker's verdict from CheckResults.
+func FormatResult(result executor.Result, testcase models.Testcase, checked models.SubmissionStatus, limits Limits) models.TestcaseResult {
+	verdict := TestcaseVerdict(result.Status.ID, checked)
+
+	statusID := result.Status.ID
+	if ranCleanly(result) && checked != "" {
+		// Judge0 never sees the expected output, so report the checker's view
+		statusID = executor.StatusWrongAnswer
+		if verdict == models.StatusAC {
+			statusID = executor.StatusAccepted
+		}
+	}
+
+	statusDesc := executor.StatusDescriptions[statusID]
+	if statusDesc == "" {
+		statusDesc = fmt.Sprintf("Unknown Status (%d)", statusID)
+	}
+
+	actualOutput := ""
+	if result.Stdout != nil {
+		actualOutput = strings.TrimSpace(*result.Stdout)
+	}
+
+	// Judge0 reports time as a string of seconds
+	tcTime := 0.0
+	if result.Time != nil {
+		tcTime, _ = strconv.ParseFloat(*result.Time, 64)
+	}
+
+	tcMemory := 0
+	if result.Memory != nil {
+		tcMemory = *result.Memory
+	}
+
+	tcLog := ""
+	if result.Log != nil {
+		tcLog = *result.Log
+	}
+
+	tcStderr := ""
+	tcStderrTruncated := false
+	if result.Stderr != nil {
+		tcStderr = *result.Stderr
+		tcStderrTruncated = result.StderrTruncated
+	} else if result.CompileOutput != nil {
+		tcStderr = *result.CompileOutput
+		tcStderrTruncated = result.CompileOutputTruncated
+	}
+
+	// Judge0 has no memory limit status, running out shows up as a runtime error
+	if verdict == models.StatusRE && limits.exceededMemory(tcMemory, tcStderr) {
+		verdict = models.StatusMLE
+	}
+
+	var testcaseID *uuid.UUID
+	if testcase.ID != uuid.Nil {
+		testcaseID = &testcase.ID
+	}
+
+	return models.TestcaseResult{
+		TCTestcaseID:     testcaseID,
+		TCHidden:         !testcase.IsSample,
+		TCPass:           verdict == models.StatusAC,
+		TCStatusID:       statusID,
+		TCStatus:         statusDesc,
+		TCVerdict:        verdict,
+		TCTime:           tcTime,
+		TCMemory:         tcMemory,
+		TCInput:          testcase.Input,
+		TCOutput:         actualOutput,
+		TCStderr:         tcStderr,
+		TCLog:            tcLog,
+		TCExpectedOutput: strings.TrimSpace(testcase.Output),
+
+		TCOutputTruncated: result.StdoutTruncated,
+		TCStderrTruncated: tcStderrTruncated,
+	}
+}
+
+func FormatResults(results []executor.Result, testCases []models.Testcase, checked []models.SubmissionStatus, limits Limits) models.SubmitSubmissionResponse {
+	passedTests := 0
+	totalTime := 0.0
+	peakMemory := 0
+	formattedResults := make([]models.TestcaseResult, len(results))
+
+	for i, result := range results {
+		formattedResults[i] = FormatResult(result, testCases[i], checked[i], limits)
+		if formattedResults[i].TCPass {
+			passedTests++
+		}
+
+		totalTime += formattedResults[i].TCTime
+		peakMemory = max(peakMemory, formattedResults[i].TCMemory)
+	}
+
+	verdict := ResolveVerdict(formattedResults)
+
+	response := models.SubmitSubmissionResponse{
+		OverallStatusID:  verdict.StatusID,
+		OverallStatus:    verdict.Status,
+		PassedTestcases:  passedTests,
+		TotalTestcases:   len(results),
+		Runtime:          int(math.Round(totalTime * 1000)),
+		MemoryUsed:       peakMemory,
+		TestcasesResults: formattedResults,
+	}
+
+	if verdict.FirstFailed != nil {
+		response.FirstFailedTestcase = verdict.FirstFailed
+		response.FirstFailedVerdict = formattedResults[*verdict.FirstFailed].TCVerdict
+	}
+
+	return response
+}
@@ -0,0 +1,174 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/grvbrk/async0_server/internal/checker"
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/harness"
+	"github.com/grvbrk/async0_server/internal/languages"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+func TestBuildSubmissionsStdio(t *testing.T) {
+	problem := &models.Problem{IOMode: models.IOModeStdio}
+	code := "a, b = map(int, input().split())\nprint(a + b)\n"
+
+	tests := []struct {
+		name      string
+		input     string
+		wantStdin string
+	}{
+		{"gets a trailing newline", "1 2", "1 2\n"},
+		{"keeps an existing newline", "1 2\n", "1 2\n"},
+		{"multiple lines", "3\n1 2 3", "3\n1 2 3\n"},
+		{"empty input", "", ""},
+	}
+
+	for _, language := range languages.All() {
+		if !Supports(language, problem) {
+			t.Errorf("stdio problems should support %s", language.Name)
+		}
+
+		for _, tt := range tests {
+			t.Run(language.Slug+"/"+tt.name, func(t *testing.T) {
+				submissions, err := BuildSubmissions(language, problem, code, []models.Testcase{{Input: tt.input}})
+				if err != nil {
+					t.Fatalf("BuildSubmissions() error: %v", err)
+				}
+
+				got := submissions[0]
+				if got.SourceCode != code {
+					t.Errorf("source code was changed to %q", got.SourceCode)
+				}
+				if got.Stdin != tt.wantStdin || got.ResultMarker != "" {
+					t.Errorf("stdin = %q with marker %q, want %q and no marker", got.Stdin, got.ResultMarker, tt.wantStdin)
+				}
+				if got.LanguageID != language.Judge0ID || got.CompilerOptions != language.CompilerOptions {
+					t.Errorf("submission runs as %d %q, want %d %q", got.LanguageID, got.CompilerOptions, language.Judge0ID, language.CompilerOptions)
+				}
+			})
+		}
+	}
+}
+
+func TestCheckResultsStdio(t *testing.T) {
+	stdout := func(s string) *string { return &s }
+
+	chk, err := checker.New(checker.ModeWhitespace, 0)
+	if err != nil {
+		t.Fatalf("checker.New() error: %v", err)
+	}
+
+	testcases := []models.Testcase{
+		{Input: "1 2", Output: "3"},
+		{Input: "2 2", Output: "4"},
+		{Input: "3", Output: "1\n2\n3"},
+		{Input: "0 0", Output: "0"},
+		{Input: "", Output: ""},
+	}
+	results := []executor.Result{
+		{Status: executor.Status{ID: executor.StatusAccepted}, Stdout: stdout("3\n")},
+		{Status: executor.Status{ID: executor.StatusAccepted}, Stdout: stdout("5\n")},
+		{Status: executor.Status{ID: executor.StatusAccepted}, Stdout: stdout("1 2 3\n")},
+		{Status: executor.Status{ID: executor.StatusRuntimeNZEC}, Stdout: stdout("0\n")},
+		{Status: executor.Status{ID: executor.StatusAccepted}},
+	}
+	want := []models.SubmissionStatus{models.StatusAC, models.StatusWA, models.StatusAC, "", models.StatusAC}
+
+	verdicts, err := CheckResults(context.Background(), chk, results, testcases)
+	if err != nil {
+		t.Fatalf("CheckResults() error: %v", err)
+	}
+
+	for i := range want {
+		if verdicts[i] != want[i] {
+			t.Errorf("verdict %d = %q, want %q", i, verdicts[i], want[i])
+		}
+	}
+}
+
+func TestSplitOutput(t *testing.T) {
+	text := func(s string) *string { return &s }
+	marker := harness.NewMarker()
+
+	tests := []struct {
+		name       string
+		stdout     *string
+		wantStdout *string
+		wantLog    *string
+	}{
+		{"stdio output is left alone", text("1 2\n3\n"), text("1 2\n3\n"), nil},
+		{"harness result and logs", text("\n" + marker + "[1]\nhi\n"), text("[1]"), text("hi\n")},
+		{"harness result without logs", text(marker + "true\n"), text("true"), nil},
+		{"forged result before the real one", text("\n" + marker + "0\n\n" + marker + "[1]\n"), text("[1]"), text("\n" + marker + "0\n")},
+		{"no output", nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := executor.Result{Stdout: tt.stdout}
+			splitOutput(executor.Submission{ResultMarker: marker}, &result)
+
+			if !equalText(result.Stdout, tt.wantStdout) || !equalText(result.Log, tt.wantLog) {
+				t.Errorf("splitOutput() = %v, %v, want %v, %v", show(result.Stdout), show(result.Log), show(tt.wantStdout), show(tt.wantLog))
+			}
+		})
+	}
+}
+
+func equalText(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func show(s *string) string {
+	if s == nil {
+		return "nil"
+	}
+	return strconv.Quote(*s)
+}
+
+func TestBuildSubmissionsCallInput(t *testing.T) {
+	language, err := languages.Get("python")
+	if err != nil {
+		t.Fatalf("languages.Get() error: %v", err)
+	}
+	problem := &models.Problem{IOMode: models.IOModeFunction}
+
+	tests := []struct {
+		name      string
+		input     string
+		wantStdin string
+		wantErr   error
+	}{
+		{"call expression", "add(1, [2, 3])", "add\n1\n[2,3]\n", nil},
+		{"code in the input", "add(__import__('os').system('id'))", "", harness.ErrInvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			submissions, err := BuildSubmissions(language, problem, "def add(a, b): return a", []models.Testcase{{Input: tt.input}, {Input: tt.input}})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("BuildSubmissions() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			// Every run gets a marker of its own, first on stdin
+			marker := submissions[0].ResultMarker
+			if marker == "" || marker == submissions[1].ResultMarker {
+				t.Errorf("markers = %q, %q, want two different ones", marker, submissions[1].ResultMarker)
+			}
+			if submissions[0].Stdin != marker+"\n"+tt.wantStdin {
+				t.Errorf("stdin = %q, want %q after the marker", submissions[0].Stdin, tt.wantStdin)
+			}
+		})
+	}
+}
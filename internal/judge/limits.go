@@ -0,0 +1,62 @@
+package judge
+
+import (
+	"strings"
+
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/languages"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+// Judge0's own ceilings, anything above is rejected
+const (
+	maxCPUTimeLimit  = 15.0
+	maxWallTimeLimit = 20.0
+	maxMemoryLimitKB = 512000
+)
+
+// Limits are what a problem's submissions run with, in Judge0's units.
+type Limits struct {
+	CPUTime  float64
+	WallTime float64
+	MemoryKB int
+}
+
+// LimitsFor turns the problem's time limit (ms) and memory limit (MB) into
+// the limits for one language. Zero limits are left to the executor.
+func LimitsFor(problem *models.Problem, language languages.Language) Limits {
+	timeFactor := max(language.TimeFactor, 1)
+
+	var limits Limits
+
+	if problem.TimeLimit > 0 {
+		limits.CPUTime = min(float64(problem.TimeLimit)/1000*timeFactor, maxCPUTimeLimit)
+		// Wall time covers I/O and scheduling, not extra computation
+		limits.WallTime = min(limits.CPUTime*2+1, maxWallTimeLimit)
+	}
+
+	if problem.MemoryLimit > 0 {
+		limits.MemoryKB = min((problem.MemoryLimit+language.ExtraMemoryMB)*1024, maxMemoryLimitKB)
+	}
+
+	return limits
+}
+
+func (l Limits) Apply(submissions []executor.Submission) {
+	for i := range submissions {
+		submissions[i].CPUTimeLimit = l.CPUTime
+		submissions[i].WallTimeLimit = l.WallTime
+		submissions[i].MemoryLimit = l.MemoryKB
+	}
+}
+
+// exceededMemory reports whether a failed run was most likely killed for
+// using too much memory. Allocations fail before the peak reaches the limit
+// exactly, so anything close counts, as does the runtime saying so.
+func (l Limits) exceededMemory(memoryKB int, stderr string) bool {
+	if l.MemoryKB == 0 {
+		return false
+	}
+
+	return memoryKB >= l.MemoryKB*85/100 || strings.Contains(strings.ToLower(stderr), "out of memory")
+}
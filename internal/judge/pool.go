@@ -0,0 +1,407 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/languages"
+	"github.com/grvbrk/async0_server/internal/models"
+	"github.com/grvbrk/async0_server/internal/store"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrQueueFull = errors.New("judge queue is full")
+
+const (
+	judgeTimeout = 60 * time.Second
+	runTimeout   = 20 * time.Second
+	// How long a run's result waits in Redis for the handler to pick it up
+	runResultTTL = time.Minute
+	// How long a run nobody waits for anymore is remembered, it only has to
+	// outlast the run's time in the queue
+	runCancelTTL = 10 * time.Minute
+	// Testcases sent at a time when judging fails fast. Smaller wastes less
+	// on a failing submission but takes longer on a passing one.
+	failFastGroup = 4
+	// How often unfinished submissions are checked for a worker that's gone
+	reclaimInterval = time.Minute
+)
+
+// Job is one Submit, Run or rejudged submission. ID is the submission's ID
+// for a Submit or a rejudge and a fresh ID for a Run, which only exists in
+// the queue. Stress turns a Run into a stress test against the reference
+// solution. FailFast stops a Submit at its first failing testcase, which
+// the problem can also ask for.
+type Job struct {
+	ID           uuid.UUID `json:"id"`
+	Lane         Lane      `json:"lane"`
+	UserID       uuid.UUID `json:"user_id"`
+	ProblemID    uuid.UUID `json:"problem_id"`
+	Language     string    `json:"language"`
+	Code         string    `json:"code"`
+	CustomInputs []string  `json:"custom_inputs,omitempty"`
+	RejudgeID    uuid.UUID `json:"rejudge_id"`
+	Stress       bool      `json:"stress,omitempty"`
+	FailFast     bool      `json:"fail_fast,omitempty"`
+}
+
+// Pool judges submissions in the background. Handlers insert a PENDING
+// submission, enqueue it and return straight away; a worker then moves it
+// through RUNNING to its final verdict. Runs go through the same queue in a
+// lane of their own, with the handler waiting on the result.
+type Pool struct {
+	Executor        executor.CodeExecutor
+	SubmissionStore store.SubmissionStore
+	ProblemStore    store.ProblemStore
+	TestcaseStore   store.TestcaseStore
+	RejudgeStore    store.RejudgeStore
+	Queue           *Queue
+	Cache           *ResultCache
+	Redis           *redis.Client
+	Logger          *log.Logger
+	Events          *Broker
+	Callbacks       *Callbacks
+
+	// CallbackURL is where Judge0 should report finished tokens. When empty
+	// the pool only polls.
+	CallbackURL string
+
+	workers int
+}
+
+func NewPool(codeExecutor executor.CodeExecutor, submissionStore store.SubmissionStore, problemStore store.ProblemStore, testcaseStore store.TestcaseStore, rejudgeStore store.RejudgeStore, redisClient *redis.Client, queue *Queue, cache *ResultCache, logger *log.Logger, callbackURL string, workers int) *Pool {
+	return &Pool{
+		Executor:        codeExecutor,
+		SubmissionStore: submissionStore,
+		ProblemStore:    problemStore,
+		TestcaseStore:   testcaseStore,
+		RejudgeStore:    rejudgeStore,
+		Queue:           queue,
+		Cache:           cache,
+		Redis:           redisClient,
+		Logger:          logger,
+		Events:          NewBroker(redisClient, logger),
+		Callbacks:       NewCallbacks(),
+		CallbackURL:     callbackURL,
+		workers:         workers,
+	}
+}
+
+// Start launches the workers and the loop that re-queues submissions whose
+// worker is gone, whether from a crash here or on another instance.
+func (p *Pool) Start(ctx context.Context) {
+	for i := 0; i < p.workers; i++ {
+		go p.work(ctx)
+	}
+
+	go p.reclaim(ctx)
+	go p.Events.Run(ctx)
+}
+
+// Enqueue queues a Submit and returns its position in the queue.
+func (p *Pool) Enqueue(ctx context.Context, job Job) (int, error) {
+	job.Lane = LaneSubmit
+	return p.Queue.Push(ctx, job)
+}
+
+// QueuePosition returns where the user's submission is in the queue, or 0
+// once a worker has it.
+func (p *Pool) QueuePosition(ctx context.Context, submission *models.Submission) (int, error) {
+	return p.Queue.Position(ctx, Job{ID: submission.ID, Lane: LaneSubmit, UserID: submission.UserID})
+}
+
+func (p *Pool) reclaim(ctx context.Context) {
+	for {
+		p.requeue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reclaimInterval):
+		}
+	}
+}
+
+func (p *Pool) requeue(ctx context.Context) {
+	submissions, err := p.SubmissionStore.GetUnfinishedSubmissions()
+	if err != nil {
+		p.Logger.Println("Error getting unfinished submissions", err)
+		return
+	}
+
+	count := 0
+	for _, submission := range submissions {
+		// The handler that created it may not have queued it yet
+		if time.Since(submission.CreatedAt) < leaseTTL {
+			continue
+		}
+
+		held, err := p.Queue.Held(ctx, submission.ID)
+		if err != nil {
+			p.Logger.Println("Error checking if submission is queued", submission.ID, err)
+			continue
+		}
+		if held {
+			continue
+		}
+
+		job := Job{
+			ID:        submission.ID,
+			Lane:      LaneSubmit,
+			UserID:    submission.UserID,
+			ProblemID: submission.ProblemID,
+			Language:  submission.Language,
+			Code:      submission.Code,
+		}
+
+		// A rejudge that was cut short still needs this verdict
+		job.RejudgeID, err = p.RejudgeStore.GetOpenRejudgeID(submission.ID)
+		if err != nil {
+			p.Logger.Println("Error checking if submission is being rejudged", submission.ID, err)
+			continue
+		}
+		if job.RejudgeID != uuid.Nil {
+			job.Lane = LaneRejudge
+		}
+
+		// Another instance may have got to it first
+		pushed, err := p.Queue.requeue(ctx, job)
+		if err != nil {
+			p.Logger.Println("Error re-queueing submission", submission.ID, err)
+			continue
+		}
+		if pushed {
+			count++
+		}
+	}
+
+	if count > 0 {
+		p.Logger.Printf("Re-queued %d unfinished submissions", count)
+	}
+}
+
+// hold renews the job's lease until the returned func is called, so other
+// instances don't take it back.
+func (p *Pool) hold(ctx context.Context, job Job) func() {
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		ticker := time.NewTicker(p.Queue.leaseTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.Queue.Renew(ctx, job); err != nil && ctx.Err() == nil {
+					p.Logger.Println("Error renewing judge job", job.ID, err)
+				}
+			}
+		}
+	}()
+
+	return cancel
+}
+
+func (p *Pool) work(ctx context.Context) {
+	for {
+		job, err := p.Queue.Pop(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			p.Logger.Println("Error popping judge job", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		release := p.hold(ctx, job)
+
+		switch job.Lane {
+		case LaneRun:
+			p.run(ctx, job)
+		case LaneRejudge:
+			p.rejudge(ctx, job)
+		default:
+			p.submit(ctx, job)
+		}
+
+		release()
+		if err := p.Queue.Done(ctx, job); err != nil {
+			p.Logger.Println("Error releasing judge job", job.ID, err)
+		}
+	}
+}
+
+// submit judges the submission and returns its final status.
+func (p *Pool) submit(ctx context.Context, job Job) models.SubmissionStatus {
+	status, err := p.judge(ctx, job)
+	if err == nil {
+		return status
+	}
+
+	p.Logger.Println("Error judging submission", job.ID, err)
+
+	err = p.SubmissionStore.UpdateSubmissionStatus(job.ID, models.StatusIE)
+	if err != nil {
+		p.Logger.Println("Error marking submission as failed", job.ID, err)
+	}
+
+	p.Events.Publish(ctx, job.ID, Event{Type: EventResult, Data: models.SubmitSubmissionResponse{
+		OverallStatus:    models.StatusIE,
+		TestcasesResults: []models.TestcaseResult{},
+	}})
+
+	return models.StatusIE
+}
+
+// executeFailFast runs the submissions in order, failFastGroup at a time,
+// and stops after the group holding the first one that didn't pass. It
+// returns the results up to and including that one.
+func (p *Pool) executeFailFast(ctx context.Context, problemID uuid.UUID, submissions []executor.Submission, onResult func(int, executor.Result), passed func(int, executor.Result) bool) ([]executor.Result, error) {
+	results := make([]executor.Result, 0, len(submissions))
+
+	for start := 0; start < len(submissions); start += failFastGroup {
+		end := min(start+failFastGroup, len(submissions))
+
+		group, err := p.Cache.Execute(ctx, problemID, submissions[start:end], p.execute, func(j int, result executor.Result) {
+			onResult(start+j, result)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for j, result := range group {
+			results = append(results, result)
+			if !passed(start+j, result) {
+				return results, nil
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// execute sends the submissions as one batch and waits for them, on
+// callbacks when Judge0 has somewhere to send them.
+func (p *Pool) execute(ctx context.Context, submissions []executor.Submission, onResult func(int, executor.Result)) ([]executor.Result, error) {
+	tokens, err := p.Executor.SubmitBatch(ctx, submissions)
+	if err != nil {
+		return nil, err
+	}
+
+	split := func(i int, result executor.Result) {
+		splitOutput(submissions[i], &result)
+		if onResult != nil {
+			onResult(i, result)
+		}
+	}
+
+	var results []executor.Result
+	if p.CallbackURL != "" {
+		results, err = p.Callbacks.Await(ctx, p.Executor, tokens, split)
+	} else {
+		results, err = executor.AwaitBatch(ctx, p.Executor, tokens, split)
+	}
+
+	for i := range results {
+		splitOutput(submissions[i], &results[i])
+	}
+	return results, err
+}
+
+func (p *Pool) judge(ctx context.Context, job Job) (models.SubmissionStatus, error) {
+	err := p.SubmissionStore.UpdateSubmissionStatus(job.ID, models.StatusRunning)
+	if err != nil {
+		return "", err
+	}
+
+	p.Events.Publish(ctx, job.ID, Event{Type: EventStatus, Data: models.StatusRunning})
+
+	testcases, err := p.TestcaseStore.GetTestcasesByProblemID(job.ProblemID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(testcases) == 0 {
+		return "", fmt.Errorf("problem %s has no testcases", job.ProblemID)
+	}
+
+	problem, err := p.ProblemStore.GetProblemByID(job.ProblemID)
+	if err != nil {
+		return "", err
+	}
+
+	chk, err := NewChecker(p.Executor, problem)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, judgeTimeout)
+	defer cancel()
+
+	language, err := languages.Get(job.Language)
+	if err != nil {
+		return "", err
+	}
+
+	submissions, err := BuildSubmissions(language, problem, job.Code, testcases)
+	if err != nil {
+		return "", err
+	}
+
+	limits := LimitsFor(problem, language)
+	limits.Apply(submissions)
+	for i := range submissions {
+		submissions[i].CallbackURL = p.CallbackURL
+	}
+
+	// Each result is checked as it arrives so the streamed verdicts are final
+	checked := make([]models.SubmissionStatus, len(testcases))
+	var checkErr error
+	onResult := func(i int, result executor.Result) {
+		verdicts, err := CheckResults(ctx, chk, []executor.Result{result}, testcases[i:i+1])
+		if err != nil {
+			checkErr = err
+			return
+		}
+
+		checked[i] = verdicts[0]
+		p.Events.Publish(ctx, job.ID, Event{Type: EventTestcase, ID: i, Data: FormatResult(result, testcases[i], checked[i], limits).Redacted()})
+	}
+
+	// Rejudges always run in full so their history has every testcase
+	var results []executor.Result
+	if job.FailFast || (problem.FailFast && job.Lane != LaneRejudge) {
+		results, err = p.executeFailFast(ctx, problem.ID, submissions, onResult, func(i int, result executor.Result) bool {
+			return TestcaseVerdict(result.Status.ID, checked[i]) == models.StatusAC
+		})
+	} else {
+		results, err = p.Cache.Execute(ctx, problem.ID, submissions, p.execute, onResult)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if checkErr != nil {
+		return "", fmt.Errorf("error checking output: %w", checkErr)
+	}
+
+	// Testcases skipped after a failure count as not passed
+	response := FormatResults(results, testcases, checked, limits)
+	response.TotalTestcases = len(testcases)
+
+	err = p.SubmissionStore.CompleteSubmission(job.ID, response)
+	if err != nil {
+		return "", err
+	}
+
+	p.Events.Publish(ctx, job.ID, Event{Type: EventResult, Data: response.Redacted()})
+	return response.OverallStatus, nil
+}
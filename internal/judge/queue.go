@@ -0,0 +1,336 @@
+package judge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrUserLimit = errors.New("too many submissions in flight")
+
+type Lane string
+
+const (
+	LaneRun    Lane = "run"
+	LaneSubmit Lane = "submit"
+	// Rejudges run when nothing else is waiting
+	LaneRejudge Lane = "rejudge"
+)
+
+// Workers drain the lanes in this order, so a Run never waits behind Submits
+var lanes = []Lane{LaneRun, LaneSubmit, LaneRejudge}
+
+const (
+	queuePrefix = "judge:"
+	// The braces make Redis Cluster put every queue key in one slot, so the
+	// scripts can touch them together
+	queueSlot = queuePrefix + "{queue}:"
+	// How long a worker blocks waiting for work before looking again
+	queuePollTimeout = 2 * time.Second
+	// How long a popped job stays claimed without its worker renewing it
+	leaseTTL = 30 * time.Second
+)
+
+// queueKeys are every key the scripts use, passed in KEYS so Redis Cluster
+// and proxies can route them. Each lane's keys follow in lane order.
+var queueKeys = func() []string {
+	keys := []string{queueSlot + "jobs", queueSlot + "members", queueSlot + "leases", queueSlot + "running", queueSlot + "ready", queueSlot + "seq"}
+	for _, lane := range lanes {
+		prefix := queueSlot + "lane:" + string(lane) + ":"
+		keys = append(keys, prefix+"queue", prefix+"rounds", prefix+"round", prefix+"count")
+	}
+	return keys
+}()
+
+// Each lane is a sorted set scored by round. A user's waiting jobs go one
+// round apart and each round goes in push order, so one user queueing many
+// jobs only slows themselves down. A popped job is leased to its worker until
+// Done; a lease that isn't renewed expires and frees the job's slot in its
+// user's limit.
+const queueLib = `
+local jobs, members, leases, running, ready, seq = KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5], KEYS[6]
+local lanes = (#KEYS - 6) / 4
+
+local function laneKeys(lane)
+	local i = 6 + (lane - 1) * 4
+	return KEYS[i + 1], KEYS[i + 2], KEYS[i + 3], KEYS[i + 4]
+end
+
+local function split(value)
+	local sep = string.find(value, ":")
+	return tonumber(string.sub(value, 1, sep - 1)), string.sub(value, sep + 1)
+end
+
+local function release(id)
+	local owner = redis.call("HGET", running, id)
+	redis.call("HDEL", running, id)
+	redis.call("ZREM", leases, id)
+	if owner then
+		local lane, user = split(owner)
+		local _, _, _, count = laneKeys(lane)
+		if redis.call("HINCRBY", count, user, -1) <= 0 then
+			redis.call("HDEL", count, user)
+		end
+	end
+end
+
+local function reap(now)
+	for _, id in ipairs(redis.call("ZRANGEBYSCORE", leases, "-inf", now, "LIMIT", 0, 100)) do
+		release(id)
+	end
+end
+`
+
+var pushScript = redis.NewScript(queueLib + `
+local lane, user, id, job = tonumber(ARGV[1]), ARGV[2], ARGV[3], ARGV[4]
+local limit, maxQueued, force, now = tonumber(ARGV[5]), tonumber(ARGV[6]), ARGV[7] == "1", tonumber(ARGV[8])
+local queue, rounds, round, count = laneKeys(lane)
+reap(now)
+
+-- A job that's waiting or has a live worker stays where it is
+if redis.call("HEXISTS", members, id) == 1 or redis.call("ZSCORE", leases, id) then
+	return 1
+end
+
+if not force and redis.call("ZCARD", queue) >= maxQueued then
+	return -1
+end
+
+if redis.call("HINCRBY", count, user, 1) > limit and not force then
+	redis.call("HINCRBY", count, user, -1)
+	return -2
+end
+
+local current = tonumber(redis.call("GET", round) or 0)
+local last = tonumber(redis.call("HGET", rounds, user) or -1)
+local r = math.max(current, last + 1)
+redis.call("HSET", rounds, user, r)
+
+local member = string.format("%016d:%s", redis.call("INCR", seq), id)
+redis.call("ZADD", queue, r, member)
+redis.call("HSET", jobs, id, job)
+redis.call("HSET", members, id, lane .. ":" .. member)
+redis.call("LPUSH", ready, "1")
+redis.call("LTRIM", ready, 0, maxQueued)
+return 0
+`)
+
+var popScript = redis.NewScript(queueLib + `
+local now, ttl = tonumber(ARGV[1]), tonumber(ARGV[2])
+reap(now)
+
+for lane = 1, lanes do
+	local queue, rounds, round = laneKeys(lane)
+	local popped = redis.call("ZPOPMIN", queue)
+	if #popped > 0 then
+		local _, id = split(popped[1])
+		local job = redis.call("HGET", jobs, id)
+		redis.call("HDEL", jobs, id)
+		redis.call("HDEL", members, id)
+
+		-- With nobody waiting the rounds start over
+		if redis.call("ZCARD", queue) == 0 then
+			redis.call("DEL", rounds, round)
+		else
+			redis.call("SET", round, popped[2])
+		end
+
+		redis.call("ZADD", leases, now + ttl, id)
+		redis.call("HSET", running, id, lane .. ":" .. cjson.decode(job).user_id)
+		return job
+	end
+end
+return false
+`)
+
+var doneScript = redis.NewScript(queueLib + `
+release(ARGV[1])
+return 0
+`)
+
+// positionScript counts the jobs that will be popped up to the given one:
+// everything in the lanes ahead of it and its rank in its own.
+var positionScript = redis.NewScript(queueLib + `
+local member = redis.call("HGET", members, ARGV[1])
+if not member then
+	return 0
+end
+
+local lane, rest = split(member)
+local rank = redis.call("ZRANK", (laneKeys(lane)), rest)
+if not rank then
+	return 0
+end
+
+local position = rank + 1
+for ahead = 1, lane - 1 do
+	position = position + redis.call("ZCARD", (laneKeys(ahead)))
+end
+return position
+`)
+
+// Queue is the judge's job queue, kept in Redis so jobs survive a restart and
+// the per user limits hold across server instances.
+type Queue struct {
+	redis *redis.Client
+
+	// userLimit caps each user's jobs per lane, queued or running, and
+	// maxQueued the jobs waiting in each lane
+	userLimit int
+	maxQueued int
+	leaseTTL  time.Duration
+}
+
+func NewQueue(redisClient *redis.Client, userLimit int, maxQueued int) *Queue {
+	return &Queue{
+		redis:     redisClient,
+		userLimit: userLimit,
+		maxQueued: maxQueued,
+		leaseTTL:  leaseTTL,
+	}
+}
+
+func laneIndex(lane Lane) int {
+	for i, l := range lanes {
+		if l == lane {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Push queues the job and returns its position, 1 being next. A job that's
+// already queued or running is left alone.
+func (q *Queue) Push(ctx context.Context, job Job) (int, error) {
+	_, err := q.push(ctx, job, false)
+	if err != nil {
+		return 0, err
+	}
+	return q.Position(ctx, job)
+}
+
+// requeue pushes a recovered job past the limits, it was already accepted
+// once. It reports false when the job is still queued or a live worker has
+// it.
+func (q *Queue) requeue(ctx context.Context, job Job) (bool, error) {
+	return q.push(ctx, job, true)
+}
+
+func (q *Queue) push(ctx context.Context, job Job, force bool) (bool, error) {
+	lane := laneIndex(job.Lane)
+	if lane == 0 {
+		return false, fmt.Errorf("unknown lane %q", job.Lane)
+	}
+
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return false, fmt.Errorf("error marshalling job: %w", err)
+	}
+
+	forced := "0"
+	if force {
+		forced = "1"
+	}
+
+	code, err := pushScript.Run(ctx, q.redis, queueKeys,
+		lane, job.UserID.String(), job.ID.String(), payload,
+		q.userLimit, q.maxQueued, forced, time.Now().UnixMilli(),
+	).Int()
+	if err != nil {
+		return false, fmt.Errorf("error pushing job: %w", err)
+	}
+
+	switch code {
+	case -1:
+		return false, ErrQueueFull
+	case -2:
+		return false, ErrUserLimit
+	}
+
+	return code == 0, nil
+}
+
+// Pop blocks until a job is available or the context is done. The job is
+// leased to the caller, who renews it while working and releases it with
+// Done.
+func (q *Queue) Pop(ctx context.Context) (Job, error) {
+	for {
+		payload, err := popScript.Run(ctx, q.redis, queueKeys, time.Now().UnixMilli(), q.leaseTTL.Milliseconds()).Text()
+		if err == nil {
+			var job Job
+			if err := json.Unmarshal([]byte(payload), &job); err != nil {
+				return Job{}, fmt.Errorf("error unmarshalling job: %w", err)
+			}
+			return job, nil
+		}
+
+		if err != redis.Nil {
+			return Job{}, fmt.Errorf("error popping job: %w", err)
+		}
+
+		// Every push leaves a wake up here, the timeout covers any that were
+		// taken by a worker that found the job already gone
+		err = q.redis.BLPop(ctx, queuePollTimeout, queueKeys[4]).Err()
+		if err != nil && err != redis.Nil {
+			if ctx.Err() != nil {
+				return Job{}, ctx.Err()
+			}
+			return Job{}, fmt.Errorf("error waiting for jobs: %w", err)
+		}
+	}
+}
+
+// Renew extends the lease on a popped job.
+func (q *Queue) Renew(ctx context.Context, job Job) error {
+	expiry := time.Now().Add(q.leaseTTL).UnixMilli()
+
+	err := q.redis.ZAddXX(ctx, queueKeys[2], redis.Z{Score: float64(expiry), Member: job.ID.String()}).Err()
+	if err != nil {
+		return fmt.Errorf("error renewing job: %w", err)
+	}
+	return nil
+}
+
+// Done releases the job's lease and its slot in its user's limit.
+func (q *Queue) Done(ctx context.Context, job Job) error {
+	err := doneScript.Run(ctx, q.redis, queueKeys, job.ID.String()).Err()
+	if err != nil {
+		return fmt.Errorf("error releasing job: %w", err)
+	}
+	return nil
+}
+
+// Position returns how many jobs will be popped up to and including this
+// one, or 0 once it's no longer queued.
+func (q *Queue) Position(ctx context.Context, job Job) (int, error) {
+	position, err := positionScript.Run(ctx, q.redis, queueKeys, job.ID.String()).Int()
+	if err != nil {
+		return 0, fmt.Errorf("error getting queue position: %w", err)
+	}
+
+	return position, nil
+}
+
+// Held reports whether the job with this ID is waiting in the queue or
+// leased to a worker that's still renewing it.
+func (q *Queue) Held(ctx context.Context, id uuid.UUID) (bool, error) {
+	queued, err := q.redis.HExists(ctx, queueKeys[1], id.String()).Result()
+	if err != nil || queued {
+		return queued, err
+	}
+
+	expiry, err := q.redis.ZScore(ctx, queueKeys[2], id.String()).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return int64(expiry) > time.Now().UnixMilli(), nil
+}
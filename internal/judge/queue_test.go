@@ -0,0 +1,334 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestQueue(t *testing.T, userLimit int, maxQueued int) *Queue {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewQueue(client, userLimit, maxQueued)
+}
+
+func newTestJob(lane Lane, user uuid.UUID) Job {
+	return Job{ID: uuid.New(), Lane: lane, UserID: user}
+}
+
+func TestQueueFairness(t *testing.T) {
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name   string
+		pushed []Job
+		// popped lists indexes into pushed in the order they should come out
+		popped []int
+	}{
+		{
+			name:   "one user is first in first out",
+			pushed: []Job{newTestJob(LaneSubmit, alice), newTestJob(LaneSubmit, alice), newTestJob(LaneSubmit, alice)},
+			popped: []int{0, 1, 2},
+		},
+		{
+			name: "users take turns",
+			pushed: []Job{
+				newTestJob(LaneSubmit, alice), newTestJob(LaneSubmit, alice), newTestJob(LaneSubmit, alice),
+				newTestJob(LaneSubmit, bob), newTestJob(LaneSubmit, bob),
+				newTestJob(LaneSubmit, carol),
+			},
+			popped: []int{0, 3, 5, 1, 4, 2},
+		},
+		{
+			name: "runs go before submits",
+			pushed: []Job{
+				newTestJob(LaneSubmit, alice), newTestJob(LaneSubmit, bob),
+				newTestJob(LaneRun, carol), newTestJob(LaneRun, alice),
+			},
+			popped: []int{2, 3, 0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			q := newTestQueue(t, 10, 100)
+
+			for _, job := range tt.pushed {
+				if _, err := q.Push(ctx, job); err != nil {
+					t.Fatalf("Push() error: %v", err)
+				}
+			}
+
+			for n, i := range tt.popped {
+				job, err := q.Pop(ctx)
+				if err != nil {
+					t.Fatalf("Pop() error: %v", err)
+				}
+				if job.ID != tt.pushed[i].ID {
+					t.Fatalf("pop %d got a job of %s, want job %d", n, job.UserID, i)
+				}
+			}
+		})
+	}
+}
+
+func TestQueuePosition(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueue(t, 10, 100)
+	alice, bob := uuid.New(), uuid.New()
+
+	jobs := []Job{
+		newTestJob(LaneSubmit, alice),
+		newTestJob(LaneSubmit, alice),
+		newTestJob(LaneSubmit, bob),
+		newTestJob(LaneRun, bob),
+	}
+
+	// Bob's submit overtakes Alice's second, and the run overtakes everything
+	wantPushed := []int{1, 2, 2, 1}
+	for i, job := range jobs {
+		position, err := q.Push(ctx, job)
+		if err != nil {
+			t.Fatalf("Push() error: %v", err)
+		}
+		if position != wantPushed[i] {
+			t.Errorf("job %d pushed at position %d, want %d", i, position, wantPushed[i])
+		}
+	}
+
+	wantNow := []int{2, 4, 3, 1}
+	for i, job := range jobs {
+		position, err := q.Position(ctx, job)
+		if err != nil {
+			t.Fatalf("Position() error: %v", err)
+		}
+		if position != wantNow[i] {
+			t.Errorf("job %d at position %d, want %d", i, position, wantNow[i])
+		}
+	}
+
+	popped, err := q.Pop(ctx)
+	if err != nil {
+		t.Fatalf("Pop() error: %v", err)
+	}
+
+	position, err := q.Position(ctx, popped)
+	if err != nil {
+		t.Fatalf("Position() error: %v", err)
+	}
+	if position != 0 {
+		t.Errorf("popped job at position %d, want 0", position)
+	}
+
+	// Its worker holds it now
+	held, err := q.Held(ctx, popped.ID)
+	if err != nil {
+		t.Fatalf("Held() error: %v", err)
+	}
+	if !held {
+		t.Error("popped job isn't held")
+	}
+
+	if err := q.Done(ctx, popped); err != nil {
+		t.Fatalf("Done() error: %v", err)
+	}
+
+	held, err = q.Held(ctx, popped.ID)
+	if err != nil {
+		t.Fatalf("Held() error: %v", err)
+	}
+	if held {
+		t.Error("finished job is still held")
+	}
+
+	held, err = q.Held(ctx, jobs[0].ID)
+	if err != nil {
+		t.Fatalf("Held() error: %v", err)
+	}
+	if !held {
+		t.Error("waiting job isn't held")
+	}
+}
+
+func TestQueueLimits(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+
+	// Each step pushes a job, or with done set releases the job pushed at
+	// that index, which must have been popped first
+	type step struct {
+		job     Job
+		done    int
+		wantErr error
+	}
+
+	tests := []struct {
+		name      string
+		userLimit int
+		maxQueued int
+		steps     []step
+	}{
+		{
+			name:      "user limit",
+			userLimit: 2,
+			maxQueued: 100,
+			steps: []step{
+				{job: newTestJob(LaneSubmit, alice), done: -1},
+				{job: newTestJob(LaneSubmit, alice), done: -1},
+				{job: newTestJob(LaneSubmit, alice), done: -1, wantErr: ErrUserLimit},
+				{job: newTestJob(LaneSubmit, bob), done: -1},
+			},
+		},
+		{
+			name:      "limits are per lane",
+			userLimit: 1,
+			maxQueued: 100,
+			steps: []step{
+				{job: newTestJob(LaneSubmit, alice), done: -1},
+				{job: newTestJob(LaneRun, alice), done: -1},
+				{job: newTestJob(LaneRun, alice), done: -1, wantErr: ErrUserLimit},
+			},
+		},
+		{
+			name:      "done frees the slot",
+			userLimit: 1,
+			maxQueued: 100,
+			steps: []step{
+				{job: newTestJob(LaneSubmit, alice), done: -1},
+				{job: newTestJob(LaneSubmit, alice), done: -1, wantErr: ErrUserLimit},
+				{done: 0},
+				{job: newTestJob(LaneSubmit, alice), done: -1},
+			},
+		},
+		{
+			name:      "full queue",
+			userLimit: 10,
+			maxQueued: 2,
+			steps: []step{
+				{job: newTestJob(LaneSubmit, alice), done: -1},
+				{job: newTestJob(LaneSubmit, bob), done: -1},
+				{job: newTestJob(LaneSubmit, uuid.New()), done: -1, wantErr: ErrQueueFull},
+				{done: 0},
+				{job: newTestJob(LaneSubmit, uuid.New()), done: -1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			q := newTestQueue(t, tt.userLimit, tt.maxQueued)
+
+			for i, s := range tt.steps {
+				if s.done >= 0 {
+					job, err := q.Pop(ctx)
+					if err != nil {
+						t.Fatalf("step %d: Pop() error: %v", i, err)
+					}
+					if job.ID != tt.steps[s.done].job.ID {
+						t.Fatalf("step %d: popped %s, want the job of step %d", i, job.ID, s.done)
+					}
+					if err := q.Done(ctx, job); err != nil {
+						t.Fatalf("step %d: Done() error: %v", i, err)
+					}
+					continue
+				}
+
+				_, err := q.Push(ctx, s.job)
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d: Push() error = %v, want %v", i, err, s.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestQueueRequeueSkipsLimits(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueue(t, 1, 1)
+	alice := uuid.New()
+
+	if _, err := q.Push(ctx, newTestJob(LaneSubmit, alice)); err != nil {
+		t.Fatalf("Push() error: %v", err)
+	}
+
+	// A recovered job was accepted once already
+	if pushed, err := q.requeue(ctx, newTestJob(LaneSubmit, alice)); err != nil || !pushed {
+		t.Fatalf("requeue() = %v, %v, want it pushed", pushed, err)
+	}
+
+	for range 2 {
+		if _, err := q.Pop(ctx); err != nil {
+			t.Fatalf("Pop() error: %v", err)
+		}
+	}
+}
+
+func TestQueueLeases(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueue(t, 1, 100)
+	q.leaseTTL = 50 * time.Millisecond
+	alice := uuid.New()
+	job := newTestJob(LaneSubmit, alice)
+
+	if _, err := q.Push(ctx, job); err != nil {
+		t.Fatalf("Push() error: %v", err)
+	}
+
+	// Pushing it again, as a handler racing a reclaim would, keeps one copy
+	if pushed, err := q.requeue(ctx, job); err != nil || pushed {
+		t.Fatalf("requeue() of a queued job = %v, %v, want it left alone", pushed, err)
+	}
+
+	if _, err := q.Pop(ctx); err != nil {
+		t.Fatalf("Pop() error: %v", err)
+	}
+
+	// While the worker renews the lease nobody else takes the job
+	for range 3 {
+		time.Sleep(q.leaseTTL / 2)
+		if err := q.Renew(ctx, job); err != nil {
+			t.Fatalf("Renew() error: %v", err)
+		}
+	}
+	if pushed, err := q.requeue(ctx, job); err != nil || pushed {
+		t.Fatalf("requeue() of a leased job = %v, %v, want it left alone", pushed, err)
+	}
+	if _, err := q.Push(ctx, newTestJob(LaneSubmit, alice)); !errors.Is(err, ErrUserLimit) {
+		t.Fatalf("Push() error = %v, the running job should count against the limit", err)
+	}
+
+	// Once the worker stops renewing, the job is taken back exactly once
+	time.Sleep(q.leaseTTL * 2)
+	if pushed, err := q.requeue(ctx, job); err != nil || !pushed {
+		t.Fatalf("requeue() of an expired job = %v, %v, want it pushed", pushed, err)
+	}
+	if pushed, err := q.requeue(ctx, job); err != nil || pushed {
+		t.Fatalf("second requeue() = %v, %v, want it left alone", pushed, err)
+	}
+
+	popped, err := q.Pop(ctx)
+	if err != nil {
+		t.Fatalf("Pop() error: %v", err)
+	}
+	if popped.ID != job.ID {
+		t.Fatalf("popped %s, want the reclaimed job", popped.ID)
+	}
+
+	// The expired lease gave back its slot, so only the reclaimed job counts
+	if err := q.Done(ctx, popped); err != nil {
+		t.Fatalf("Done() error: %v", err)
+	}
+	if _, err := q.Push(ctx, newTestJob(LaneSubmit, alice)); err != nil {
+		t.Fatalf("Push() after Done() error: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package judge
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+// Rejudge queues the problem's finished submissions, or only the given ones,
+// to be judged again against its current testcases. Their verdicts before
+// the rejudge are kept in its history; progress is read back with
+// RejudgeStore.GetRejudgeByID.
+func (p *Pool) Rejudge(ctx context.Context, problemID uuid.UUID, submissionIDs []uuid.UUID) (*models.Rejudge, error) {
+	rejudge, submissions, err := p.RejudgeStore.CreateRejudge(problemID, submissionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Results cached before the testcases changed would come straight back
+	if err := p.Cache.Invalidate(ctx, problemID); err != nil {
+		p.Logger.Println("Error invalidating result cache", problemID, err)
+	}
+
+	for _, submission := range submissions {
+		_, err := p.Queue.requeue(ctx, Job{
+			ID:        submission.ID,
+			Lane:      LaneRejudge,
+			UserID:    submission.UserID,
+			ProblemID: submission.ProblemID,
+			Language:  submission.Language,
+			Code:      submission.Code,
+			RejudgeID: rejudge.ID,
+		})
+		if err == nil {
+			continue
+		}
+
+		// Count it as failed so the rejudge still finishes
+		p.Logger.Println("Error queueing submission for rejudge", submission.ID, err)
+		if err := p.RejudgeStore.RecordRejudgeResult(rejudge.ID, submission.ID, models.StatusIE); err != nil {
+			p.Logger.Println("Error recording rejudge result", submission.ID, err)
+		}
+	}
+
+	p.Logger.Printf("Queued %d submissions of problem %s for rejudge %s", len(submissions), problemID, rejudge.ID)
+	return rejudge, nil
+}
+
+func (p *Pool) rejudge(ctx context.Context, job Job) {
+	status := p.submit(ctx, job)
+
+	err := p.RejudgeStore.RecordRejudgeResult(job.RejudgeID, job.ID, status)
+	if err != nil {
+		p.Logger.Println("Error recording rejudge result", job.ID, err)
+	}
+}
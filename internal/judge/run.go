@@ -0,0 +1,404 @@
+package judge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grvbrk/async0_server/internal/checker"
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/harness"
+	"github.com/grvbrk/async0_server/internal/languages"
+	"github.com/grvbrk/async0_server/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// RunCase is one row of a "Run": either a sample testcase or a custom input
+// whose expected output was produced by the reference solution.
+type RunCase struct {
+	models.TestcaseResult
+	IsCustom       bool   `json:"is_custom"`
+	ReferenceError string `json:"reference_error,omitempty"`
+}
+
+type RunResponse struct {
+	OverallStatus   models.SubmissionStatus `json:"overall_status"`
+	PassedTestcases int                     `json:"passed_testcases"`
+	TotalTestcases  int                     `json:"total_testcases"`
+	// Some case's output or stderr was cut down to the output limit
+	Truncated bool      `json:"truncated,omitempty"`
+	Cases     []RunCase `json:"cases"`
+}
+
+// RunSamples runs the code against the problem's samples and the custom
+// inputs in a single batch, under the problem's limits. The reference
+// solution is run on the custom inputs in the same batch so their expected
+// outputs are known when the user's results are graded. Runs found in the
+// cache aren't sent at all, cache may be nil.
+func RunSamples(ctx context.Context, exec executor.CodeExecutor, cache *ResultCache, chk checker.Checker, problem *models.Problem, language languages.Language, code string, samples []models.Testcase, customInputs []string) (RunResponse, error) {
+	limits := LimitsFor(problem, language)
+
+	testcases := make([]models.Testcase, 0, len(samples)+len(customInputs))
+	testcases = append(testcases, samples...)
+
+	custom := make([]models.Testcase, len(customInputs))
+	for i, input := range customInputs {
+		// Custom inputs are the user's own, so they're never hidden
+		custom[i] = models.Testcase{Input: input, IsSample: true}
+	}
+	testcases = append(testcases, custom...)
+
+	submissions, err := BuildSubmissions(language, problem, code, testcases)
+	if err != nil {
+		return RunResponse{}, err
+	}
+	limits.Apply(submissions)
+
+	// The reference may be in another language than the user's code
+	referenceCode := ""
+	if problem.SolutionCode != "" && len(custom) > 0 {
+		referenceSubmissions, err := referenceSubmissions(problem, custom)
+		if err != nil {
+			return RunResponse{}, err
+		}
+
+		referenceCode = problem.SolutionCode
+		submissions = append(submissions, referenceSubmissions...)
+	}
+
+	if len(submissions) == 0 {
+		return RunResponse{OverallStatus: models.StatusAC, Cases: []RunCase{}}, nil
+	}
+
+	results, err := cache.Execute(ctx, problem.ID, submissions, func(ctx context.Context, submissions []executor.Submission, onResult func(int, executor.Result)) ([]executor.Result, error) {
+		tokens, err := exec.SubmitBatch(ctx, submissions)
+		if err != nil {
+			return nil, fmt.Errorf("error submitting run batch: %w", err)
+		}
+
+		results, err := executor.AwaitBatch(ctx, exec, tokens, func(i int, result executor.Result) {
+			splitOutput(submissions[i], &result)
+			if onResult != nil {
+				onResult(i, result)
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error awaiting run batch: %w", err)
+		}
+
+		for i := range results {
+			splitOutput(submissions[i], &results[i])
+		}
+		return results, nil
+	}, nil)
+	if err != nil {
+		return RunResponse{}, err
+	}
+
+	// Custom inputs only get an expected output if the reference managed one
+	referenceErrors := make([]string, len(custom))
+	for i := range custom {
+		referenceErrors[i] = "No reference solution for this problem"
+		if referenceCode != "" {
+			referenceErrors[i] = referenceOutput(results[len(testcases)+i], &custom[i])
+		}
+	}
+	copy(testcases[len(samples):], custom)
+
+	userResults := results[:len(testcases)]
+	checkable := slices.Clone(userResults)
+	for i, referenceError := range referenceErrors {
+		if referenceError != "" {
+			// Nothing to check against, keep it away from the checker
+			checkable[len(samples)+i].Status.ID = executor.StatusInternalError
+		}
+	}
+
+	checked, err := CheckResults(ctx, chk, checkable, testcases)
+	if err != nil {
+		return RunResponse{}, fmt.Errorf("error checking run output: %w", err)
+	}
+
+	cases := make([]RunCase, len(testcases))
+	graded := make([]models.TestcaseResult, 0, len(testcases))
+
+	for i, testcase := range testcases {
+		runCase := RunCase{TestcaseResult: FormatResult(userResults[i], testcase, checked[i], limits)}
+
+		if i >= len(samples) {
+			runCase.IsCustom = true
+			runCase.ReferenceError = referenceErrors[i-len(samples)]
+		}
+
+		if runCase.ReferenceError != "" {
+			// Without an expected output only the run itself can be judged
+			runCase.TCExpectedOutput = ""
+			runCase.TCPass = false
+			if runCase.TCVerdict == models.StatusWA {
+				runCase.TCVerdict = ""
+			}
+		}
+
+		cases[i] = runCase
+		if runCase.TCVerdict != "" {
+			graded = append(graded, runCase.TestcaseResult)
+		}
+	}
+
+	response := RunResponse{
+		TotalTestcases: len(cases),
+		Cases:          cases,
+	}
+
+	if len(graded) > 0 {
+		response.OverallStatus = ResolveVerdict(graded).Status
+	}
+
+	for _, runCase := range cases {
+		if runCase.TCPass {
+			response.PassedTestcases++
+		}
+		response.Truncated = response.Truncated || runCase.TCOutputTruncated || runCase.TCStderrTruncated
+	}
+
+	return response, nil
+}
+
+func referenceSubmissions(problem *models.Problem, custom []models.Testcase) ([]executor.Submission, error) {
+	language, err := languages.Get(problem.SolutionLanguage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting reference solution language: %w", err)
+	}
+
+	submissions, err := BuildSubmissions(language, problem, problem.SolutionCode, custom)
+	if err != nil {
+		return nil, fmt.Errorf("error building reference submissions: %w", err)
+	}
+
+	LimitsFor(problem, language).Apply(submissions)
+	return submissions, nil
+}
+
+// referenceOutput fills in the testcase's expected output from the reference
+// run, or explains why it couldn't.
+func referenceOutput(result executor.Result, testcase *models.Testcase) string {
+	if result.Status.ID != executor.StatusAccepted && result.Status.ID != executor.StatusWrongAnswer {
+		desc := executor.StatusDescriptions[result.Status.ID]
+		if desc == "" {
+			desc = fmt.Sprintf("Unknown Status (%d)", result.Status.ID)
+		}
+		return "Reference solution failed on this input: " + desc
+	}
+
+	if result.Stdout == nil {
+		return "Reference solution produced no output for this input"
+	}
+
+	if result.StdoutTruncated {
+		return "Reference solution's output is over the output limit"
+	}
+
+	testcase.Output = strings.TrimSpace(*result.Stdout)
+	return ""
+}
+
+const (
+	runErrorUnavailable  = "unavailable"
+	runErrorInvalidInput = "invalid_input"
+	runErrorTimeout      = "timeout"
+)
+
+// runOutcome is how a worker hands a run back to the handler waiting on it,
+// which may be on another server instance. Response is a RunResponse, or a
+// StressResponse for a stress test. Kind keeps the errors the handler tells
+// apart.
+type runOutcome struct {
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Kind     string          `json:"kind,omitempty"`
+}
+
+func runResultKey(id uuid.UUID) string {
+	return queuePrefix + "run:" + id.String()
+}
+
+// runCancelKey marks a run whose handler stopped waiting, so a worker that
+// gets to it later doesn't spend the judge on it.
+func runCancelKey(id uuid.UUID) string {
+	return runResultKey(id) + ":cancelled"
+}
+
+// Run queues the run in the Run lane and waits for a worker to finish it.
+func (p *Pool) Run(ctx context.Context, job Job) (RunResponse, error) {
+	job.Stress = false
+
+	var response RunResponse
+	err := p.runAndWait(ctx, job, &response)
+	return response, err
+}
+
+// Stress queues a stress test of the job's code in the Run lane and waits
+// for a worker to finish it.
+func (p *Pool) Stress(ctx context.Context, job Job) (StressResponse, error) {
+	job.Stress = true
+	job.CustomInputs = nil
+
+	var response StressResponse
+	err := p.runAndWait(ctx, job, &response)
+	return response, err
+}
+
+func (p *Pool) runAndWait(ctx context.Context, job Job, response any) error {
+	job.ID = uuid.New()
+	job.Lane = LaneRun
+
+	_, err := p.Queue.Push(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	wait := 2 * runTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		wait = time.Until(deadline)
+	}
+
+	result, err := p.Redis.BLPop(ctx, wait, runResultKey(job.ID)).Result()
+	if err != nil {
+		// Not the request's context, that's likely what ended the wait
+		if cErr := p.Redis.Set(context.Background(), runCancelKey(job.ID), 1, runCancelTTL).Err(); cErr != nil {
+			p.Logger.Println("Error cancelling run", job.ID, cErr)
+		}
+	}
+	if err == redis.Nil {
+		return fmt.Errorf("waiting for run: %w", context.DeadlineExceeded)
+	}
+	if err != nil {
+		return fmt.Errorf("error waiting for run: %w", err)
+	}
+
+	// BLPop returns the key and the value
+	var outcome runOutcome
+	if err := json.Unmarshal([]byte(result[1]), &outcome); err != nil {
+		return fmt.Errorf("error unmarshalling run outcome: %w", err)
+	}
+
+	switch outcome.Kind {
+	case runErrorUnavailable:
+		return fmt.Errorf("%w: %s", executor.ErrUnavailable, outcome.Error)
+	case runErrorInvalidInput:
+		return fmt.Errorf("%w: %s", harness.ErrInvalidInput, outcome.Error)
+	case runErrorTimeout:
+		return fmt.Errorf("%w: %s", context.DeadlineExceeded, outcome.Error)
+	}
+
+	if outcome.Error != "" {
+		return errors.New(outcome.Error)
+	}
+
+	if err := json.Unmarshal(outcome.Response, response); err != nil {
+		return fmt.Errorf("error unmarshalling run response: %w", err)
+	}
+	return nil
+}
+
+func (p *Pool) run(ctx context.Context, job Job) {
+	cancelled, err := p.Redis.Exists(ctx, runCancelKey(job.ID)).Result()
+	if err != nil {
+		p.Logger.Println("Error checking whether run was cancelled", job.ID, err)
+	}
+	if cancelled > 0 {
+		p.Logger.Println("Skipping run nobody is waiting for", job.ID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, runTimeout)
+	defer cancel()
+
+	var response any
+	if job.Stress {
+		response, err = p.stress(ctx, job)
+	} else {
+		response, err = p.runSamples(ctx, job)
+	}
+
+	var outcome runOutcome
+	if err == nil {
+		outcome.Response, err = json.Marshal(response)
+	}
+
+	if err != nil {
+		outcome.Error = err.Error()
+
+		switch {
+		case errors.Is(err, executor.ErrUnavailable):
+			outcome.Kind = runErrorUnavailable
+		case errors.Is(err, harness.ErrInvalidInput):
+			outcome.Kind = runErrorInvalidInput
+		case errors.Is(err, context.DeadlineExceeded):
+			outcome.Kind = runErrorTimeout
+		}
+	}
+
+	payload, err := json.Marshal(outcome)
+	if err != nil {
+		p.Logger.Println("Error marshalling run outcome", job.ID, err)
+		return
+	}
+
+	// Not the run's context, that may be what just expired
+	key := runResultKey(job.ID)
+	pipe := p.Redis.TxPipeline()
+	pipe.RPush(context.Background(), key, payload)
+	pipe.Expire(context.Background(), key, runResultTTL)
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		p.Logger.Println("Error storing run outcome", job.ID, err)
+	}
+}
+
+func (p *Pool) runSamples(ctx context.Context, job Job) (RunResponse, error) {
+	problem, err := p.ProblemStore.GetProblemByID(job.ProblemID)
+	if err != nil {
+		return RunResponse{}, err
+	}
+
+	samples, err := p.TestcaseStore.GetSampleTestcasesByProblemID(job.ProblemID)
+	if err != nil {
+		return RunResponse{}, err
+	}
+
+	chk, err := NewChecker(p.Executor, problem)
+	if err != nil {
+		return RunResponse{}, err
+	}
+
+	language, err := languages.Get(job.Language)
+	if err != nil {
+		return RunResponse{}, err
+	}
+
+	return RunSamples(ctx, p.Executor, p.Cache, chk, problem, language, job.Code, samples, job.CustomInputs)
+}
+
+func (p *Pool) stress(ctx context.Context, job Job) (StressResponse, error) {
+	problem, err := p.ProblemStore.GetProblemByID(job.ProblemID)
+	if err != nil {
+		return StressResponse{}, err
+	}
+
+	chk, err := NewChecker(p.Executor, problem)
+	if err != nil {
+		return StressResponse{}, err
+	}
+
+	language, err := languages.Get(job.Language)
+	if err != nil {
+		return StressResponse{}, err
+	}
+
+	return StressTest(ctx, p.Executor, chk, problem, language, job.Code)
+}
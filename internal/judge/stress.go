@@ -0,0 +1,222 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grvbrk/async0_server/internal/checker"
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/harness"
+	"github.com/grvbrk/async0_server/internal/languages"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+var ErrCannotStress = errors.New("problem can't be stress tested")
+
+const (
+	// Each input is run twice, by the user's code and the reference
+	stressInputs = 10
+	// Bounds array lengths and values of inputs made from the signature
+	stressSize = 6
+	// A failing input is shrunk at most this many times, trying this many
+	// smaller inputs each time
+	stressShrinkRounds = 12
+	stressShrinkBatch  = 16
+)
+
+type StressResponse struct {
+	Tested int  `json:"tested"`
+	Found  bool `json:"found"`
+	// An input the user's code got wrong, shrunk as far as it would go
+	// while still failing, with the reference solution's output as the
+	// expected one
+	Case *RunCase `json:"case,omitempty"`
+}
+
+// CanStress reports whether there's a reference to compare against and a
+// way to make inputs, the problem's generator or its signature.
+func CanStress(problem *models.Problem) bool {
+	if problem.SolutionCode == "" {
+		return false
+	}
+	return problem.GeneratorCode != "" || problem.Signature != nil
+}
+
+// StressTest runs the code and the reference solution on random inputs
+// and looks for one where the checker doesn't accept the code's output
+// against the reference's. Inputs the reference itself fails on are skipped.
+// When the problem has a signature the shortest failing input is then
+// shrunk, keeping each smaller input the code still gets wrong.
+func StressTest(ctx context.Context, exec executor.CodeExecutor, chk checker.Checker, problem *models.Problem, language languages.Language, code string) (StressResponse, error) {
+	if !CanStress(problem) {
+		return StressResponse{}, ErrCannotStress
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	inputs, err := stressInputsFor(ctx, exec, problem, rng)
+	if err != nil {
+		return StressResponse{}, err
+	}
+
+	tested, failed, err := stressBatch(ctx, exec, chk, problem, language, code, inputs)
+	if err != nil {
+		return StressResponse{}, err
+	}
+
+	response := StressResponse{Tested: tested}
+	if len(failed) == 0 {
+		return response, nil
+	}
+
+	smallest := failed[0]
+	for _, result := range failed[1:] {
+		if len(result.TCInput) < len(smallest.TCInput) {
+			smallest = result
+		}
+	}
+
+	if problem.Signature != nil {
+		smallest = shrink(ctx, exec, chk, problem, language, code, smallest)
+	}
+
+	response.Found = true
+	response.Case = &RunCase{TestcaseResult: smallest, IsCustom: true}
+	return response, nil
+}
+
+// shrink tries smaller versions of the failing input, moving to the first
+// one that still fails, until none do. Finding a smaller case is a bonus, so
+// errors only stop it early.
+func shrink(ctx context.Context, exec executor.CodeExecutor, chk checker.Checker, problem *models.Problem, language languages.Language, code string, failing models.TestcaseResult) models.TestcaseResult {
+	for range stressShrinkRounds {
+		inputs, err := harness.ShrinkInput(*problem.Signature, failing.TCInput, stressShrinkBatch)
+		if err != nil || len(inputs) == 0 {
+			break
+		}
+
+		_, failed, err := stressBatch(ctx, exec, chk, problem, language, code, inputs)
+		if err != nil || len(failed) == 0 {
+			break
+		}
+
+		// The inputs come biggest cut first
+		failing = failed[0]
+	}
+
+	return failing
+}
+
+// stressBatch runs the code and the reference on the inputs and returns how
+// many the reference could answer and, in input order, the results the code
+// got wrong.
+func stressBatch(ctx context.Context, exec executor.CodeExecutor, chk checker.Checker, problem *models.Problem, language languages.Language, code string, inputs []string) (int, []models.TestcaseResult, error) {
+	testcases := make([]models.Testcase, len(inputs))
+	for i, input := range inputs {
+		// The user sees everything about a case they're stress testing
+		testcases[i] = models.Testcase{Input: input, IsSample: true}
+	}
+
+	submissions, err := BuildSubmissions(language, problem, code, testcases)
+	if err != nil {
+		return 0, nil, err
+	}
+	limits := LimitsFor(problem, language)
+	limits.Apply(submissions)
+
+	reference, err := referenceSubmissions(problem, testcases)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	results, err := runBatch(ctx, exec, append(submissions, reference...))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var compared []models.Testcase
+	var userResults []executor.Result
+	for i := range testcases {
+		if referenceOutput(results[len(testcases)+i], &testcases[i]) != "" {
+			continue
+		}
+		compared = append(compared, testcases[i])
+		userResults = append(userResults, results[i])
+	}
+
+	checked, err := CheckResults(ctx, chk, userResults, compared)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error checking stress output: %w", err)
+	}
+
+	var failed []models.TestcaseResult
+	for i, testcase := range compared {
+		result := FormatResult(userResults[i], testcase, checked[i], limits)
+		if !result.TCPass {
+			failed = append(failed, result)
+		}
+	}
+
+	return len(compared), failed, nil
+}
+
+// stressInputsFor prefers the problem's generator, which knows its
+// constraints, over random values that only match the signature's types.
+func stressInputsFor(ctx context.Context, exec executor.CodeExecutor, problem *models.Problem, rng *rand.Rand) ([]string, error) {
+	inputs := make([]string, stressInputs)
+
+	if problem.GeneratorCode == "" {
+		for i := range inputs {
+			input, err := harness.RandomInput(*problem.Signature, rng, 1+i*stressSize/stressInputs)
+			if err != nil {
+				return nil, err
+			}
+			inputs[i] = input
+		}
+		return inputs, nil
+	}
+
+	language, err := languages.Get(problem.GeneratorLanguage)
+	if err != nil {
+		return nil, fmt.Errorf("error getting generator language: %w", err)
+	}
+
+	submissions := make([]executor.Submission, len(inputs))
+	for i := range submissions {
+		submissions[i] = executor.Submission{
+			LanguageID:      language.Judge0ID,
+			SourceCode:      problem.GeneratorCode,
+			Stdin:           strconv.FormatInt(rng.Int63(), 10),
+			CompilerOptions: language.CompilerOptions,
+		}
+	}
+	LimitsFor(problem, language).Apply(submissions)
+
+	results, err := runBatch(ctx, exec, submissions)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs = inputs[:0]
+	for _, result := range results {
+		if result.Status.ID != executor.StatusAccepted || result.Stdout == nil {
+			continue
+		}
+
+		input := strings.TrimSpace(*result.Stdout)
+		if problem.Signature != nil && harness.ValidateInput(*problem.Signature, input) != nil {
+			continue
+		}
+		inputs = append(inputs, input)
+	}
+
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("%w: generator produced no inputs", ErrGeneration)
+	}
+	return inputs, nil
+}
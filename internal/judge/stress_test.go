@@ -0,0 +1,107 @@
+package judge
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grvbrk/async0_server/internal/checker"
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/languages"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+// fakeExecutor finishes every submission as soon as it's queued, with the
+// output run returns for it.
+type fakeExecutor struct {
+	run func(executor.Submission) executor.Result
+
+	mu      sync.Mutex
+	results map[string]executor.Result
+}
+
+func (f *fakeExecutor) SubmitBatch(ctx context.Context, submissions []executor.Submission) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.results == nil {
+		f.results = make(map[string]executor.Result)
+	}
+
+	tokens := make([]string, len(submissions))
+	for i, submission := range submissions {
+		tokens[i] = strconv.Itoa(len(f.results))
+		f.results[tokens[i]] = f.run(submission)
+	}
+	return tokens, nil
+}
+
+func (f *fakeExecutor) GetBatchResults(ctx context.Context, tokens []string) ([]executor.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	results := make([]executor.Result, len(tokens))
+	for i, token := range tokens {
+		results[i] = f.results[token]
+	}
+	return results, nil
+}
+
+func (f *fakeExecutor) Run(ctx context.Context, submission executor.Submission) (executor.Result, error) {
+	return f.run(submission), nil
+}
+
+func TestStressTestShrinks(t *testing.T) {
+	problem := &models.Problem{
+		Signature:        &models.Signature{FunctionName: "sum", Params: []models.Param{{Name: "nums", Type: "int[]"}}, ReturnType: "int"},
+		SolutionCode:     "def sum(nums): return reference(nums)",
+		SolutionLanguage: "python",
+	}
+
+	// Both programs add up the array, the user's forgets the last element
+	exec := &fakeExecutor{run: func(submission executor.Submission) executor.Result {
+		var nums []int
+		if err := json.Unmarshal([]byte(strings.Split(submission.Stdin, "\n")[1]), &nums); err != nil {
+			t.Fatalf("stdin %q isn't the array: %v", submission.Stdin, err)
+		}
+
+		if !strings.Contains(submission.SourceCode, "reference") && len(nums) > 0 {
+			nums = nums[:len(nums)-1]
+		}
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+
+		stdout := "\n" + submission.ResultMarker + strconv.Itoa(total) + "\n"
+		return executor.Result{Status: executor.Status{ID: executor.StatusAccepted}, Stdout: &stdout}
+	}}
+
+	chk, err := checker.New(checker.ModeWhitespace, 0)
+	if err != nil {
+		t.Fatalf("checker.New() error: %v", err)
+	}
+	language, err := languages.Get("python")
+	if err != nil {
+		t.Fatalf("languages.Get() error: %v", err)
+	}
+
+	response, err := StressTest(context.Background(), exec, chk, problem, language, "def sum(nums): return sum(nums[:-1])")
+	if err != nil {
+		t.Fatalf("StressTest() error: %v", err)
+	}
+	if !response.Found {
+		t.Fatal("StressTest() found no failing input")
+	}
+
+	// Nothing smaller than one element next to zero still fails
+	if input := response.Case.TCInput; input != "[1]" && input != "[-1]" {
+		t.Errorf("failing input = %q, want it shrunk to [1] or [-1]", input)
+	}
+	if response.Case.TCExpectedOutput != "1" && response.Case.TCExpectedOutput != "-1" {
+		t.Errorf("expected output = %q, want the reference's answer for the shrunk input", response.Case.TCExpectedOutput)
+	}
+}
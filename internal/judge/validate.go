@@ -0,0 +1,96 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/languages"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+// ValidationSolution is a piece of code expected to pass every testcase of
+// the problem it belongs to.
+type ValidationSolution struct {
+	Name     string
+	Language string
+	Code     string
+}
+
+type SolutionReport struct {
+	Name      string                  `json:"name"`
+	Language  string                  `json:"language"`
+	Passed    bool                    `json:"passed"`
+	Verdict   models.SubmissionStatus `json:"verdict"`
+	Testcases []models.TestcaseResult `json:"testcases"`
+}
+
+type ValidationReport struct {
+	Passed    bool             `json:"passed"`
+	Solutions []SolutionReport `json:"solutions"`
+}
+
+// ValidateSolutions runs every solution against the testcases in one batch,
+// under the problem's limits and with its checker, and reports how each
+// testcase went. A failing solution most likely means a wrong expected
+// output.
+func ValidateSolutions(ctx context.Context, exec executor.CodeExecutor, problem *models.Problem, testcases []models.Testcase, solutions []ValidationSolution) (ValidationReport, error) {
+	report := ValidationReport{Passed: true, Solutions: []SolutionReport{}}
+	if len(solutions) == 0 || len(testcases) == 0 {
+		return report, nil
+	}
+
+	chk, err := NewChecker(exec, problem)
+	if err != nil {
+		return ValidationReport{}, err
+	}
+
+	limits := make([]Limits, len(solutions))
+	var submissions []executor.Submission
+	for i, solution := range solutions {
+		language, err := languages.Get(solution.Language)
+		if err != nil {
+			return ValidationReport{}, fmt.Errorf("%s: %w", solution.Name, err)
+		}
+
+		built, err := BuildSubmissions(language, problem, solution.Code, testcases)
+		if err != nil {
+			return ValidationReport{}, fmt.Errorf("%s: %w", solution.Name, err)
+		}
+
+		limits[i] = LimitsFor(problem, language)
+		limits[i].Apply(built)
+		submissions = append(submissions, built...)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, judgeTimeout)
+	defer cancel()
+
+	results, err := runBatch(ctx, exec, submissions)
+	if err != nil {
+		return ValidationReport{}, err
+	}
+
+	for i, solution := range solutions {
+		solutionResults := results[i*len(testcases) : (i+1)*len(testcases)]
+
+		checked, err := CheckResults(ctx, chk, solutionResults, testcases)
+		if err != nil {
+			return ValidationReport{}, fmt.Errorf("error checking validation output: %w", err)
+		}
+
+		response := FormatResults(solutionResults, testcases, checked, limits[i])
+		solutionReport := SolutionReport{
+			Name:      solution.Name,
+			Language:  solution.Language,
+			Passed:    response.OverallStatus == models.StatusAC,
+			Verdict:   response.OverallStatus,
+			Testcases: response.TestcasesResults,
+		}
+
+		report.Passed = report.Passed && solutionReport.Passed
+		report.Solutions = append(report.Solutions, solutionReport)
+	}
+
+	return report, nil
+}
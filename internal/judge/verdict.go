@@ -0,0 +1,71 @@
+package judge
+
+import (
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+// TestcaseVerdict maps the Judge0 status of a single testcase to a verdict.
+// checked is the problem checker's verdict on the output; for runs that
+// finished cleanly it is the only thing that decides AC, WA or PE.
+func TestcaseVerdict(statusID int, checked models.SubmissionStatus) models.SubmissionStatus {
+	switch statusID {
+	case executor.StatusAccepted, executor.StatusWrongAnswer:
+		if checked == "" {
+			return models.StatusWA
+		}
+		return checked
+	case executor.StatusTimeLimitExceeded:
+		return models.StatusTLE
+	case executor.StatusCompilationError:
+		return models.StatusCE
+	case executor.StatusRuntimeSIGSEGV,
+		executor.StatusRuntimeSIGXFSZ,
+		executor.StatusRuntimeSIGFPE,
+		executor.StatusRuntimeSIGABRT,
+		executor.StatusRuntimeNZEC,
+		executor.StatusRuntimeOther:
+		return models.StatusRE
+	default:
+		// Internal and exec format errors, or a status we don't know about
+		return models.StatusIE
+	}
+}
+
+type Verdict struct {
+	Status   models.SubmissionStatus
+	StatusID int
+	// FirstFailed is the index of the first testcase that didn't pass, nil when all passed
+	FirstFailed *int
+}
+
+// ResolveVerdict picks the overall verdict for a set of testcase results:
+//  1. A compilation error anywhere is CE, it applies to every testcase.
+//  2. A judge failure anywhere is IE, the other results can't be trusted.
+//  3. Otherwise the first failing testcase, in order, decides the verdict.
+//  4. If nothing failed the submission is AC.
+func ResolveVerdict(results []models.TestcaseResult) Verdict {
+	if len(results) == 0 {
+		return Verdict{Status: models.StatusIE, StatusID: executor.StatusInternalError}
+	}
+
+	for _, status := range []models.SubmissionStatus{models.StatusCE, models.StatusIE} {
+		for i, result := range results {
+			if result.TCVerdict == status {
+				return Verdict{Status: status, StatusID: result.TCStatusID, FirstFailed: &i}
+			}
+		}
+	}
+
+	for i, result := range results {
+		if result.TCVerdict != models.StatusAC {
+			statusID := result.TCStatusID
+			if result.TCVerdict == models.StatusWA || result.TCVerdict == models.StatusPE {
+				statusID = executor.StatusWrongAnswer
+			}
+			return Verdict{Status: result.TCVerdict, StatusID: statusID, FirstFailed: &i}
+		}
+	}
+
+	return Verdict{Status: models.StatusAC, StatusID: executor.StatusAccepted}
+}
@@ -0,0 +1,128 @@
+package judge
+
+import (
+	"testing"
+
+	"github.com/grvbrk/async0_server/internal/executor"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+func TestTestcaseVerdict(t *testing.T) {
+	tests := []struct {
+		name     string
+		statusID int
+		checked  models.SubmissionStatus
+		want     models.SubmissionStatus
+	}{
+		{"accepted and checked", executor.StatusAccepted, models.StatusAC, models.StatusAC},
+		{"checker says presentation", executor.StatusAccepted, models.StatusPE, models.StatusPE},
+		{"judge0 wrong answer is rechecked", executor.StatusWrongAnswer, models.StatusAC, models.StatusAC},
+		{"not checked", executor.StatusAccepted, "", models.StatusWA},
+		{"time limit", executor.StatusTimeLimitExceeded, models.StatusAC, models.StatusTLE},
+		{"compilation error", executor.StatusCompilationError, "", models.StatusCE},
+		{"segfault", executor.StatusRuntimeSIGSEGV, "", models.StatusRE},
+		{"non-zero exit", executor.StatusRuntimeNZEC, "", models.StatusRE},
+		{"internal error", executor.StatusInternalError, "", models.StatusIE},
+		{"exec format error", executor.StatusExecFormatError, "", models.StatusIE},
+		{"unknown status", 99, models.StatusAC, models.StatusIE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TestcaseVerdict(tt.statusID, tt.checked); got != tt.want {
+				t.Errorf("TestcaseVerdict(%d, %q) = %q, want %q", tt.statusID, tt.checked, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveVerdict(t *testing.T) {
+	result := func(verdict models.SubmissionStatus, statusID int) models.TestcaseResult {
+		return models.TestcaseResult{TCVerdict: verdict, TCStatusID: statusID}
+	}
+
+	tests := []struct {
+		name        string
+		results     []models.TestcaseResult
+		wantStatus  models.SubmissionStatus
+		wantID      int
+		firstFailed int // -1 when nothing failed
+	}{
+		{
+			name:        "no results",
+			wantStatus:  models.StatusIE,
+			wantID:      executor.StatusInternalError,
+			firstFailed: -1,
+		},
+		{
+			name: "all accepted",
+			results: []models.TestcaseResult{
+				result(models.StatusAC, executor.StatusAccepted),
+				result(models.StatusAC, executor.StatusAccepted),
+			},
+			wantStatus:  models.StatusAC,
+			wantID:      executor.StatusAccepted,
+			firstFailed: -1,
+		},
+		{
+			name: "first failure decides",
+			results: []models.TestcaseResult{
+				result(models.StatusAC, executor.StatusAccepted),
+				result(models.StatusTLE, executor.StatusTimeLimitExceeded),
+				result(models.StatusRE, executor.StatusRuntimeNZEC),
+			},
+			wantStatus:  models.StatusTLE,
+			wantID:      executor.StatusTimeLimitExceeded,
+			firstFailed: 1,
+		},
+		{
+			name: "checker verdicts report wrong answer",
+			results: []models.TestcaseResult{
+				result(models.StatusPE, executor.StatusAccepted),
+			},
+			wantStatus:  models.StatusPE,
+			wantID:      executor.StatusWrongAnswer,
+			firstFailed: 0,
+		},
+		{
+			name: "compilation error wins over earlier failures",
+			results: []models.TestcaseResult{
+				result(models.StatusWA, executor.StatusWrongAnswer),
+				result(models.StatusIE, executor.StatusInternalError),
+				result(models.StatusCE, executor.StatusCompilationError),
+			},
+			wantStatus:  models.StatusCE,
+			wantID:      executor.StatusCompilationError,
+			firstFailed: 2,
+		},
+		{
+			name: "internal error wins over other failures",
+			results: []models.TestcaseResult{
+				result(models.StatusWA, executor.StatusWrongAnswer),
+				result(models.StatusIE, executor.StatusInternalError),
+			},
+			wantStatus:  models.StatusIE,
+			wantID:      executor.StatusInternalError,
+			firstFailed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResolveVerdict(tt.results)
+
+			if got.Status != tt.wantStatus || got.StatusID != tt.wantID {
+				t.Errorf("ResolveVerdict() = %q (%d), want %q (%d)", got.Status, got.StatusID, tt.wantStatus, tt.wantID)
+			}
+
+			switch {
+			case tt.firstFailed < 0 && got.FirstFailed != nil:
+				t.Errorf("FirstFailed = %d, want nil", *got.FirstFailed)
+			case tt.firstFailed >= 0 && got.FirstFailed == nil:
+				t.Errorf("FirstFailed = nil, want %d", tt.firstFailed)
+			case tt.firstFailed >= 0 && *got.FirstFailed != tt.firstFailed:
+				t.Errorf("FirstFailed = %d, want %d", *got.FirstFailed, tt.firstFailed)
+			}
+		})
+	}
+}
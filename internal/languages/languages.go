@@ -0,0 +1,221 @@
+package languages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrUnknownLanguage = errors.New("unknown language")
+	ErrNoHarness       = errors.New("language has no harness for this problem")
+)
+
+const Default = "javascript"
+
+// Language is one language users can submit in, with the Judge0 ID it runs
+// as and the harness that turns the user's code into a program calling the
+// function a testcase names and printing the result as JSON.
+type Language struct {
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	Judge0ID int    `json:"judge0_id"`
+
+	// harness is a format string taking the user's code as a string
+	// literal and the code as it is. The program reads what
+	// harness.CallStdin writes, the run's result marker, the function's name
+	// and its arguments, and never evaluates the input. Empty when a typed
+	// signature is needed instead.
+	harness string
+
+	// Headroom on top of the problem's limits for the runtime's own startup
+	// cost, so it doesn't eat into what the problem promises.
+	TimeFactor    float64 `json:"-"`
+	ExtraMemoryMB int     `json:"-"`
+
+	// Flags for the compiler, Judge0's defaults are too old for some of the
+	// generated harnesses
+	CompilerOptions string `json:"-"`
+}
+
+var registry = map[string]Language{
+	"javascript": {
+		Slug:     "javascript",
+		Name:     "JavaScript",
+		Judge0ID: 63,
+		// The user's code runs as a script of its own, solution.js, after
+		// the capture starts. Its top level declarations land in the global
+		// scope, where the function is looked up.
+		harness: `
+			const harnessLines = require("fs").readFileSync(0, "utf8").split("\n").filter((line) => line !== "");
+			const harnessArgs = harnessLines.slice(2).map((line) => JSON.parse(line));
+			const harnessWrite = process.stdout.write.bind(process.stdout);
+			const harnessLogs = [];
+			process.stdout.write = (chunk) => {
+				harnessLogs.push(String(chunk));
+				return true;
+			};
+			globalThis.require = require;
+			try {
+				const harnessVm = require("vm");
+				harnessVm.runInThisContext(%[1]s, { filename: "solution.js" });
+				const result = harnessVm.runInThisContext(harnessLines[1])(...harnessArgs);
+				process.stdout.write = harnessWrite;
+				harnessWrite("\n" + harnessLines[0] + JSON.stringify(result) + "\n" + harnessLogs.join(""));
+			} catch (error) {
+				process.stdout.write = harnessWrite;
+				harnessWrite(harnessLogs.join(""));
+				console.error('Runtime Error:', error instanceof SyntaxError ? error.stack : error.message);
+				process.exit(1);
+			}
+		`,
+		TimeFactor:    1.5,
+		ExtraMemoryMB: 64,
+	},
+	"typescript": {
+		Slug:     "typescript",
+		Name:     "TypeScript",
+		Judge0ID: 74,
+		// TypeScript has to be compiled with the rest, so the user's code
+		// goes last and the call waits for it to have run. No process.exit
+		// or require, the node typings may not be installed. Rethrowing
+		// still exits non-zero.
+		harness: `
+			const harnessProcess: any = (globalThis as any).process;
+			const harnessLines: string[] = eval("require")("fs").readFileSync(0, "utf8").split("\n").filter((line: string) => line !== "");
+			const harnessArgs: any[] = harnessLines.slice(2).map((line: string) => JSON.parse(line));
+			const harnessWrite = harnessProcess.stdout.write.bind(harnessProcess.stdout);
+			const harnessLogs: string[] = [];
+			harnessProcess.stdout.write = (chunk: any) => {
+				harnessLogs.push(String(chunk));
+				return true;
+			};
+			function harnessMain() {
+				try {
+					const result = eval(harnessLines[1])(...harnessArgs);
+					harnessProcess.stdout.write = harnessWrite;
+					harnessWrite("\n" + harnessLines[0] + JSON.stringify(result) + "\n" + harnessLogs.join(""));
+				} catch (error) {
+					harnessProcess.stdout.write = harnessWrite;
+					harnessWrite(harnessLogs.join(""));
+					console.error('Runtime Error:', error.message);
+					throw error;
+				}
+			}
+			(globalThis as any).setTimeout(harnessMain, 0);
+
+%[2]s
+`,
+		TimeFactor:    1.5,
+		ExtraMemoryMB: 64,
+	},
+	"python": {
+		Slug:     "python",
+		Name:     "Python",
+		Judge0ID: 71,
+		// The user's code is compiled on its own as solution.py, after the
+		// capture starts
+		harness: `import io
+import json
+import sys
+import traceback
+
+harness_lines = [line for line in sys.stdin.read().split("\n") if line]
+harness_args = [json.loads(line) for line in harness_lines[2:]]
+harness_stdout = sys.stdout
+harness_logs = sys.stdout = io.StringIO()
+harness_scope = {"__name__": "__main__"}
+
+try:
+    exec(compile(%[1]s, "solution.py", "exec"), harness_scope)
+    result = harness_scope[harness_lines[1]](*harness_args)
+    sys.stdout = harness_stdout
+    print("\n" + harness_lines[0] + json.dumps(result, separators=(",", ":")))
+    sys.stdout.write(harness_logs.getvalue())
+except SyntaxError as error:
+    sys.stdout = harness_stdout
+    sys.stdout.write(harness_logs.getvalue())
+    print("".join(traceback.format_exception_only(type(error), error)), end="", file=sys.stderr)
+    sys.exit(1)
+except Exception as error:
+    sys.stdout = harness_stdout
+    sys.stdout.write(harness_logs.getvalue())
+    print("Runtime Error:", error, file=sys.stderr)
+    sys.exit(1)
+`,
+		TimeFactor:    2,
+		ExtraMemoryMB: 32,
+	},
+	"go": {
+		Slug:          "go",
+		Name:          "Go",
+		Judge0ID:      60,
+		TimeFactor:    1,
+		ExtraMemoryMB: 32,
+	},
+	"cpp": {
+		Slug:            "cpp",
+		Name:            "C++",
+		Judge0ID:        54,
+		TimeFactor:      1,
+		ExtraMemoryMB:   16,
+		CompilerOptions: "-std=c++17 -O2",
+	},
+	"java": {
+		Slug:          "java",
+		Name:          "Java",
+		Judge0ID:      62,
+		TimeFactor:    2,
+		ExtraMemoryMB: 128,
+	},
+}
+
+// Get looks a language up by slug. An empty slug is the default language.
+func Get(slug string) (Language, error) {
+	if slug == "" {
+		slug = Default
+	}
+
+	language, ok := registry[slug]
+	if !ok {
+		return Language{}, fmt.Errorf("%w: %q", ErrUnknownLanguage, slug)
+	}
+
+	return language, nil
+}
+
+// All returns every registered language, sorted by slug.
+func All() []Language {
+	all := make([]Language, 0, len(registry))
+	for _, language := range registry {
+		all = append(all, language)
+	}
+
+	slices.SortFunc(all, func(a, b Language) int {
+		return strings.Compare(a.Slug, b.Slug)
+	})
+
+	return all
+}
+
+func (l Language) HasHarness() bool {
+	return l.harness != ""
+}
+
+// Harness wraps the user's code so it calls the function given on stdin and
+// prints the result on the line of the marker given there.
+func (l Language) Harness(code string) (string, error) {
+	if l.harness == "" {
+		return "", fmt.Errorf("%w: %s", ErrNoHarness, l.Name)
+	}
+
+	// JSON strings are valid JavaScript and Python string literals
+	literal, err := json.Marshal(code)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(l.harness, literal, code), nil
+}
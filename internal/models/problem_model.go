@@ -1,27 +1,133 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Problem struct {
-	ID                    uuid.UUID `json:"id"`
-	Name                  string    `json:"name"`
-	Slug                  string    `json:"slug"`
-	Description           string    `json:"description"`
-	Link                  string    `json:"link,omitempty"`
-	ProblemNumber         *int      `json:"problem_number,omitempty"`
-	Difficulty            string    `json:"difficulty"`
-	StarterCode           any       `json:"starter_code"`
-	SolutionCode          any       `json:"solution_code,omitempty"`
-	TimeLimit             int       `json:"time_limit"`
-	MemoryLimit           int       `json:"memory_limit"`
-	AcceptanceRate        *float64  `json:"acceptance_rate,omitempty"`
-	TotalSubmissions      int       `json:"total_submissions"`
-	SuccessfulSubmissions int       `json:"successful_submissions"`
-	IsActive              bool      `json:"is_active"`
-	CreatedAt             time.Time `json:"created_at"`
-	UpdatedAt             time.Time `json:"updated_at"`
+	ID                    uuid.UUID   `json:"id"`
+	Name                  string      `json:"name"`
+	Slug                  string      `json:"slug"`
+	Description           string      `json:"description"`
+	Link                  string      `json:"link,omitempty"`
+	ProblemNumber         *int        `json:"problem_number,omitempty"`
+	Difficulty            string      `json:"difficulty"`
+	StarterCode           StarterCode `json:"starter_code"`
+	SolutionCode          string      `json:"solution_code,omitempty"`
+	SolutionLanguage      string      `json:"solution_language,omitempty"`
+	Signature             *Signature  `json:"signature,omitempty"`
+	Checker               string      `json:"checker"`
+	CheckerTolerance      *float64    `json:"checker_tolerance,omitempty"`
+	CheckerCode           string      `json:"checker_code,omitempty"`
+	GeneratorCode         string      `json:"generator_code,omitempty"`
+	GeneratorLanguage     string      `json:"generator_language,omitempty"`
+	GeneratorSeeds        Seeds       `json:"generator_seeds,omitempty"`
+	FailFast              bool        `json:"fail_fast"`
+	IOMode                IOMode      `json:"io_mode"`
+	TimeLimit             int         `json:"time_limit"`
+	MemoryLimit           int         `json:"memory_limit"`
+	AcceptanceRate        *float64    `json:"acceptance_rate,omitempty"`
+	TotalSubmissions      int         `json:"total_submissions"`
+	SuccessfulSubmissions int         `json:"successful_submissions"`
+	IsActive              bool        `json:"is_active"`
+	CreatedAt             time.Time   `json:"created_at"`
+	UpdatedAt             time.Time   `json:"updated_at"`
+}
+
+// IOMode is how a problem's code gets its input. Function problems call the
+// user's function with each testcase's arguments, stdio problems run the
+// user's whole program with the input on stdin and check what it prints.
+type IOMode string
+
+const (
+	IOModeFunction IOMode = "function"
+	IOModeStdio    IOMode = "stdio"
+)
+
+func (m IOMode) IsValid() bool {
+	return m == IOModeFunction || m == IOModeStdio
+}
+
+// StarterCode is the code a problem starts with, keyed by language slug. It
+// is stored as JSONB.
+type StarterCode map[string]string
+
+func (sc *StarterCode) Scan(src any) error {
+	if src == nil {
+		*sc = nil
+		return nil
+	}
+	return scanJSON(src, sc)
+}
+
+func (sc StarterCode) Value() (driver.Value, error) {
+	if sc == nil {
+		return "{}", nil
+	}
+	return valueJSON(sc)
+}
+
+// Seeds are what a problem's generator is run with, one generated testcase
+// each. They are stored as JSONB.
+type Seeds []string
+
+func (s *Seeds) Scan(src any) error {
+	if src == nil {
+		*s = nil
+		return nil
+	}
+	return scanJSON(src, s)
+}
+
+func (s Seeds) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	return valueJSON(s)
+}
+
+// Signature declares the function a problem's testcases call, so a harness
+// can be generated for any language. Testcase inputs are then the arguments
+// as JSON values, separated by commas or newlines.
+type Signature struct {
+	FunctionName string  `json:"function_name"`
+	Params       []Param `json:"params"`
+	ReturnType   string  `json:"return_type"`
+}
+
+type Param struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (s *Signature) Scan(src any) error {
+	return scanJSON(src, s)
+}
+
+func (s Signature) Value() (driver.Value, error) {
+	return valueJSON(s)
+}
+
+func scanJSON(src any, dst any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dst)
+	}
+}
+
+func valueJSON(v any) (driver.Value, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
 }
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Rejudge re-runs finished submissions against a problem's current testcases.
+// Done counts the submissions judged so far, Changed those whose verdict
+// moved and Failed those that ended in an internal error.
+type Rejudge struct {
+	ID         uuid.UUID  `json:"id"`
+	ProblemID  uuid.UUID  `json:"problem_id"`
+	Total      int        `json:"total"`
+	Done       int        `json:"done"`
+	Changed    int        `json:"changed"`
+	Failed     int        `json:"failed"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+func (r Rejudge) IsFinished() bool {
+	return r.Done >= r.Total
+}
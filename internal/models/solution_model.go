@@ -13,6 +13,7 @@ type Solution struct {
 	Hint            string    `json:"hint"`
 	Description     string    `json:"description"`
 	Code            string    `json:"code"`
+	Language        string    `json:"language"`
 	CodeExplanation string    `json:"code_explanation"`
 	Notes           string    `json:"notes"`
 	TimeComplexity  string    `json:"time_complexity"`
@@ -31,6 +32,7 @@ type SolutionBasic struct {
 	Hint            string    `json:"hint"`
 	Description     string    `json:"description"`
 	Code            string    `json:"code"`
+	Language        string    `json:"language"`
 	CodeExplanation string    `json:"code_explanation"`
 	Notes           string    `json:"notes"`
 	TimeComplexity  string    `json:"time_complexity"`
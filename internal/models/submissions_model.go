@@ -18,36 +18,90 @@ const (
 	StatusPE      SubmissionStatus = "PE"
 	StatusPending SubmissionStatus = "PENDING"
 	StatusRunning SubmissionStatus = "RUNNING"
+	StatusIE      SubmissionStatus = "IE"
 )
 
 type Submission struct {
-	ID              uuid.UUID        `json:"id"`
-	UserID          uuid.UUID        `json:"user_id"`
-	ProblemID       uuid.UUID        `json:"problem_id"`
-	Code            string           `json:"code"`
-	Status          SubmissionStatus `json:"status"`
-	Runtime         *int             `json:"runtime"`
-	MemoryUsed      *int             `json:"memory_used"`
-	TotalTestcases  *int             `json:"total_testcases"`
-	PassedTestcases *int             `json:"passed_testcases"`
-	FailedTestcases *int             `json:"failed_testcases"`
-	CreatedAt       time.Time        `json:"created_at"`
+	ID                  uuid.UUID        `json:"id"`
+	UserID              uuid.UUID        `json:"user_id"`
+	ProblemID           uuid.UUID        `json:"problem_id"`
+	Language            string           `json:"language"`
+	Code                string           `json:"code"`
+	Status              SubmissionStatus `json:"status"`
+	Runtime             *int             `json:"runtime"`
+	MemoryUsed          *int             `json:"memory_used"`
+	TotalTestcases      *int             `json:"total_testcases"`
+	PassedTestcases     *int             `json:"passed_testcases"`
+	FailedTestcases     *int             `json:"failed_testcases"`
+	FirstFailedTestcase *int             `json:"first_failed_testcase"`
+	CreatedAt           time.Time        `json:"created_at"`
+}
+
+func (s SubmissionStatus) IsFinal() bool {
+	return s != StatusPending && s != StatusRunning
 }
 
 type TestcaseResult struct {
-	TCPass           bool   `json:"tc_pass"`
-	TCStatusID       int    `json:"tc_status_id"`
-	TCStatus         string `json:"tc_status"`
-	TCTime           string `json:"tc_time"`
-	TCMemory         int    `json:"tc_memory"`
-	TCOutput         string `json:"tc_output"`
+	TCTestcaseID *uuid.UUID       `json:"tc_testcase_id,omitempty"`
+	TCHidden     bool             `json:"tc_hidden"`
+	TCPass       bool             `json:"tc_pass"`
+	TCStatusID   int              `json:"tc_status_id"`
+	TCStatus     string           `json:"tc_status"`
+	TCVerdict    SubmissionStatus `json:"tc_verdict"`
+	TCTime       float64          `json:"tc_time"`
+	TCMemory     int              `json:"tc_memory"`
+	TCInput      string           `json:"tc_input,omitempty"`
+	TCOutput     string           `json:"tc_output"`
+	TCStderr     string           `json:"tc_stderr,omitempty"`
+	// What the code printed besides the result the harness reported
+	TCLog            string `json:"tc_log,omitempty"`
 	TCExpectedOutput string `json:"tc_expected_output"`
+
+	// The output or stderr was cut down to the judge's output limit
+	TCOutputTruncated bool `json:"tc_output_truncated,omitempty"`
+	TCStderrTruncated bool `json:"tc_stderr_truncated,omitempty"`
+}
+
+// Redacted hides the input and expected output of a hidden testcase so they
+// can't be read back out of a submission's results.
+func (r TestcaseResult) Redacted() TestcaseResult {
+	if r.TCHidden {
+		r.TCInput = ""
+		r.TCExpectedOutput = ""
+	}
+	return r
+}
+
+func RedactTestcaseResults(results []TestcaseResult) []TestcaseResult {
+	redacted := make([]TestcaseResult, len(results))
+	for i, result := range results {
+		redacted[i] = result.Redacted()
+	}
+	return redacted
 }
 
 type SubmitSubmissionResponse struct {
-	OverallStatusID  int              `json:"overall_status_id"`
-	OverallStatus    SubmissionStatus `json:"overall_status"`
-	PassedTestcases  int              `json:"passed_testcases"`
-	TotalTestcases   int              `json:"total_testcases"`
-	TestcasesResults []TestcaseResult `json:"testcases_results"`
+	OverallStatusID     int              `json:"overall_status_id"`
+	OverallStatus       SubmissionStatus `json:"overall_status"`
+	PassedTestcases     int              `json:"passed_testcases"`
+	TotalTestcases      int              `json:"total_testcases"`
+	FirstFailedTestcase *int             `json:"first_failed_testcase,omitempty"`
+	FirstFailedVerdict  SubmissionStatus `json:"first_failed_verdict,omitempty"`
+	Runtime             int              `json:"runtime"`
+	MemoryUsed          int              `json:"memory_used"`
+	TestcasesResults    []TestcaseResult `json:"testcases_results"`
+}
+
+func (r SubmitSubmissionResponse) Redacted() SubmitSubmissionResponse {
+	r.TestcasesResults = RedactTestcaseResults(r.TestcasesResults)
+	return r
+}
+
+type SubmissionPercentile struct {
+	SubmissionID  uuid.UUID `json:"submission_id"`
+	Runtime       int       `json:"runtime"`
+	MemoryUsed    int       `json:"memory_used"`
+	RuntimeBeats  float64   `json:"runtime_beats"`
+	MemoryBeats   float64   `json:"memory_beats"`
+	TotalAccepted int       `json:"total_accepted"`
 }
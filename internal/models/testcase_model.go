@@ -7,19 +7,26 @@ import (
 )
 
 type Testcase struct {
-	ID        uuid.UUID `json:"id"`
-	ProblemID uuid.UUID `json:"problem_id"`
-	UI        string    `json:"ui"`
-	Input     string    `json:"input"`
-	Output    string    `json:"output"`
-	Position  int       `json:"position"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          uuid.UUID `json:"id"`
+	ProblemID   uuid.UUID `json:"problem_id"`
+	UI          string    `json:"ui"`
+	Input       string    `json:"input"`
+	Output      string    `json:"output"`
+	Position    int       `json:"position"`
+	IsSample    bool      `json:"is_sample"`
+	IsActive    bool      `json:"is_active"`
+	IsGenerated bool      `json:"is_generated"`
+	Seed        string    `json:"seed,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type TestcaseBasic struct {
-	ID     uuid.UUID `json:"id"`
-	UI     string    `json:"ui"`
-	Input  string    `json:"input"`
-	Output string    `json:"output"`
+	ID          uuid.UUID `json:"id"`
+	UI          string    `json:"ui"`
+	Input       string    `json:"input"`
+	Output      string    `json:"output"`
+	Position    int       `json:"position"`
+	IsSample    bool      `json:"is_sample"`
+	IsGenerated bool      `json:"is_generated"`
+	Seed        string    `json:"seed,omitempty"`
 }
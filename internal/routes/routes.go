@@ -67,8 +67,13 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 			r.Use(app.MiddlewareHandler.Authenticate)
 
 			r.Get("/problem/{problemID}", app.UserSubmissionHandler.HandlerGetSubmissionsByProblemID)
+			r.Get("/{submissionID}", app.UserSubmissionHandler.HandlerGetSubmissionByID)
+			r.Get("/{submissionID}/results", app.UserSubmissionHandler.HandlerGetSubmissionResults)
+			r.Get("/{submissionID}/percentile", app.UserSubmissionHandler.HandlerGetSubmissionPercentile)
+			r.Get("/{submissionID}/events", app.UserSubmissionHandler.HandlerStreamSubmissionEvents)
 
-			r.Post("/run", app.UserSubmissionHandler.HandlerRunSubmission)
+			r.Post("/run/{id}", app.UserSubmissionHandler.HandlerRunSubmission)
+			r.Post("/stress/{id}", app.UserSubmissionHandler.HandlerStressSubmission)
 			r.Post("/submit/{id}", app.UserSubmissionHandler.HandlerSubmitSubmission)
 		})
 
@@ -79,6 +84,11 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 
 	})
 
+	// Called by Judge0, not by browsers, so no CORS or session auth
+	r.Route("/internal", func(r chi.Router) {
+		r.Put("/judge0/callback", app.JudgeCallbackHandler.HandlerJudge0Callback)
+	})
+
 	r.Route("/admin", func(r chi.Router) {
 		r.Use(httprate.LimitAll(100, time.Minute))
 		r.Use(app.MiddlewareHandler.Cors)
@@ -89,6 +99,12 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 			r.Get("/{id}", app.AdminProblemHandler.HandlerGetProblemByID)
 			r.Post("/", app.AdminProblemHandler.HandlerCreateProblem)
 			r.Put("/{id}", app.AdminProblemHandler.HandlerUpdateProblem)
+			r.Post("/{id}/rejudge", app.AdminRejudgeHandler.HandlerRejudgeProblem)
+			r.Post("/{id}/generate", app.AdminProblemHandler.HandlerGenerateTestcases)
+		})
+
+		r.Route("/rejudges", func(r chi.Router) {
+			r.Get("/{id}", app.AdminRejudgeHandler.HandlerGetRejudgeByID)
 		})
 
 		r.Route("/lists", func(r chi.Router) {
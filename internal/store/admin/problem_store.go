@@ -2,12 +2,15 @@ package admin
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/grvbrk/async0_server/internal/models"
 )
 
+var ErrProblemNotFound = errors.New("problem not found")
+
 type AdminPostgresProblemStore struct {
 	DB *sql.DB
 }
@@ -23,6 +26,8 @@ type AdminProblemStore interface {
 	GetProblemByID(uuid.UUID) (models.Problem, error)
 	UpdateProblem(uuid.UUID, models.Problem, []uuid.UUID, []uuid.UUID, []models.Testcase, []models.Solution) error
 	CreateProblem(models.Problem, []uuid.UUID, []uuid.UUID, []models.Testcase, []models.Solution) error
+	ReplaceGeneratedTestcases(uuid.UUID, []models.Testcase) error
+	GetTestcaseSamples(uuid.UUID) (map[uuid.UUID]bool, error)
 }
 
 func (ap *AdminPostgresProblemStore) GetAllProblems() ([]models.Problem, error) {
@@ -56,7 +61,7 @@ func (ap *AdminPostgresProblemStore) GetAllProblems() ([]models.Problem, error)
 func (ap *AdminPostgresProblemStore) GetProblemByID(problemID uuid.UUID) (models.Problem, error) {
 
 	query := `
-		SELECT id, name, slug, description, link, problem_number, difficulty, starter_code, time_limit, memory_limit, acceptance_rate, total_submissions, successful_submissions, is_active
+		SELECT id, name, slug, description, link, problem_number, difficulty, starter_code, COALESCE(solution_code, ''), solution_language, signature, checker, checker_tolerance, COALESCE(checker_code, ''), COALESCE(generator_code, ''), COALESCE(generator_language, ''), generator_seeds, fail_fast, io_mode, time_limit, memory_limit, acceptance_rate, total_submissions, successful_submissions, is_active
 		FROM problems
 		WHERE id = $1
 	`
@@ -64,13 +69,13 @@ func (ap *AdminPostgresProblemStore) GetProblemByID(problemID uuid.UUID) (models
 	row := ap.DB.QueryRow(query, problemID)
 
 	problem := models.Problem{}
-	err := row.Scan(&problem.ID, &problem.Name, &problem.Slug, &problem.Description, &problem.Link, &problem.ProblemNumber, &problem.Difficulty, &problem.StarterCode, &problem.TimeLimit, &problem.MemoryLimit, &problem.AcceptanceRate, &problem.TotalSubmissions, &problem.SuccessfulSubmissions, &problem.IsActive)
-	if err != nil {
-		return models.Problem{}, fmt.Errorf("error running get problem by id query: %w", err)
+	err := row.Scan(&problem.ID, &problem.Name, &problem.Slug, &problem.Description, &problem.Link, &problem.ProblemNumber, &problem.Difficulty, &problem.StarterCode, &problem.SolutionCode, &problem.SolutionLanguage, &problem.Signature, &problem.Checker, &problem.CheckerTolerance, &problem.CheckerCode, &problem.GeneratorCode, &problem.GeneratorLanguage, &problem.GeneratorSeeds, &problem.FailFast, &problem.IOMode, &problem.TimeLimit, &problem.MemoryLimit, &problem.AcceptanceRate, &problem.TotalSubmissions, &problem.SuccessfulSubmissions, &problem.IsActive)
+	if err == sql.ErrNoRows {
+		return models.Problem{}, ErrProblemNotFound
 	}
 
-	if err == sql.ErrNoRows {
-		return models.Problem{}, fmt.Errorf("problem not found")
+	if err != nil {
+		return models.Problem{}, fmt.Errorf("error running get problem by id query: %w", err)
 	}
 
 	return problem, nil
@@ -93,11 +98,11 @@ func (ap *AdminPostgresProblemStore) CreateProblem(problem models.Problem, listI
 	// insert problem
 	var problemID uuid.UUID
 	query := `
-		INSERT INTO problems (name, slug, description, link, difficulty, starter_code, time_limit, memory_limit, is_active)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO problems (name, slug, description, link, difficulty, starter_code, solution_code, solution_language, signature, checker, checker_tolerance, checker_code, time_limit, memory_limit, is_active, generator_code, generator_language, generator_seeds, fail_fast, io_mode)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13, $14, $15, NULLIF($16, ''), NULLIF($17, ''), $18, $19, $20)
 		RETURNING id
 		`
-	err = tx.QueryRow(query, problem.Name, problem.Slug, problem.Description, problem.Link, problem.Difficulty, problem.StarterCode, problem.TimeLimit, problem.MemoryLimit, problem.IsActive).Scan(&problemID)
+	err = tx.QueryRow(query, problem.Name, problem.Slug, problem.Description, problem.Link, problem.Difficulty, problem.StarterCode, problem.SolutionCode, problem.SolutionLanguage, problem.Signature, problem.Checker, problem.CheckerTolerance, problem.CheckerCode, problem.TimeLimit, problem.MemoryLimit, problem.IsActive, problem.GeneratorCode, problem.GeneratorLanguage, problem.GeneratorSeeds, problem.FailFast, problem.IOMode).Scan(&problemID)
 	if err != nil {
 		return fmt.Errorf("failed to insert problem: %w", err)
 	}
@@ -129,10 +134,10 @@ func (ap *AdminPostgresProblemStore) CreateProblem(problem models.Problem, listI
 	// insert into testcases
 	for _, testcase := range testcases {
 		query := `
-			INSERT INTO testcases (problem_id, ui, input, output, position)
-			VALUES ($1, $2, $3, $4, $5)
+			INSERT INTO testcases (problem_id, ui, input, output, position, is_sample, is_generated, seed)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))
 			`
-		_, err := tx.Exec(query, problemID, testcase.UI, testcase.Input, testcase.Output, testcase.Position)
+		_, err := tx.Exec(query, problemID, testcase.UI, testcase.Input, testcase.Output, testcase.Position, testcase.IsSample, testcase.IsGenerated, testcase.Seed)
 		if err != nil {
 			return fmt.Errorf("failed to insert testcases: %w", err)
 		}
@@ -144,10 +149,10 @@ func (ap *AdminPostgresProblemStore) CreateProblem(problem models.Problem, listI
 	if len(solutions) > 0 {
 		for _, solution := range solutions {
 			query := `
-				INSERT INTO solutions (problem_id, title, hint, description, code, code_explanation, notes, time_complexity, space_complexity, difficulty_level, display_order, author, is_active)
-				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+				INSERT INTO solutions (problem_id, title, hint, description, code, code_explanation, notes, time_complexity, space_complexity, difficulty_level, display_order, author, is_active, language)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 				`
-			_, err := tx.Exec(query, problemID, solution.Title, solution.Hint, solution.Description, solution.Code, solution.CodeExplanation, solution.Notes, solution.TimeComplexity, solution.SpaceComplexity, solution.DifficultyLevel, solution.DisplayOrder, solution.Author, solution.IsActive)
+			_, err := tx.Exec(query, problemID, solution.Title, solution.Hint, solution.Description, solution.Code, solution.CodeExplanation, solution.Notes, solution.TimeComplexity, solution.SpaceComplexity, solution.DifficultyLevel, solution.DisplayOrder, solution.Author, solution.IsActive, solution.Language)
 			if err != nil {
 				return fmt.Errorf("failed to insert solutions: %w", err)
 			}
@@ -183,16 +188,29 @@ func (ap *AdminPostgresProblemStore) UpdateProblem(problemID uuid.UUID, problem
 			link = $4,
 			difficulty = $5,
 			starter_code = $6,
-			time_limit = $7,
-			memory_limit = $8,
-			is_active = $9,
+			solution_code = $7,
+			solution_language = $8,
+			signature = $9,
+			checker = $10,
+			checker_tolerance = $11,
+			checker_code = NULLIF($12, ''),
+			time_limit = $13,
+			memory_limit = $14,
+			is_active = $15,
+			generator_code = NULLIF($16, ''),
+			generator_language = NULLIF($17, ''),
+			generator_seeds = $18,
+			fail_fast = $19,
+			io_mode = $20,
 			updated_at = CURRENT_TIMESTAMP
-		WHERE id = $10
+		WHERE id = $21
 	`
 	_, err = tx.Exec(query,
 		problem.Name, problem.Slug, problem.Description, problem.Link,
-		problem.Difficulty, problem.StarterCode, problem.TimeLimit, problem.MemoryLimit,
-		problem.IsActive, problemID)
+		problem.Difficulty, problem.StarterCode, problem.SolutionCode, problem.SolutionLanguage,
+		problem.Signature, problem.Checker, problem.CheckerTolerance, problem.CheckerCode,
+		problem.TimeLimit, problem.MemoryLimit, problem.IsActive,
+		problem.GeneratorCode, problem.GeneratorLanguage, problem.GeneratorSeeds, problem.FailFast, problem.IOMode, problemID)
 	if err != nil {
 		return fmt.Errorf("failed to update problem: %w", err)
 	}
@@ -222,17 +240,38 @@ func (ap *AdminPostgresProblemStore) UpdateProblem(problemID uuid.UUID, problem
 		}
 	}
 
-	// Replace testcases
-	_, err = tx.Exec(`DELETE FROM testcases WHERE problem_id = $1`, problemID)
-	if err != nil {
-		return fmt.Errorf("failed to clear testcases: %w", err)
-	}
+	// Testcases are updated in place so judged results keep pointing at them
+	keep := make([]string, 0, len(testcases))
 	for _, tc := range testcases {
-		_, err = tx.Exec(`INSERT INTO testcases (problem_id, ui, input, output, position) VALUES ($1, $2, $3, $4, $5)`,
-			problemID, tc.UI, tc.Input, tc.Output, tc.Position)
+		if tc.ID != uuid.Nil {
+			result, err := tx.Exec(`UPDATE testcases SET ui = $1, input = $2, output = $3, position = $4, is_sample = $5, is_generated = $6, seed = NULLIF($7, '') WHERE id = $8 AND problem_id = $9`,
+				tc.UI, tc.Input, tc.Output, tc.Position, tc.IsSample, tc.IsGenerated, tc.Seed, tc.ID, problemID)
+			if err != nil {
+				return fmt.Errorf("failed to update testcases: %w", err)
+			}
+
+			updated, err := result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to update testcases: %w", err)
+			}
+			if updated > 0 {
+				keep = append(keep, tc.ID.String())
+				continue
+			}
+		}
+
+		var testcaseID uuid.UUID
+		err = tx.QueryRow(`INSERT INTO testcases (problem_id, ui, input, output, position, is_sample, is_generated, seed) VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, '')) RETURNING id`,
+			problemID, tc.UI, tc.Input, tc.Output, tc.Position, tc.IsSample, tc.IsGenerated, tc.Seed).Scan(&testcaseID)
 		if err != nil {
 			return fmt.Errorf("failed to insert testcases: %w", err)
 		}
+		keep = append(keep, testcaseID.String())
+	}
+
+	_, err = tx.Exec(`DELETE FROM testcases WHERE problem_id = $1 AND NOT (id = ANY($2::uuid[]))`, problemID, keep)
+	if err != nil {
+		return fmt.Errorf("failed to clear removed testcases: %w", err)
 	}
 
 	// Replace solutions
@@ -242,12 +281,12 @@ func (ap *AdminPostgresProblemStore) UpdateProblem(problemID uuid.UUID, problem
 	}
 	for _, s := range solutions {
 		_, err = tx.Exec(`
-			INSERT INTO solutions (problem_id, title, hint, description, code, code_explanation, notes, time_complexity, space_complexity, difficulty_level, display_order, author, is_active)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			INSERT INTO solutions (problem_id, title, hint, description, code, code_explanation, notes, time_complexity, space_complexity, difficulty_level, display_order, author, is_active, language)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 		`,
 			problemID, s.Title, s.Hint, s.Description, s.Code, s.CodeExplanation,
 			s.Notes, s.TimeComplexity, s.SpaceComplexity, s.DifficultyLevel,
-			s.DisplayOrder, s.Author, s.IsActive)
+			s.DisplayOrder, s.Author, s.IsActive, s.Language)
 		if err != nil {
 			return fmt.Errorf("failed to insert solutions: %w", err)
 		}
@@ -258,3 +297,68 @@ func (ap *AdminPostgresProblemStore) UpdateProblem(problemID uuid.UUID, problem
 	}
 	return nil
 }
+
+// ReplaceGeneratedTestcases swaps the problem's generated testcases for the
+// given ones, placing them after the hand written ones.
+func (ap *AdminPostgresProblemStore) ReplaceGeneratedTestcases(problemID uuid.UUID, testcases []models.Testcase) error {
+	tx, err := ap.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	defer func() {
+		if rErr := tx.Rollback(); rErr != nil && rErr != sql.ErrTxDone {
+			fmt.Printf("rollback error: %v", rErr)
+		}
+	}()
+
+	_, err = tx.Exec(`DELETE FROM testcases WHERE problem_id = $1 AND is_generated`, problemID)
+	if err != nil {
+		return fmt.Errorf("failed to clear generated testcases: %w", err)
+	}
+
+	var lastPosition int
+	err = tx.QueryRow(`SELECT COALESCE(MAX(position), 0) FROM testcases WHERE problem_id = $1`, problemID).Scan(&lastPosition)
+	if err != nil {
+		return fmt.Errorf("failed to get last testcase position: %w", err)
+	}
+
+	for i, tc := range testcases {
+		_, err = tx.Exec(`INSERT INTO testcases (problem_id, ui, input, output, position, is_sample, is_generated, seed) VALUES ($1, $2, $3, $4, $5, $6, TRUE, NULLIF($7, ''))`,
+			problemID, tc.UI, tc.Input, tc.Output, lastPosition+i+1, tc.IsSample, tc.Seed)
+		if err != nil {
+			return fmt.Errorf("failed to insert generated testcases: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit generated testcases: %w", err)
+	}
+	return nil
+}
+
+// GetTestcaseSamples returns whether each of the problem's testcases is a
+// sample, by testcase ID.
+func (ap *AdminPostgresProblemStore) GetTestcaseSamples(problemID uuid.UUID) (map[uuid.UUID]bool, error) {
+	rows, err := ap.DB.Query(`SELECT id, is_sample FROM testcases WHERE problem_id = $1`, problemID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying testcase samples: %w", err)
+	}
+	defer rows.Close()
+
+	samples := make(map[uuid.UUID]bool)
+	for rows.Next() {
+		var id uuid.UUID
+		var isSample bool
+		if err := rows.Scan(&id, &isSample); err != nil {
+			return nil, fmt.Errorf("error scanning testcase sample: %w", err)
+		}
+		samples[id] = isSample
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating testcase samples: %w", err)
+	}
+
+	return samples, nil
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+// uuidArrays passes []string arguments, sent to Postgres as uuid[], as
+// array literals the mock can hold.
+type uuidArrays struct{}
+
+func (uuidArrays) ConvertValue(v any) (driver.Value, error) {
+	if values, ok := v.([]string); ok {
+		return "{" + strings.Join(values, ",") + "}", nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+func TestUpdateProblemKeepsTestcaseIDs(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.ValueConverterOption(uuidArrays{}))
+	if err != nil {
+		t.Fatalf("sqlmock.New() error: %v", err)
+	}
+	defer db.Close()
+
+	problemID := uuid.New()
+	kept, gone, replaced, added := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	testcases := []models.Testcase{
+		{ID: kept, Input: "1", Output: "1", Position: 1, IsSample: true},
+		// Deleted by someone else since the admin loaded the problem
+		{ID: gone, Input: "2", Output: "2", Position: 2},
+		{Input: "3", Output: "3", Position: 3},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE problems`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM problem_topics`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM list_problems`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectExec(`UPDATE testcases SET .+ WHERE id = \$8 AND problem_id = \$9`).
+		WithArgs("", "1", "1", 1, true, false, "", kept, problemID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE testcases`).
+		WithArgs("", "2", "2", 2, false, false, "", gone, problemID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO testcases`).
+		WithArgs(problemID, "", "2", "2", 2, false, false, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(replaced.String()))
+	mock.ExpectQuery(`INSERT INTO testcases`).
+		WithArgs(problemID, "", "3", "3", 3, false, false, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(added.String()))
+
+	// Only testcases missing from the update go
+	mock.ExpectExec(`DELETE FROM testcases WHERE problem_id = \$1 AND NOT \(id = ANY\(\$2::uuid\[\]\)\)`).
+		WithArgs(problemID, "{"+kept.String()+","+replaced.String()+","+added.String()+"}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec(`DELETE FROM solutions`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	ps := NewPostgresAdminProblemStore(db)
+	if err := ps.UpdateProblem(problemID, models.Problem{}, nil, nil, testcases, nil); err != nil {
+		t.Fatalf("UpdateProblem() error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
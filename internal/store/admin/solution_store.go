@@ -30,6 +30,7 @@ func (ap *AdminPostgresSolutionStore) GetSolutionsByProblemID(problemID uuid.UUI
 			hint,
 			description,
 			code,
+			language,
 			code_explanation,
 			notes,
 			time_complexity,
@@ -57,6 +58,7 @@ func (ap *AdminPostgresSolutionStore) GetSolutionsByProblemID(problemID uuid.UUI
 			&sol.Hint,
 			&sol.Description,
 			&sol.Code,
+			&sol.Language,
 			&sol.CodeExplanation,
 			&sol.Notes,
 			&sol.TimeComplexity,
@@ -29,9 +29,14 @@ func (ap *AdminPostgresTestcaseStore) GetTestcasesByProblemID(problemID uuid.UUI
 			id,
 			ui,
 			input,
-			output
+			output,
+			position,
+			is_sample,
+			is_generated,
+			COALESCE(seed, '')
 		FROM testcases
 		WHERE problem_id = $1
+		ORDER BY position ASC
 	`
 	rows, err := ap.DB.Query(query, problemID)
 	if err != nil {
@@ -48,6 +53,10 @@ func (ap *AdminPostgresTestcaseStore) GetTestcasesByProblemID(problemID uuid.UUI
 			&tc.UI,
 			&tc.Input,
 			&tc.Output,
+			&tc.Position,
+			&tc.IsSample,
+			&tc.IsGenerated,
+			&tc.Seed,
 		)
 
 		if err != nil {
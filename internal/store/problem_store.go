@@ -32,12 +32,14 @@ type TanstackTableProblem struct {
 
 type ProblemStore interface {
 	GetProblemBySlug(slug string) (*models.Problem, error)
+	// GetProblemByID includes the reference solution, so it's for judging and must not be sent to users as is.
+	GetProblemByID(problemID uuid.UUID) (*models.Problem, error)
 	GetTanstackTableProblemsByListID(userID *uuid.UUID, listID uuid.UUID) ([]TanstackTableProblem, error)
 }
 
 func (p *PostgresProblemStore) GetProblemBySlug(slug string) (*models.Problem, error) {
 	query := `
-		SELECT id, name, slug, description, link, problem_number, difficulty, starter_code, time_limit, memory_limit, acceptance_rate, total_submissions, successful_submissions, is_active
+		SELECT id, name, slug, description, link, problem_number, difficulty, starter_code, signature, io_mode, time_limit, memory_limit, acceptance_rate, total_submissions, successful_submissions, is_active
 		FROM problems
 		WHERE slug = $1
 	`
@@ -52,6 +54,8 @@ func (p *PostgresProblemStore) GetProblemBySlug(slug string) (*models.Problem, e
 		&problem.ProblemNumber,
 		&problem.Difficulty,
 		&problem.StarterCode,
+		&problem.Signature,
+		&problem.IOMode,
 		&problem.TimeLimit,
 		&problem.MemoryLimit,
 		&problem.AcceptanceRate,
@@ -72,6 +76,52 @@ func (p *PostgresProblemStore) GetProblemBySlug(slug string) (*models.Problem, e
 
 }
 
+func (p *PostgresProblemStore) GetProblemByID(problemID uuid.UUID) (*models.Problem, error) {
+	query := `
+		SELECT id, name, slug, description, link, problem_number, difficulty, starter_code, COALESCE(solution_code, ''), solution_language, signature, checker, checker_tolerance, COALESCE(checker_code, ''), COALESCE(generator_code, ''), COALESCE(generator_language, ''), fail_fast, io_mode, time_limit, memory_limit, acceptance_rate, total_submissions, successful_submissions, is_active
+		FROM problems
+		WHERE id = $1
+	`
+
+	var problem models.Problem
+	err := p.DB.QueryRow(query, problemID).Scan(
+		&problem.ID,
+		&problem.Name,
+		&problem.Slug,
+		&problem.Description,
+		&problem.Link,
+		&problem.ProblemNumber,
+		&problem.Difficulty,
+		&problem.StarterCode,
+		&problem.SolutionCode,
+		&problem.SolutionLanguage,
+		&problem.Signature,
+		&problem.Checker,
+		&problem.CheckerTolerance,
+		&problem.CheckerCode,
+		&problem.GeneratorCode,
+		&problem.GeneratorLanguage,
+		&problem.FailFast,
+		&problem.IOMode,
+		&problem.TimeLimit,
+		&problem.MemoryLimit,
+		&problem.AcceptanceRate,
+		&problem.TotalSubmissions,
+		&problem.SuccessfulSubmissions,
+		&problem.IsActive,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrProblemNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error running get problem by id query: %w", err)
+	}
+
+	return &problem, nil
+}
+
 func (pg *PostgresProblemStore) GetTanstackTableProblemsByListID(userID *uuid.UUID, listID uuid.UUID) ([]TanstackTableProblem, error) {
 
 	query := `
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+var (
+	ErrRejudgeNotFound  = errors.New("rejudge not found")
+	ErrNothingToRejudge = errors.New("no finished submissions to rejudge")
+)
+
+type PostgresRejudgeStore struct {
+	DB *sql.DB
+}
+
+func NewPostgresRejudgeStore(db *sql.DB) *PostgresRejudgeStore {
+	return &PostgresRejudgeStore{
+		DB: db,
+	}
+}
+
+type RejudgeStore interface {
+	CreateRejudge(problemID uuid.UUID, submissionIDs []uuid.UUID) (*models.Rejudge, []models.Submission, error)
+	RecordRejudgeResult(rejudgeID uuid.UUID, submissionID uuid.UUID, status models.SubmissionStatus) error
+	GetRejudgeByID(rejudgeID uuid.UUID) (*models.Rejudge, error)
+	GetOpenRejudgeID(submissionID uuid.UUID) (uuid.UUID, error)
+}
+
+const rejudgeColumns = `id, problem_id, total, done, changed, failed, created_at, finished_at`
+
+func scanRejudge(row rowScanner) (models.Rejudge, error) {
+	var rejudge models.Rejudge
+	err := row.Scan(
+		&rejudge.ID,
+		&rejudge.ProblemID,
+		&rejudge.Total,
+		&rejudge.Done,
+		&rejudge.Changed,
+		&rejudge.Failed,
+		&rejudge.CreatedAt,
+		&rejudge.FinishedAt,
+	)
+	return rejudge, err
+}
+
+// A submission queued by an earlier rejudge keeps its old status until a
+// worker gets to it, its open history row is what tells it apart.
+const notBeingRejudged = `NOT EXISTS (
+	SELECT 1 FROM submission_verdict_history h
+	WHERE h.submission_id = s.id AND h.new_status IS NULL
+)`
+
+// CreateRejudge records a rejudge of the problem's finished submissions, or
+// only of the given ones when submissionIDs isn't empty, and saves their
+// current verdicts. Submissions still waiting on another rejudge are left
+// out. It returns the submissions to judge again.
+func (ps *PostgresRejudgeStore) CreateRejudge(problemID uuid.UUID, submissionIDs []uuid.UUID) (*models.Rejudge, []models.Submission, error) {
+
+	tx, err := ps.DB.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	defer func() {
+		if rErr := tx.Rollback(); rErr != nil && rErr != sql.ErrTxDone {
+			fmt.Printf("rollback error: %v", rErr)
+		}
+	}()
+
+	query := `
+		SELECT ` + submissionColumns + ` FROM submissions s
+		WHERE problem_id = $1 AND status NOT IN ($2, $3) AND ` + notBeingRejudged + `
+		ORDER BY created_at ASC
+	`
+	args := []any{problemID, models.StatusPending, models.StatusRunning}
+
+	if len(submissionIDs) > 0 {
+		ids := make([]string, len(submissionIDs))
+		for i, id := range submissionIDs {
+			ids[i] = id.String()
+		}
+
+		query = `
+			SELECT ` + submissionColumns + ` FROM submissions s
+			WHERE problem_id = $1 AND status NOT IN ($2, $3) AND id = ANY($4::uuid[]) AND ` + notBeingRejudged + `
+			ORDER BY created_at ASC
+		`
+		args = append(args, ids)
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error running get submissions to rejudge query: %w", err)
+	}
+
+	var submissions []models.Submission
+	for rows.Next() {
+		submission, err := scanSubmission(rows)
+		if err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		submissions = append(submissions, submission)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	if len(submissions) == 0 {
+		return nil, nil, ErrNothingToRejudge
+	}
+
+	query = `
+		INSERT INTO rejudges (problem_id, total)
+		VALUES ($1, $2)
+		RETURNING ` + rejudgeColumns
+
+	rejudge, err := scanRejudge(tx.QueryRow(query, problemID, len(submissions)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error running create rejudge query: %w", err)
+	}
+
+	for _, submission := range submissions {
+		query := `
+			INSERT INTO submission_verdict_history (submission_id, rejudge_id, status, runtime, memory_used, total_testcases, passed_testcases, first_failed_testcase)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`
+		_, err = tx.Exec(query, submission.ID, rejudge.ID, submission.Status, submission.Runtime, submission.MemoryUsed, submission.TotalTestcases, submission.PassedTestcases, submission.FirstFailedTestcase)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to insert submission_verdict_history: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit create rejudge: %w", err)
+	}
+
+	return &rejudge, submissions, nil
+}
+
+// RecordRejudgeResult saves the submission's new verdict and counts it
+// towards the rejudge's progress. Recording the same submission twice only
+// counts it once.
+func (ps *PostgresRejudgeStore) RecordRejudgeResult(rejudgeID uuid.UUID, submissionID uuid.UUID, status models.SubmissionStatus) error {
+
+	query := `
+		WITH recorded AS (
+			UPDATE submission_verdict_history
+			SET new_status = $3
+			WHERE rejudge_id = $1 AND submission_id = $2 AND new_status IS NULL
+			RETURNING status
+		)
+		UPDATE rejudges r
+		SET done = r.done + 1,
+			changed = r.changed + CASE WHEN recorded.status <> $3 THEN 1 ELSE 0 END,
+			failed = r.failed + CASE WHEN $3 = 'IE' THEN 1 ELSE 0 END,
+			finished_at = CASE WHEN r.done + 1 >= r.total THEN CURRENT_TIMESTAMP ELSE r.finished_at END
+		FROM recorded
+		WHERE r.id = $1
+	`
+
+	_, err := ps.DB.Exec(query, rejudgeID, submissionID, status)
+	if err != nil {
+		return fmt.Errorf("error running record rejudge result query: %w", err)
+	}
+	return nil
+}
+
+func (ps *PostgresRejudgeStore) GetRejudgeByID(rejudgeID uuid.UUID) (*models.Rejudge, error) {
+
+	query := `
+		SELECT ` + rejudgeColumns + ` FROM rejudges
+		WHERE id = $1
+	`
+
+	rejudge, err := scanRejudge(ps.DB.QueryRow(query, rejudgeID))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrRejudgeNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error running get rejudge by id query: %w", err)
+	}
+
+	return &rejudge, nil
+}
+
+// GetOpenRejudgeID returns the rejudge still waiting on this submission's
+// verdict, or uuid.Nil when there isn't one.
+func (ps *PostgresRejudgeStore) GetOpenRejudgeID(submissionID uuid.UUID) (uuid.UUID, error) {
+
+	query := `
+		SELECT rejudge_id FROM submission_verdict_history
+		WHERE submission_id = $1 AND new_status IS NULL
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	var rejudgeID uuid.UUID
+	err := ps.DB.QueryRow(query, submissionID).Scan(&rejudgeID)
+
+	if err == sql.ErrNoRows {
+		return uuid.Nil, nil
+	}
+
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("error running get open rejudge id query: %w", err)
+	}
+
+	return rejudgeID, nil
+}
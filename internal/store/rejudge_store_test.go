@@ -0,0 +1,186 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+// uuidArrays passes []string arguments, sent to Postgres as uuid[], as
+// array literals the mock can hold.
+type uuidArrays struct{}
+
+func (uuidArrays) ConvertValue(v any) (driver.Value, error) {
+	if values, ok := v.([]string); ok {
+		return "{" + strings.Join(values, ",") + "}", nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+func newRejudgeMock(t *testing.T) (*PostgresRejudgeStore, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New(sqlmock.ValueConverterOption(uuidArrays{}))
+	if err != nil {
+		t.Fatalf("sqlmock.New() error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return NewPostgresRejudgeStore(db), mock
+}
+
+func TestCreateRejudge(t *testing.T) {
+	errDB := errors.New("connection reset")
+	problemID := uuid.New()
+	rejudgeID := uuid.New()
+	runtime := 12
+
+	finished := []models.Submission{
+		{ID: uuid.New(), Status: models.StatusAC, Runtime: &runtime},
+		{ID: uuid.New(), Status: models.StatusWA},
+	}
+
+	tests := []struct {
+		name          string
+		submissionIDs []uuid.UUID
+		found         []models.Submission
+		failHistory   bool
+		wantErr       error
+	}{
+		{name: "every finished submission", found: finished},
+		{name: "only the given submissions", submissionIDs: []uuid.UUID{finished[1].ID}, found: finished[1:]},
+		{name: "nothing to rejudge", wantErr: ErrNothingToRejudge},
+		{name: "history insert fails", found: finished, failHistory: true, wantErr: errDB},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps, mock := newRejudgeMock(t)
+
+			rows := sqlmock.NewRows(strings.Split(submissionColumns, ", "))
+			for _, s := range tt.found {
+				var runtime driver.Value
+				if s.Runtime != nil {
+					runtime = int64(*s.Runtime)
+				}
+				rows.AddRow(s.ID.String(), uuid.New().String(), problemID.String(), "python", "code", string(s.Status), runtime, nil, 2, 1, 1, nil, time.Now())
+			}
+
+			// Both queries leave out submissions another rejudge is still
+			// waiting on
+			mock.ExpectBegin()
+			if len(tt.submissionIDs) > 0 {
+				mock.ExpectQuery(`SELECT .+ FROM submissions .+ id = ANY\(\$4::uuid\[\]\) AND NOT EXISTS \(.+new_status IS NULL`).
+					WithArgs(problemID.String(), string(models.StatusPending), string(models.StatusRunning), "{"+tt.submissionIDs[0].String()+"}").
+					WillReturnRows(rows)
+			} else {
+				mock.ExpectQuery(`SELECT .+ FROM submissions .+ NOT EXISTS \(.+new_status IS NULL`).
+					WithArgs(problemID.String(), string(models.StatusPending), string(models.StatusRunning)).
+					WillReturnRows(rows)
+			}
+
+			if len(tt.found) > 0 {
+				mock.ExpectQuery(`INSERT INTO rejudges`).
+					WithArgs(problemID.String(), len(tt.found)).
+					WillReturnRows(sqlmock.NewRows(strings.Split(rejudgeColumns, ", ")).
+						AddRow(rejudgeID.String(), problemID.String(), len(tt.found), 0, 0, 0, time.Now(), nil))
+
+				// The old verdict of each is kept before it's judged again
+				for i, s := range tt.found {
+					exec := mock.ExpectExec(`INSERT INTO submission_verdict_history`).
+						WithArgs(s.ID.String(), rejudgeID.String(), string(s.Status), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg())
+					if tt.failHistory && i == len(tt.found)-1 {
+						exec.WillReturnError(errDB)
+						break
+					}
+					exec.WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+			}
+
+			if tt.wantErr != nil {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
+
+			rejudge, submissions, err := ps.CreateRejudge(problemID, tt.submissionIDs)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateRejudge() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil {
+				if rejudge.ID != rejudgeID || rejudge.Total != len(tt.found) {
+					t.Errorf("rejudge = %s with %d submissions, want %s with %d", rejudge.ID, rejudge.Total, rejudgeID, len(tt.found))
+				}
+				if len(submissions) != len(tt.found) {
+					t.Errorf("got %d submissions to rejudge, want %d", len(submissions), len(tt.found))
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRecordRejudgeResult(t *testing.T) {
+	ps, mock := newRejudgeMock(t)
+	rejudgeID, submissionID := uuid.New(), uuid.New()
+
+	// Only an open history row is recorded, so a second result is a no-op
+	mock.ExpectExec(`new_status IS NULL`).
+		WithArgs(rejudgeID.String(), submissionID.String(), string(models.StatusAC)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := ps.RecordRejudgeResult(rejudgeID, submissionID, models.StatusAC); err != nil {
+		t.Fatalf("RecordRejudgeResult() error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetOpenRejudgeID(t *testing.T) {
+	open := uuid.New()
+
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+		want uuid.UUID
+	}{
+		{"waiting on a rejudge", sqlmock.NewRows([]string{"rejudge_id"}).AddRow(open.String()), open},
+		{"no open rejudge", sqlmock.NewRows([]string{"rejudge_id"}), uuid.Nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps, mock := newRejudgeMock(t)
+			mock.ExpectQuery(`FROM submission_verdict_history`).WillReturnRows(tt.rows)
+
+			got, err := ps.GetOpenRejudgeID(uuid.New())
+			if err != nil {
+				t.Fatalf("GetOpenRejudgeID() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetOpenRejudgeID() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRejudgeByIDNotFound(t *testing.T) {
+	ps, mock := newRejudgeMock(t)
+	mock.ExpectQuery(`FROM rejudges`).WillReturnError(sql.ErrNoRows)
+
+	if _, err := ps.GetRejudgeByID(uuid.New()); !errors.Is(err, ErrRejudgeNotFound) {
+		t.Errorf("GetRejudgeByID() error = %v, want ErrRejudgeNotFound", err)
+	}
+}
@@ -31,6 +31,7 @@ func (ps *PostgresSolutionStore) GetSolutionsByProblemID(problemID uuid.UUID) ([
 			hint,
 			description,
 			code,
+			language,
 			code_explanation,
 			notes,
 			time_complexity,
@@ -59,6 +60,7 @@ func (ps *PostgresSolutionStore) GetSolutionsByProblemID(problemID uuid.UUID) ([
 			&sol.Hint,
 			&sol.Description,
 			&sol.Code,
+			&sol.Language,
 			&sol.CodeExplanation,
 			&sol.Notes,
 			&sol.TimeComplexity,
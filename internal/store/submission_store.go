@@ -2,12 +2,16 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
 
 	"github.com/google/uuid"
 	"github.com/grvbrk/async0_server/internal/models"
 )
 
+var ErrSubmissionNotFound = errors.New("submission not found")
+
 type PostgresSubmissionStore struct {
 	DB *sql.DB
 }
@@ -18,39 +22,157 @@ func NewPostgresSubmissionStore(db *sql.DB) *PostgresSubmissionStore {
 	}
 }
 
+const submissionColumns = `id, user_id, problem_id, language, code, status, runtime, memory_used, total_testcases, passed_testcases, failed_testcases, first_failed_testcase, created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubmission(row rowScanner) (models.Submission, error) {
+	var submission models.Submission
+	err := row.Scan(
+		&submission.ID,
+		&submission.UserID,
+		&submission.ProblemID,
+		&submission.Language,
+		&submission.Code,
+		&submission.Status,
+		&submission.Runtime,
+		&submission.MemoryUsed,
+		&submission.TotalTestcases,
+		&submission.PassedTestcases,
+		&submission.FailedTestcases,
+		&submission.FirstFailedTestcase,
+		&submission.CreatedAt,
+	)
+	return submission, err
+}
+
 type SubmissionStore interface {
-	CreateSubmission(userID uuid.UUID, problemID uuid.UUID, code string, result models.SubmitSubmissionResponse) error
+	CreatePendingSubmission(userID uuid.UUID, problemID uuid.UUID, language string, code string) (uuid.UUID, error)
+	UpdateSubmissionStatus(submissionID uuid.UUID, status models.SubmissionStatus) error
+	CompleteSubmission(submissionID uuid.UUID, result models.SubmitSubmissionResponse) error
+	DeleteSubmission(submissionID uuid.UUID) error
+	GetSubmissionByID(submissionID uuid.UUID) (*models.Submission, error)
 	GetSubmissionsByProblemID(userID uuid.UUID, problemID uuid.UUID) ([]models.Submission, error)
+	GetUnfinishedSubmissions() ([]models.Submission, error)
+	GetSubmissionTestcaseResults(submissionID uuid.UUID) ([]models.TestcaseResult, error)
+	GetSubmissionPercentile(submission *models.Submission) (models.SubmissionPercentile, error)
 }
 
-func (ps *PostgresSubmissionStore) CreateSubmission(userID uuid.UUID, problemID uuid.UUID, code string, result models.SubmitSubmissionResponse) error {
+func (ps *PostgresSubmissionStore) CreatePendingSubmission(userID uuid.UUID, problemID uuid.UUID, language string, code string) (uuid.UUID, error) {
 
 	query := `
-		INSERT INTO submissions (user_id, problem_id, code, status, total_testcases, passed_testcases, failed_testcases)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO submissions (user_id, problem_id, language, code, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
 	`
 
-	// var total_memory int
-	// var total_time float64
+	var submissionID uuid.UUID
+	err := ps.DB.QueryRow(query, userID, problemID, language, code, models.StatusPending).Scan(&submissionID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("error running create pending submission query: %w", err)
+	}
 
-	// for _, testcaseResult := range result.TestcasesResults {
-	// 	total_memory += testcaseResult.TCMemory
-	// 	total_time += testcaseResult.TCTime
-	// }
+	return submissionID, nil
+}
+
+func (ps *PostgresSubmissionStore) UpdateSubmissionStatus(submissionID uuid.UUID, status models.SubmissionStatus) error {
 
-	_, err := ps.DB.Exec(query, userID, problemID, code, result.OverallStatus, result.TotalTestcases, result.PassedTestcases, result.TotalTestcases-result.PassedTestcases)
+	query := `
+		UPDATE submissions
+		SET status = $1
+		WHERE id = $2
+	`
+
+	_, err := ps.DB.Exec(query, status, submissionID)
 	if err != nil {
-		return fmt.Errorf("error running create submission query: %w", err)
+		return fmt.Errorf("error running update submission status query: %w", err)
 	}
 	return nil
+}
+
+func (ps *PostgresSubmissionStore) CompleteSubmission(submissionID uuid.UUID, result models.SubmitSubmissionResponse) error {
+
+	tx, err := ps.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	defer func() {
+		if rErr := tx.Rollback(); rErr != nil && rErr != sql.ErrTxDone {
+			fmt.Printf("rollback error: %v", rErr)
+		}
+	}()
+
+	query := `
+		UPDATE submissions
+		SET status = $1, runtime = $2, memory_used = $3, total_testcases = $4, passed_testcases = $5, failed_testcases = $6, first_failed_testcase = $7
+		WHERE id = $8
+	`
+
+	_, err = tx.Exec(query, result.OverallStatus, result.Runtime, result.MemoryUsed, result.TotalTestcases, result.PassedTestcases, result.TotalTestcases-result.PassedTestcases, result.FirstFailedTestcase, submissionID)
+	if err != nil {
+		return fmt.Errorf("error running complete submission query: %w", err)
+	}
+
+	// Clear results of an earlier attempt in case this submission is judged again
+	_, err = tx.Exec(`DELETE FROM submission_testcase_results WHERE submission_id = $1`, submissionID)
+	if err != nil {
+		return fmt.Errorf("failed to clear submission_testcase_results: %w", err)
+	}
 
+	for i, tc := range result.TestcasesResults {
+		query := `
+			INSERT INTO submission_testcase_results (submission_id, testcase_id, position, hidden, status_id, status, verdict, time, memory, stdout, stderr, expected_output, passed, stdout_truncated, stderr_truncated, log)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		`
+		_, err = tx.Exec(query, submissionID, tc.TCTestcaseID, i, tc.TCHidden, tc.TCStatusID, tc.TCStatus, tc.TCVerdict, tc.TCTime, tc.TCMemory, tc.TCOutput, tc.TCStderr, tc.TCExpectedOutput, tc.TCPass, tc.TCOutputTruncated, tc.TCStderrTruncated, tc.TCLog)
+		if err != nil {
+			return fmt.Errorf("failed to insert submission_testcase_results: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit complete submission: %w", err)
+	}
+	return nil
+
+}
+
+func (ps *PostgresSubmissionStore) DeleteSubmission(submissionID uuid.UUID) error {
+	_, err := ps.DB.Exec(`DELETE FROM submissions WHERE id = $1`, submissionID)
+	if err != nil {
+		return fmt.Errorf("error running delete submission query: %w", err)
+	}
+	return nil
+}
+
+func (ps *PostgresSubmissionStore) GetSubmissionByID(submissionID uuid.UUID) (*models.Submission, error) {
+
+	query := `
+		SELECT ` + submissionColumns + ` FROM submissions
+		WHERE id = $1
+	`
+
+	submission, err := scanSubmission(ps.DB.QueryRow(query, submissionID))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrSubmissionNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error running get submission by id query: %w", err)
+	}
+
+	return &submission, nil
 }
 
 func (ps *PostgresSubmissionStore) GetSubmissionsByProblemID(userID uuid.UUID, problemID uuid.UUID) ([]models.Submission, error) {
 	var submissions []models.Submission
 
 	query := `
-		SELECT * FROM submissions
+		SELECT ` + submissionColumns + ` FROM submissions
 		WHERE user_id = $1 AND problem_id = $2
 		ORDER BY created_at DESC
 	`
@@ -63,20 +185,7 @@ func (ps *PostgresSubmissionStore) GetSubmissionsByProblemID(userID uuid.UUID, p
 	defer rows.Close()
 
 	for rows.Next() {
-		submission := models.Submission{}
-		err = rows.Scan(
-			&submission.ID,
-			&submission.UserID,
-			&submission.ProblemID,
-			&submission.Code,
-			&submission.Status,
-			&submission.Runtime,
-			&submission.MemoryUsed,
-			&submission.TotalTestcases,
-			&submission.PassedTestcases,
-			&submission.FailedTestcases,
-			&submission.CreatedAt,
-		)
+		submission, err := scanSubmission(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning row: %w", err)
 		}
@@ -86,3 +195,146 @@ func (ps *PostgresSubmissionStore) GetSubmissionsByProblemID(userID uuid.UUID, p
 
 	return submissions, nil
 }
+
+func (ps *PostgresSubmissionStore) GetUnfinishedSubmissions() ([]models.Submission, error) {
+	var submissions []models.Submission
+
+	query := `
+		SELECT ` + submissionColumns + ` FROM submissions
+		WHERE status IN ($1, $2)
+		ORDER BY created_at ASC
+	`
+
+	rows, err := ps.DB.Query(query, models.StatusPending, models.StatusRunning)
+	if err != nil {
+		return nil, fmt.Errorf("error running get unfinished submissions query: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		submission, err := scanSubmission(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		submissions = append(submissions, submission)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return submissions, nil
+}
+
+func (ps *PostgresSubmissionStore) GetSubmissionTestcaseResults(submissionID uuid.UUID) ([]models.TestcaseResult, error) {
+
+	query := `
+		SELECT
+			r.testcase_id,
+			r.hidden,
+			r.passed,
+			r.status_id,
+			r.status,
+			COALESCE(r.verdict, 'IE'),
+			COALESCE(r.time, 0),
+			COALESCE(r.memory, 0),
+			COALESCE(t.input, ''),
+			COALESCE(r.stdout, ''),
+			COALESCE(r.stderr, ''),
+			COALESCE(r.expected_output, ''),
+			r.stdout_truncated,
+			r.stderr_truncated,
+			COALESCE(r.log, '')
+		FROM submission_testcase_results r
+		LEFT JOIN testcases t ON r.testcase_id = t.id
+		WHERE r.submission_id = $1
+		ORDER BY r.position ASC
+	`
+
+	rows, err := ps.DB.Query(query, submissionID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying submission testcase results: %w", err)
+	}
+
+	defer rows.Close()
+
+	results := []models.TestcaseResult{}
+	for rows.Next() {
+		var tc models.TestcaseResult
+		err := rows.Scan(
+			&tc.TCTestcaseID,
+			&tc.TCHidden,
+			&tc.TCPass,
+			&tc.TCStatusID,
+			&tc.TCStatus,
+			&tc.TCVerdict,
+			&tc.TCTime,
+			&tc.TCMemory,
+			&tc.TCInput,
+			&tc.TCOutput,
+			&tc.TCStderr,
+			&tc.TCExpectedOutput,
+			&tc.TCOutputTruncated,
+			&tc.TCStderrTruncated,
+			&tc.TCLog,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("error scanning submission testcase result: %w", err)
+		}
+
+		results = append(results, tc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating submission testcase results: %w", err)
+	}
+
+	return results, nil
+}
+
+func (ps *PostgresSubmissionStore) GetSubmissionPercentile(submission *models.Submission) (models.SubmissionPercentile, error) {
+
+	if submission.Runtime == nil || submission.MemoryUsed == nil {
+		return models.SubmissionPercentile{}, fmt.Errorf("submission %s has no runtime or memory recorded", submission.ID)
+	}
+
+	query := `
+		SELECT
+			COUNT(*) as total_accepted,
+			COUNT(*) FILTER (WHERE runtime > $2) as slower,
+			COUNT(*) FILTER (WHERE memory_used > $3) as heavier
+		FROM submissions
+		WHERE problem_id = $1
+			AND language = $5
+			AND status = 'AC'
+			AND runtime IS NOT NULL
+			AND memory_used IS NOT NULL
+			AND id <> $4
+	`
+
+	var total, slower, heavier int
+	err := ps.DB.QueryRow(query, submission.ProblemID, *submission.Runtime, *submission.MemoryUsed, submission.ID, submission.Language).Scan(&total, &slower, &heavier)
+	if err != nil {
+		return models.SubmissionPercentile{}, fmt.Errorf("error running get submission percentile query: %w", err)
+	}
+
+	percentile := models.SubmissionPercentile{
+		SubmissionID:  submission.ID,
+		Runtime:       *submission.Runtime,
+		MemoryUsed:    *submission.MemoryUsed,
+		TotalAccepted: total,
+		RuntimeBeats:  100,
+		MemoryBeats:   100,
+	}
+
+	// With no other accepted submission there's nobody to compare against, so it beats everyone
+	if total > 0 {
+		percentile.RuntimeBeats = math.Round(float64(slower)/float64(total)*10000) / 100
+		percentile.MemoryBeats = math.Round(float64(heavier)/float64(total)*10000) / 100
+	}
+
+	return percentile, nil
+}
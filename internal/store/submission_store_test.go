@@ -0,0 +1,102 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/grvbrk/async0_server/internal/models"
+)
+
+func TestCompleteSubmission(t *testing.T) {
+	errDB := errors.New("connection reset")
+	testcaseID := uuid.New()
+
+	tests := []struct {
+		name    string
+		results []models.TestcaseResult
+		// failAt makes the statement with this index fail: 0 the update,
+		// 1 the delete, 2 onwards the inserts. -1 for none.
+		failAt  int
+		wantErr bool
+	}{
+		{
+			name: "stores every testcase",
+			results: []models.TestcaseResult{
+				{TCTestcaseID: &testcaseID, TCStatusID: 3, TCStatus: "Accepted", TCPass: true},
+				{TCStatusID: 4, TCStatus: "Wrong Answer"},
+			},
+			failAt: -1,
+		},
+		{
+			name:   "no testcases",
+			failAt: -1,
+		},
+		{
+			name:    "update fails",
+			results: []models.TestcaseResult{{TCStatusID: 3}},
+			failAt:  0,
+			wantErr: true,
+		},
+		{
+			name:    "clearing old results fails",
+			results: []models.TestcaseResult{{TCStatusID: 3}},
+			failAt:  1,
+			wantErr: true,
+		},
+		{
+			name:    "insert fails part way",
+			results: []models.TestcaseResult{{TCStatusID: 3}, {TCStatusID: 3}, {TCStatusID: 3}},
+			failAt:  3,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New() error: %v", err)
+			}
+			defer db.Close()
+
+			statements := []string{`UPDATE submissions`, `DELETE FROM submission_testcase_results`}
+			for range tt.results {
+				statements = append(statements, `INSERT INTO submission_testcase_results`)
+			}
+
+			mock.ExpectBegin()
+			for i, statement := range statements {
+				exec := mock.ExpectExec(statement)
+				if i == tt.failAt {
+					exec.WillReturnError(errDB)
+					break
+				}
+				exec.WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			if tt.wantErr {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
+
+			ps := NewPostgresSubmissionStore(db)
+			err = ps.CompleteSubmission(uuid.New(), models.SubmitSubmissionResponse{
+				OverallStatus:    models.StatusWA,
+				TotalTestcases:   len(tt.results),
+				TestcasesResults: tt.results,
+			})
+
+			if tt.wantErr && !errors.Is(err, errDB) {
+				t.Errorf("CompleteSubmission() error = %v, want %v", err, errDB)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("CompleteSubmission() error: %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
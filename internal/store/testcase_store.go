@@ -19,10 +19,20 @@ func NewPostgresTestcaseStore(db *sql.DB) *PostgresTestcaseStore {
 }
 
 type TestcaseStore interface {
+	// GetTestcasesByProblemID returns every active testcase, hidden ones included. It's meant for judging.
 	GetTestcasesByProblemID(problemID uuid.UUID) ([]models.Testcase, error)
+	GetSampleTestcasesByProblemID(problemID uuid.UUID) ([]models.Testcase, error)
 }
 
 func (ps *PostgresTestcaseStore) GetTestcasesByProblemID(problemID uuid.UUID) ([]models.Testcase, error) {
+	return ps.getTestcases(problemID, false)
+}
+
+func (ps *PostgresTestcaseStore) GetSampleTestcasesByProblemID(problemID uuid.UUID) ([]models.Testcase, error) {
+	return ps.getTestcases(problemID, true)
+}
+
+func (ps *PostgresTestcaseStore) getTestcases(problemID uuid.UUID, samplesOnly bool) ([]models.Testcase, error) {
 	query := `
 		SELECT
 			id,
@@ -31,13 +41,15 @@ func (ps *PostgresTestcaseStore) GetTestcasesByProblemID(problemID uuid.UUID) ([
 			input,
 			output,
 			position,
+			is_sample,
 			is_active,
 			created_at
 		FROM testcases
-		WHERE problem_id = $1
+		WHERE problem_id = $1 AND is_active = TRUE AND ($2 = FALSE OR is_sample = TRUE)
+		ORDER BY position ASC
 	`
 
-	rows, err := ps.DB.Query(query, problemID)
+	rows, err := ps.DB.Query(query, problemID, samplesOnly)
 	if err != nil {
 		return nil, fmt.Errorf("error querying testcases: %w", err)
 	}
@@ -54,6 +66,7 @@ func (ps *PostgresTestcaseStore) GetTestcasesByProblemID(problemID uuid.UUID) ([
 			&tc.Input,
 			&tc.Output,
 			&tc.Position,
+			&tc.IsSample,
 			&tc.IsActive,
 			&tc.CreatedAt,
 		)
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/grvbrk/async0_server/internal/app"
@@ -19,6 +21,14 @@ func main() {
 		app.Logger.Fatal("Error creating new Application", err)
 	}
 
+	// Anything after the binary name is a command, e.g. "rejudge"
+	if len(os.Args) > 1 {
+		runCommand(app, os.Args[1:])
+		return
+	}
+
+	app.JudgePool.Start(context.Background())
+
 	r := routes.SetupRoutes(app)
 
 	server := &http.Server{
@@ -0,0 +1,8 @@
+-- +goose NO TRANSACTION
+-- ALTER TYPE ... ADD VALUE can't run inside a transaction before Postgres 12
+
+-- +goose Up
+ALTER TYPE submission_status ADD VALUE IF NOT EXISTS 'IE';
+
+-- +goose Down
+-- enum values can't be dropped, so 'IE' stays on the type
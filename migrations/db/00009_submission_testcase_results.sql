@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS submission_testcase_results (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  submission_id UUID NOT NULL REFERENCES submissions(id) ON DELETE CASCADE,
+  testcase_id UUID REFERENCES testcases(id) ON DELETE SET NULL,
+  position INTEGER NOT NULL,
+
+  status_id INTEGER NOT NULL,
+  status VARCHAR(50) NOT NULL,
+  time DOUBLE PRECISION,
+  memory INTEGER,
+  stdout TEXT,
+  stderr TEXT,
+  expected_output TEXT,
+  passed BOOLEAN NOT NULL DEFAULT FALSE,
+
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_submission_testcase_results_submission_id ON submission_testcase_results(submission_id, position);
+CREATE INDEX IF NOT EXISTS idx_submission_testcase_results_testcase_id ON submission_testcase_results(testcase_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_submission_testcase_results_testcase_id;
+DROP INDEX IF EXISTS idx_submission_testcase_results_submission_id;
+
+DROP TABLE IF EXISTS submission_testcase_results;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- runtime is the total time across testcases in ms, memory_used the peak in KB
+CREATE INDEX IF NOT EXISTS idx_submissions_problem_accepted_runtime ON submissions(problem_id, runtime) WHERE status = 'AC';
+CREATE INDEX IF NOT EXISTS idx_submissions_problem_accepted_memory ON submissions(problem_id, memory_used) WHERE status = 'AC';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_submissions_problem_accepted_memory;
+DROP INDEX IF EXISTS idx_submissions_problem_accepted_runtime;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS first_failed_testcase INTEGER;
+
+ALTER TABLE submission_testcase_results ADD COLUMN IF NOT EXISTS verdict submission_status;
+
+UPDATE submission_testcase_results
+SET verdict = CASE
+  WHEN passed THEN 'AC'::submission_status
+  WHEN status_id = 5 THEN 'TLE'::submission_status
+  WHEN status_id = 6 THEN 'CE'::submission_status
+  WHEN status_id BETWEEN 7 AND 12 THEN 'RE'::submission_status
+  WHEN status_id IN (3, 4) THEN 'WA'::submission_status
+  ELSE 'IE'::submission_status
+END
+WHERE verdict IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE submission_testcase_results DROP COLUMN IF EXISTS verdict;
+
+ALTER TABLE submissions DROP COLUMN IF EXISTS first_failed_testcase;
+-- +goose StatementEnd
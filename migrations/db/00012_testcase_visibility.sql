@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE testcases ADD COLUMN IF NOT EXISTS is_sample BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Keep the first testcase of every problem visible so problem pages aren't left empty
+UPDATE testcases t
+SET is_sample = TRUE
+WHERE t.position = (SELECT MIN(position) FROM testcases WHERE problem_id = t.problem_id);
+
+CREATE INDEX IF NOT EXISTS idx_testcases_problem_sample ON testcases(problem_id, is_sample);
+
+ALTER TABLE submission_testcase_results ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT TRUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE submission_testcase_results DROP COLUMN IF EXISTS hidden;
+
+DROP INDEX IF EXISTS idx_testcases_problem_sample;
+
+ALTER TABLE testcases DROP COLUMN IF EXISTS is_sample;
+-- +goose StatementEnd
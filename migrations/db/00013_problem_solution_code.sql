@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Reference solution used to work out expected outputs for custom run inputs
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS solution_code TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE problems DROP COLUMN IF EXISTS solution_code;
+-- +goose StatementEnd
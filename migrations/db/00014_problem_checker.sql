@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE checker_mode AS ENUM ('exact', 'whitespace', 'float', 'unordered', 'set_of_arrays', 'special');
+
+ALTER TABLE problems
+  ADD COLUMN IF NOT EXISTS checker checker_mode NOT NULL DEFAULT 'whitespace',
+  ADD COLUMN IF NOT EXISTS checker_tolerance DOUBLE PRECISION,
+  ADD COLUMN IF NOT EXISTS checker_code TEXT;
+
+ALTER TABLE problems ADD CONSTRAINT problems_special_checker_code
+  CHECK (checker <> 'special' OR checker_code IS NOT NULL);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE problems DROP CONSTRAINT IF EXISTS problems_special_checker_code;
+
+ALTER TABLE problems
+  DROP COLUMN IF EXISTS checker_code,
+  DROP COLUMN IF EXISTS checker_tolerance,
+  DROP COLUMN IF EXISTS checker;
+
+DROP TYPE IF EXISTS checker_mode;
+-- +goose StatementEnd
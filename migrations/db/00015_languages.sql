@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Starter code is now keyed by language slug, existing starters are JavaScript
+ALTER TABLE problems
+  ALTER COLUMN starter_code TYPE JSONB USING jsonb_build_object('javascript', starter_code);
+
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS solution_language TEXT NOT NULL DEFAULT 'javascript';
+
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT 'javascript';
+
+-- Runtimes are only comparable within a language
+DROP INDEX IF EXISTS idx_submissions_problem_accepted_runtime;
+DROP INDEX IF EXISTS idx_submissions_problem_accepted_memory;
+CREATE INDEX IF NOT EXISTS idx_submissions_problem_language_accepted_runtime ON submissions(problem_id, language, runtime) WHERE status = 'AC';
+CREATE INDEX IF NOT EXISTS idx_submissions_problem_language_accepted_memory ON submissions(problem_id, language, memory_used) WHERE status = 'AC';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_submissions_problem_language_accepted_memory;
+DROP INDEX IF EXISTS idx_submissions_problem_language_accepted_runtime;
+CREATE INDEX IF NOT EXISTS idx_submissions_problem_accepted_runtime ON submissions(problem_id, runtime) WHERE status = 'AC';
+CREATE INDEX IF NOT EXISTS idx_submissions_problem_accepted_memory ON submissions(problem_id, memory_used) WHERE status = 'AC';
+
+ALTER TABLE submissions DROP COLUMN IF EXISTS language;
+
+ALTER TABLE problems DROP COLUMN IF EXISTS solution_language;
+
+ALTER TABLE problems
+  ALTER COLUMN starter_code TYPE TEXT USING COALESCE(starter_code->>'javascript', '');
+-- +goose StatementEnd
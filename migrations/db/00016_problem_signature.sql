@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Function name, parameter and return types, used to generate a harness per
+-- language. Problems without one keep pasting the input into the call.
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS signature JSONB;
+
+UPDATE problems SET signature = '{"function_name":"twoSum","params":[{"name":"nums","type":"int[]"},{"name":"target","type":"int"}],"return_type":"int[]"}' WHERE slug = 'two-sum';
+UPDATE problems SET signature = '{"function_name":"isValid","params":[{"name":"s","type":"string"}],"return_type":"bool"}' WHERE slug = 'valid-parentheses';
+UPDATE problems SET signature = '{"function_name":"merge","params":[{"name":"intervals","type":"int[][]"}],"return_type":"int[][]"}' WHERE slug = 'merge-intervals';
+UPDATE problems SET signature = '{"function_name":"maxProfit","params":[{"name":"prices","type":"int[]"}],"return_type":"int"}' WHERE slug = 'best-time-to-buy-sell-stock';
+UPDATE problems SET signature = '{"function_name":"maxSubArray","params":[{"name":"nums","type":"int[]"}],"return_type":"int"}' WHERE slug = 'maximum-subarray';
+UPDATE problems SET signature = '{"function_name":"productExceptSelf","params":[{"name":"nums","type":"int[]"}],"return_type":"int[]"}' WHERE slug = 'product-of-array-except-self';
+UPDATE problems SET signature = '{"function_name":"maxDepth","params":[{"name":"root","type":"TreeNode"}],"return_type":"int"}' WHERE slug = 'maximum-depth-binary-tree';
+UPDATE problems SET signature = '{"function_name":"invertTree","params":[{"name":"root","type":"TreeNode"}],"return_type":"TreeNode"}' WHERE slug = 'invert-binary-tree';
+UPDATE problems SET signature = '{"function_name":"search","params":[{"name":"nums","type":"int[]"},{"name":"target","type":"int"}],"return_type":"int"}' WHERE slug = 'binary-search';
+UPDATE problems SET signature = '{"function_name":"climbStairs","params":[{"name":"n","type":"int"}],"return_type":"int"}' WHERE slug = 'climbing-stairs';
+UPDATE problems SET signature = '{"function_name":"coinChange","params":[{"name":"coins","type":"int[]"},{"name":"amount","type":"int"}],"return_type":"int"}' WHERE slug = 'coin-change';
+UPDATE problems SET signature = '{"function_name":"numIslands","params":[{"name":"grid","type":"char[][]"}],"return_type":"int"}' WHERE slug = 'number-of-islands';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE problems DROP COLUMN IF EXISTS signature;
+-- +goose StatementEnd
@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS rejudges (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  problem_id UUID NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+
+  total INTEGER NOT NULL DEFAULT 0,
+  done INTEGER NOT NULL DEFAULT 0,
+  changed INTEGER NOT NULL DEFAULT 0,
+  failed INTEGER NOT NULL DEFAULT 0,
+
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  finished_at TIMESTAMP WITH TIME ZONE
+);
+
+-- The verdict each submission had before a rejudge, and the one it got
+CREATE TABLE IF NOT EXISTS submission_verdict_history (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  submission_id UUID NOT NULL REFERENCES submissions(id) ON DELETE CASCADE,
+  rejudge_id UUID NOT NULL REFERENCES rejudges(id) ON DELETE CASCADE,
+
+  status submission_status NOT NULL,
+  runtime INTEGER,
+  memory_used INTEGER,
+  total_testcases INTEGER,
+  passed_testcases INTEGER,
+  first_failed_testcase INTEGER,
+
+  new_status submission_status,
+
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (rejudge_id, submission_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rejudges_problem_id ON rejudges(problem_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_submission_verdict_history_submission_id ON submission_verdict_history(submission_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_submission_verdict_history_submission_id;
+DROP INDEX IF EXISTS idx_rejudges_problem_id;
+
+DROP TABLE IF EXISTS submission_verdict_history;
+DROP TABLE IF EXISTS rejudges;
+-- +goose StatementEnd
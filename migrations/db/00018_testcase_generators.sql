@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A program that prints one testcase input for the seed it reads on stdin
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS generator_code TEXT;
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS generator_language VARCHAR(50);
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS generator_seeds JSONB NOT NULL DEFAULT '[]';
+
+ALTER TABLE testcases ADD COLUMN IF NOT EXISTS is_generated BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE testcases ADD COLUMN IF NOT EXISTS seed TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE testcases DROP COLUMN IF EXISTS seed;
+ALTER TABLE testcases DROP COLUMN IF EXISTS is_generated;
+
+ALTER TABLE problems DROP COLUMN IF EXISTS generator_seeds;
+ALTER TABLE problems DROP COLUMN IF EXISTS generator_language;
+ALTER TABLE problems DROP COLUMN IF EXISTS generator_code;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Set when the judge cut the stored output down to its output limit
+ALTER TABLE submission_testcase_results ADD COLUMN IF NOT EXISTS stdout_truncated BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE submission_testcase_results ADD COLUMN IF NOT EXISTS stderr_truncated BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE submission_testcase_results DROP COLUMN IF EXISTS stderr_truncated;
+ALTER TABLE submission_testcase_results DROP COLUMN IF EXISTS stdout_truncated;
+-- +goose StatementEnd
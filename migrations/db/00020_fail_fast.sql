@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Judge submissions in order and stop at the first failing testcase
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS fail_fast BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE problems DROP COLUMN IF EXISTS fail_fast;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE problem_io_mode AS ENUM ('function', 'stdio');
+
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS io_mode problem_io_mode NOT NULL DEFAULT 'function';
+
+-- Stdio programs read their input themselves, there's no function to call
+ALTER TABLE problems ADD CONSTRAINT problems_stdio_signature
+  CHECK (io_mode <> 'stdio' OR signature IS NULL);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE problems DROP CONSTRAINT IF EXISTS problems_stdio_signature;
+
+ALTER TABLE problems DROP COLUMN IF EXISTS io_mode;
+
+DROP TYPE IF EXISTS problem_io_mode;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- What the code printed besides the result line of the harness
+ALTER TABLE submission_testcase_results ADD COLUMN IF NOT EXISTS log TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE submission_testcase_results DROP COLUMN IF EXISTS log;
+-- +goose StatementEnd
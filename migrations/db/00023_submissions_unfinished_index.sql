@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Used to be part of 00008, which has to run outside a transaction. IF NOT
+-- EXISTS keeps databases that already have it from that migration happy.
+CREATE INDEX IF NOT EXISTS idx_submissions_unfinished ON submissions(created_at) WHERE status IN ('PENDING', 'RUNNING');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_submissions_unfinished;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Existing solutions were written in their problem's reference language
+ALTER TABLE solutions ADD COLUMN IF NOT EXISTS language TEXT;
+
+UPDATE solutions s
+SET language = p.solution_language
+FROM problems p
+WHERE p.id = s.problem_id AND s.language IS NULL;
+
+ALTER TABLE solutions ALTER COLUMN language SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE solutions DROP COLUMN IF EXISTS language;
+-- +goose StatementEnd